@@ -1,16 +1,130 @@
 package main
 
 import (
+	"crypto/hmac"
+	"crypto/rand"
 	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
 	"encoding/hex"
+	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 )
 
-// hashPassword вычисляет SHA-256 хеш пароля.
-func hashPassword(password string) string {
+const (
+	// passwordScheme — идентификатор текущего алгоритма в User.PasswordHash.
+	passwordScheme = "pbkdf2-sha256"
+	// passwordIterations — число итераций PBKDF2 для новых хешей.
+	passwordIterations = 310000
+	passwordSaltLen    = 16
+	passwordKeyLen     = 32
+)
+
+// hashPassword вычисляет соленый PBKDF2-хеш пароля в формате
+// pbkdf2-sha256$<итерации>$<соль base64>$<хеш base64>.
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, passwordSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("generate password salt: %w", err)
+	}
+	return hashPasswordSalted(password, salt), nil
+}
+
+// hashPasswordSalted вычисляет PBKDF2-хеш пароля с заданной солью (см. hashPassword).
+func hashPasswordSalted(password string, salt []byte) string {
+	key := pbkdf2SHA256([]byte(password), salt, passwordIterations, passwordKeyLen)
+	return fmt.Sprintf("%s$%d$%s$%s", passwordScheme, passwordIterations,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
+
+// verifyPassword сверяет пароль с сохраненным хешем. needsRehash сообщает,
+// что хеш записан устаревшим алгоритмом или параметрами и его стоит пересчитать.
+func verifyPassword(encoded, password string) (ok, needsRehash bool) {
+	if strings.HasPrefix(encoded, passwordScheme+"$") {
+		parts := strings.Split(encoded, "$")
+		if len(parts) != 4 {
+			return false, false
+		}
+		iterations, err := strconv.Atoi(parts[1])
+		if err != nil || iterations <= 0 {
+			return false, false
+		}
+		salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+		if err != nil {
+			return false, false
+		}
+		want, err := base64.RawStdEncoding.DecodeString(parts[3])
+		if err != nil || len(want) == 0 {
+			return false, false
+		}
+		got := pbkdf2SHA256([]byte(password), salt, iterations, len(want))
+		if subtle.ConstantTimeCompare(got, want) != 1 {
+			return false, false
+		}
+		return true, iterations < passwordIterations
+	}
+	return verifyLegacyPassword(encoded, password), true
+}
+
+// verifyLegacyPassword проверяет старый формат: hex(SHA-256(пароль)) без соли.
+func verifyLegacyPassword(encoded, password string) bool {
+	want, err := hex.DecodeString(encoded)
+	if err != nil || len(want) != sha256.Size {
+		return false
+	}
 	sum := sha256.Sum256([]byte(password))
-	return hex.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare(sum[:], want) == 1
+}
+
+// pbkdf2SHA256 реализует PBKDF2 (RFC 8018) с HMAC-SHA256.
+func pbkdf2SHA256(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	hashLen := prf.Size()
+	blocks := (keyLen + hashLen - 1) / hashLen
+	key := make([]byte, 0, blocks*hashLen)
+	buf := make([]byte, 4)
+	u := make([]byte, hashLen)
+	for block := 1; block <= blocks; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(buf, uint32(block))
+		prf.Write(buf)
+		u = prf.Sum(u[:0])
+		t := make([]byte, hashLen)
+		copy(t, u)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
+
+// dummyPasswordHash используется при входе с неизвестным email,
+// чтобы время ответа не выдавало существование учетной записи. Соль не обязана быть
+// случайной: этим хешем ничего не защищено.
+var dummyPasswordHash = hashPasswordSalted("dummy-password", make([]byte, passwordSaltLen))
+
+// bearerToken извлекает токен из заголовка Authorization.
+func bearerToken(r *http.Request) string {
+	return strings.TrimSpace(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+}
+
+// requestClient собирает сведения о клиенте для записи в сессию.
+func requestClient(r *http.Request) sessionClient {
+	ua := r.UserAgent()
+	if len(ua) > 256 {
+		ua = ua[:256]
+	}
+	return sessionClient{UserAgent: ua, IP: clientIP(r)}
 }
 
 // withAuth проверяет Bearer-токен и роль пользователя перед вызовом обработчика.
@@ -21,12 +135,12 @@ func (s *Server) withAuth(next func(http.ResponseWriter, *http.Request, User), r
 	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
-		token := strings.TrimSpace(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+		token := bearerToken(r)
 		if token == "" {
 			writeError(w, http.StatusUnauthorized, "missing token")
 			return
 		}
-		user, ok := s.store.userByToken(token)
+		_, user, ok := s.store.sessionByToken(token)
 		if !ok {
 			writeError(w, http.StatusUnauthorized, "invalid token")
 			return
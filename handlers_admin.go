@@ -1,10 +1,17 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 // handleAdminUsers обрабатывает список пользователей и создание пользователя админом.
@@ -13,31 +20,1151 @@ func (s *Server) handleAdminUsers(w http.ResponseWriter, r *http.Request, _ User
 	case http.MethodGet:
 		writeJSON(w, http.StatusOK, s.store.listUsers())
 	case http.MethodPost:
-		s.handleRegister(w, r)
+		s.handleAdminUserCreate(w, r)
 	default:
 		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
 	}
 }
 
-// handleAdminUserByID удаляет пользователя по ID.
-func (s *Server) handleAdminUserByID(w http.ResponseWriter, r *http.Request, _ User) {
+// handleAdminUserCreate создает пользователя с любой ролью.
+func (s *Server) handleAdminUserCreate(w http.ResponseWriter, r *http.Request) {
+	var req userRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	if req.FullName == "" || req.Email == "" || req.Password == "" {
+		writeError(w, http.StatusBadRequest, "fullName, email and password are required")
+		return
+	}
+	if err := validateUserRole(req.Role, req.ClassName); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.createUserFromRequest(w, req)
+}
+
+// handleAdminUsersImport создает пользователей из CSV (ФИО, email, роль, класс, [пароль]).
+// CSV передается телом запроса или полем file формы multipart/form-data.
+// С ?dryRun=true только проверяет строки; иначе создает всех пользователей одной
+// операцией и возвращает учетные данные, пароли — только сгенерированные.
+func (s *Server) handleAdminUsersImport(w http.ResponseWriter, r *http.Request, _ User) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	// Ограничение действует на все тело запроса, в том числе на форму multipart:
+	// слишком большой файл отклоняется целиком, а не обрезается.
+	r.Body = http.MaxBytesReader(w, r.Body, rosterMaxBytes)
+	var body io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(rosterMaxBytes); err != nil {
+			if tooLarge(err) {
+				writeError(w, http.StatusRequestEntityTooLarge, "csv is larger than 5 MB")
+				return
+			}
+			writeError(w, http.StatusBadRequest, "failed to parse multipart form")
+			return
+		}
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "file field is required")
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+	raw, err := io.ReadAll(body)
+	if tooLarge(err) {
+		writeError(w, http.StatusRequestEntityTooLarge, "csv is larger than 5 MB")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "failed to read csv")
+		return
+	}
+	rows, err := parseRoster(raw)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	rowErrors := validateRoster(rows, s.store)
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dryRun"))
+	if dryRun || len(rowErrors) > 0 {
+		status := http.StatusOK
+		if !dryRun {
+			status = http.StatusUnprocessableEntity
+		}
+		writeJSON(w, status, map[string]any{
+			"dryRun": dryRun,
+			"total":  len(rows),
+			"valid":  len(rows) - len(rowErrors),
+			"errors": rowErrors,
+			"rows":   rows,
+		})
+		return
+	}
+
+	passwords := make([]string, len(rows))
+	for i, row := range rows {
+		passwords[i] = row.Password
+		if passwords[i] == "" {
+			if passwords[i], err = randomHex(6); err != nil {
+				writeError(w, http.StatusInternalServerError, "failed to generate password")
+				return
+			}
+		}
+	}
+	hashes, err := hashRosterPasswords(passwords)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to hash passwords")
+		return
+	}
+	users := make([]User, len(rows))
+	for i, row := range rows {
+		users[i] = User{
+			FullName:     row.FullName,
+			Email:        row.Email,
+			PasswordHash: hashes[i],
+			Role:         row.Role,
+			ClassName:    row.ClassName,
+		}
+	}
+	users, err = s.store.createUsers(users)
+	if err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	type credentials struct {
+		Line     int    `json:"line"`
+		User     User   `json:"user"`
+		Password string `json:"password,omitempty"`
+	}
+	created := make([]credentials, len(users))
+	for i, u := range users {
+		created[i] = credentials{Line: rows[i].Line, User: u}
+		if rows[i].Password == "" {
+			created[i].Password = passwords[i]
+		}
+	}
+	writeJSON(w, http.StatusCreated, map[string]any{
+		"dryRun":  false,
+		"total":   len(rows),
+		"created": created,
+	})
+}
+
+// handleAdminClasses выдает реестр классов и регистрирует новые.
+// Класс задается параллелью и литерой либо именем (7А, 7 a).
+func (s *Server) handleAdminClasses(w http.ResponseWriter, r *http.Request, _ User) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, s.store.listClasses())
+	case http.MethodPost:
+		type request struct {
+			Name              string `json:"name"`
+			Grade             int    `json:"grade"`
+			Letter            string `json:"letter"`
+			AcademicYear      string `json:"academicYear"`
+			HomeroomTeacherID int64  `json:"homeroomTeacherId"`
+			ScaleID           int64  `json:"scaleId"`
+		}
+		var req request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+		// Буквы других алфавитов normalizeClassName отбрасывает, поэтому такие классы
+		// отклоняются, а не создаются под именем без литеры.
+		raw := req.Letter
+		if strings.TrimSpace(req.Name) != "" {
+			raw = req.Name
+			req.Grade, req.Letter = parseClassName(normalizeClassName(req.Name))
+		} else {
+			req.Letter = normalizeClassName(req.Letter)
+		}
+		if req.Letter == "" && strings.IndexFunc(raw, unicode.IsLetter) >= 0 {
+			writeError(w, http.StatusBadRequest, "unsupported class letter")
+			return
+		}
+		if req.Grade < 1 || req.Grade > 11 {
+			writeError(w, http.StatusBadRequest, "grade must be between 1 and 11")
+			return
+		}
+		if utf8.RuneCountInString(req.Letter) > 1 || strings.IndexFunc(req.Letter, func(r rune) bool { return !isClassLetter(r) }) >= 0 {
+			writeError(w, http.StatusBadRequest, "letter must be a single letter")
+			return
+		}
+		req.AcademicYear = strings.TrimSpace(req.AcademicYear)
+		if req.AcademicYear == "" {
+			req.AcademicYear = currentAcademicYear(time.Now())
+		}
+		if !validAcademicYear(req.AcademicYear) {
+			writeError(w, http.StatusBadRequest, "academicYear must look like 2026/2027")
+			return
+		}
+		c, err := s.store.createClass(Class{
+			Grade:             req.Grade,
+			Letter:            req.Letter,
+			AcademicYear:      req.AcademicYear,
+			HomeroomTeacherID: req.HomeroomTeacherID,
+			ScaleID:           req.ScaleID,
+			CreatedAt:         time.Now().UTC(),
+		})
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusCreated, c)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleAdminClassByID показывает, изменяет или удаляет класс /api/admin/classes/{name}.
+func (s *Server) handleAdminClassByID(w http.ResponseWriter, r *http.Request, _ User) {
+	c, ok := s.store.getClass(strings.TrimPrefix(r.URL.Path, "/api/admin/classes/"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "class not found")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, c)
+	case http.MethodPatch:
+		type request struct {
+			AcademicYear      *string `json:"academicYear"`
+			HomeroomTeacherID *int64  `json:"homeroomTeacherId"`
+			ScaleID           *int64  `json:"scaleId"`
+		}
+		var req request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+		if req.AcademicYear != nil {
+			c.AcademicYear = strings.TrimSpace(*req.AcademicYear)
+			if !validAcademicYear(c.AcademicYear) {
+				writeError(w, http.StatusBadRequest, "academicYear must look like 2026/2027")
+				return
+			}
+		}
+		if req.HomeroomTeacherID != nil {
+			c.HomeroomTeacherID = *req.HomeroomTeacherID
+		}
+		if req.ScaleID != nil {
+			c.ScaleID = *req.ScaleID
+		}
+		updated, err := s.store.updateClass(c)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, updated)
+	case http.MethodDelete:
+		deleted, err := s.store.deleteClass(c.Name)
+		if errors.Is(err, errClassInUse) {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to delete class")
+			return
+		}
+		if !deleted {
+			writeError(w, http.StatusNotFound, "class not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleAdminAssignments выдает закрепления учителей за предметами и классами и создает новые.
+// Необязательные фильтры: ?teacherId=5&className=7A.
+func (s *Server) handleAdminAssignments(w http.ResponseWriter, r *http.Request, _ User) {
+	switch r.Method {
+	case http.MethodGet:
+		var teacherID int64
+		if raw := strings.TrimSpace(r.URL.Query().Get("teacherId")); raw != "" {
+			id, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "invalid teacherId")
+				return
+			}
+			teacherID = id
+		}
+		writeJSON(w, http.StatusOK, s.store.listAssignments(teacherID, r.URL.Query().Get("className")))
+	case http.MethodPost:
+		type request struct {
+			TeacherID int64  `json:"teacherId"`
+			Subject   string `json:"subject"`
+			ClassName string `json:"className"`
+			Group     string `json:"group"`
+		}
+		var req request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+		if req.TeacherID <= 0 || strings.TrimSpace(req.Subject) == "" || strings.TrimSpace(req.ClassName) == "" {
+			writeError(w, http.StatusBadRequest, "teacherId, subject, className are required")
+			return
+		}
+		a, err := s.store.createAssignment(Assignment{
+			TeacherID: req.TeacherID,
+			Subject:   req.Subject,
+			ClassName: req.ClassName,
+			Group:     req.Group,
+			CreatedAt: time.Now().UTC(),
+		})
+		if errors.Is(err, errAssignmentExists) {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusCreated, a)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleAdminAssignmentByID снимает закрепление /api/admin/assignments/{id}.
+func (s *Server) handleAdminAssignmentByID(w http.ResponseWriter, r *http.Request, _ User) {
+	if r.Method != http.MethodDelete {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/api/admin/assignments/"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	deleted, err := s.store.deleteAssignment(id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to delete assignment")
+		return
+	}
+	if !deleted {
+		writeError(w, http.StatusNotFound, "assignment not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// handleAdminSubjects выдает справочник предметов и добавляет новые.
+func (s *Server) handleAdminSubjects(w http.ResponseWriter, r *http.Request, _ User) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, s.store.listSubjects())
+	case http.MethodPost:
+		type request struct {
+			Name      string   `json:"name"`
+			ShortName string   `json:"shortName"`
+			Aliases   []string `json:"aliases"`
+			ScaleID   int64    `json:"scaleId"`
+		}
+		var req request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+		sub, err := s.store.createSubject(Subject{
+			Name:      req.Name,
+			ShortName: req.ShortName,
+			Aliases:   req.Aliases,
+			ScaleID:   req.ScaleID,
+			CreatedAt: time.Now().UTC(),
+		})
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusCreated, sub)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleAdminSubjectByID показывает, изменяет или удаляет предмет /api/admin/subjects/{id}.
+func (s *Server) handleAdminSubjectByID(w http.ResponseWriter, r *http.Request, _ User) {
+	id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/api/admin/subjects/"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	sub, ok := s.store.getSubject(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, "subject not found")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, sub)
+	case http.MethodPatch:
+		type request struct {
+			Name      *string   `json:"name"`
+			ShortName *string   `json:"shortName"`
+			Aliases   *[]string `json:"aliases"`
+			ScaleID   *int64    `json:"scaleId"`
+		}
+		var req request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+		if req.Name != nil {
+			sub.Name = *req.Name
+		}
+		if req.ShortName != nil {
+			sub.ShortName = *req.ShortName
+		}
+		if req.Aliases != nil {
+			sub.Aliases = *req.Aliases
+		}
+		if req.ScaleID != nil {
+			sub.ScaleID = *req.ScaleID
+		}
+		updated, err := s.store.updateSubject(sub)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, updated)
+	case http.MethodDelete:
+		deleted, err := s.store.deleteSubject(id)
+		if errors.Is(err, errSubjectInUse) {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to delete subject")
+			return
+		}
+		if !deleted {
+			writeError(w, http.StatusNotFound, "subject not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleAdminSubjectsMigrate сопоставляет свободные названия предметов в оценках, ДЗ,
+// расписании и закреплениях со справочником. С ?dryRun=true только показывает план.
+func (s *Server) handleAdminSubjectsMigrate(w http.ResponseWriter, r *http.Request, _ User) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	type request struct {
+		Mapping       map[string]int64 `json:"mapping"`
+		CreateMissing bool             `json:"createMissing"`
+	}
+	var req request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	params := subjectMigrationParams{Mapping: req.Mapping, CreateMissing: req.CreateMissing}
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dryRun"))
+	var (
+		report subjectMigrationReport
+		err    error
+	)
+	if dryRun {
+		report, err = s.store.previewSubjectMigration(params)
+	} else {
+		report, err = s.store.applySubjectMigration(params)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"dryRun": dryRun,
+		"report": report,
+	})
+}
+
+// handleAdminRollover переводит школу на следующий учебный год.
+// С ?dryRun=true только возвращает план: какие классы куда переходят, кто выпускается
+// и сколько записей уйдет в архив. Классы с параллелью finalGrade (по умолчанию 11) выпускаются.
+func (s *Server) handleAdminRollover(w http.ResponseWriter, r *http.Request, admin User) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	type request struct {
+		FinalGrade int `json:"finalGrade"`
+	}
+	var req request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	if req.FinalGrade == 0 {
+		req.FinalGrade = 11
+	}
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dryRun"))
+	var (
+		plan rolloverPlan
+		err  error
+	)
+	if dryRun {
+		plan, err = s.store.previewRollover(req.FinalGrade)
+	} else {
+		plan, err = s.store.applyRollover(req.FinalGrade, admin.ID)
+	}
+	if errors.Is(err, errYearArchived) {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"dryRun": dryRun,
+		"plan":   plan,
+	})
+}
+
+// handleAdminArchives возвращает список архивов учебных лет.
+func (s *Server) handleAdminArchives(w http.ResponseWriter, r *http.Request, _ User) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.store.listArchives())
+}
+
+// handleAdminArchiveByYear возвращает архив учебного года /api/admin/archives/{2026-2027}.
+func (s *Server) handleAdminArchiveByYear(w http.ResponseWriter, r *http.Request, _ User) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	archive, ok := s.store.getArchive(strings.TrimPrefix(r.URL.Path, "/api/admin/archives/"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "archive not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, archive)
+}
+
+// handleAdminInvites выдает список приглашений и создает новые.
+func (s *Server) handleAdminInvites(w http.ResponseWriter, r *http.Request, admin User) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, s.store.listInvites())
+	case http.MethodPost:
+		type request struct {
+			Role          Role   `json:"role"`
+			ClassName     string `json:"className"`
+			ExpiresInDays int    `json:"expiresInDays"`
+		}
+		var req request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+		if req.Role == "" {
+			req.Role = RoleStudent
+		}
+		if req.Role != RoleStudent {
+			writeError(w, http.StatusBadRequest, "invites can only be issued for students")
+			return
+		}
+		if err := validateUserRole(req.Role, req.ClassName); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if _, ok := s.store.getClass(req.ClassName); !ok {
+			writeError(w, http.StatusBadRequest, "unknown class")
+			return
+		}
+		if req.ExpiresInDays <= 0 {
+			req.ExpiresInDays = 14
+		}
+		now := time.Now().UTC()
+		inv, code, err := s.store.createInvite(Invite{
+			Role:      req.Role,
+			ClassName: req.ClassName,
+			CreatedBy: admin.ID,
+			CreatedAt: now,
+			ExpiresAt: now.AddDate(0, 0, req.ExpiresInDays),
+		})
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to create invite")
+			return
+		}
+		writeJSON(w, http.StatusCreated, map[string]any{
+			"invite": inv,
+			"code":   code,
+		})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleAdminInviteByID отзывает приглашение.
+func (s *Server) handleAdminInviteByID(w http.ResponseWriter, r *http.Request, _ User) {
 	if r.Method != http.MethodDelete {
 		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
 		return
 	}
-	idStr := strings.TrimPrefix(r.URL.Path, "/api/admin/users/")
+	id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/api/admin/invites/"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	deleted, err := s.store.deleteInvite(id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to delete invite")
+		return
+	}
+	if !deleted {
+		writeError(w, http.StatusNotFound, "invite not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// handleAdminUserByID маршрутизирует запросы /api/admin/users/{id}[/sessions|/password-reset].
+func (s *Server) handleAdminUserByID(w http.ResponseWriter, r *http.Request, admin User) {
+	idStr, sub, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/admin/users/"), "/")
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
 		writeError(w, http.StatusBadRequest, "invalid id")
 		return
 	}
-	if !s.store.deleteUser(id) {
+	switch sub {
+	case "":
+		switch r.Method {
+		case http.MethodPatch:
+			s.handleAdminUserUpdate(w, r, id, admin)
+		default:
+			s.handleAdminUserDelete(w, r, id)
+		}
+	case "sessions":
+		s.handleAdminUserSessions(w, r, id)
+	case "password-reset":
+		s.handleAdminPasswordReset(w, r, id, admin)
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+// handleAdminUserSessions показывает сессии пользователя или завершает их все.
+func (s *Server) handleAdminUserSessions(w http.ResponseWriter, r *http.Request, id int64) {
+	if _, ok := s.store.getUser(id); !ok {
+		writeError(w, http.StatusNotFound, "user not found")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, sessionViews(s.store.listSessionsByUser(id), ""))
+	case http.MethodDelete:
+		n, err := s.store.revokeUserSessions(id)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to revoke sessions")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"status": "revoked", "revoked": n})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleAdminPasswordReset выдает пользователю одноразовый код сброса пароля.
+// Код отправляется через outbox и в ответе не возвращается.
+func (s *Server) handleAdminPasswordReset(w http.ResponseWriter, r *http.Request, id int64, admin User) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	u, ok := s.store.getUser(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, "user not found")
+		return
+	}
+	now := time.Now().UTC()
+	reset, code, err := s.store.createPasswordReset(PasswordReset{
+		UserID:    u.ID,
+		CreatedBy: admin.ID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.resetTTL),
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to create password reset")
+		return
+	}
+	err = s.outbox.send(outboxMessage{
+		To:      u.Email,
+		Subject: "Password reset",
+		Body: fmt.Sprintf("Your password reset code: %s\nIt is valid until %s.\nUse it with POST /api/password/reset.",
+			code, reset.ExpiresAt.Format(time.RFC3339)),
+		CreatedAt: now,
+	})
+	if err != nil {
+		log.Printf("password reset for user %d: outbox error: %v", u.ID, err)
+		writeError(w, http.StatusInternalServerError, "failed to deliver reset code")
+		return
+	}
+	writeJSON(w, http.StatusCreated, reset)
+}
+
+// handleAdminUserUpdate меняет ФИО, email, роль или класс пользователя.
+// Переданные поля заменяют текущие, правила роли и класса те же, что при регистрации;
+// при смене роли класс сбрасывается, а сессии пользователя завершаются.
+func (s *Server) handleAdminUserUpdate(w http.ResponseWriter, r *http.Request, id int64, admin User) {
+	type request struct {
+		FullName  *string `json:"fullName"`
+		Email     *string `json:"email"`
+		Role      *Role   `json:"role"`
+		ClassName *string `json:"className"`
+	}
+	var req request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	updated, changes, err := s.store.updateUser(id, userPatch{
+		FullName:  req.FullName,
+		Email:     req.Email,
+		Role:      req.Role,
+		ClassName: req.ClassName,
+	}, admin.ID)
+	if errors.Is(err, errUserNotFound) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"user":    updated,
+		"changes": changes,
+	})
+}
+
+// handleAdminAudit возвращает журнал действий администраторов.
+// Необязательные фильтры: ?entity=user&entityId=2.
+func (s *Server) handleAdminAudit(w http.ResponseWriter, r *http.Request, _ User) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	var entityID int64
+	if raw := strings.TrimSpace(r.URL.Query().Get("entityId")); raw != "" {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid entityId")
+			return
+		}
+		entityID = id
+	}
+	writeJSON(w, http.StatusOK, s.store.listAudit(strings.TrimSpace(r.URL.Query().Get("entity")), entityID))
+}
+
+// handleAdminTerms выдает учебные периоды (?academicYear=2026/2027) и добавляет новые.
+func (s *Server) handleAdminTerms(w http.ResponseWriter, r *http.Request, _ User) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, s.store.listTerms(strings.TrimSpace(r.URL.Query().Get("academicYear"))))
+	case http.MethodPost:
+		type request struct {
+			AcademicYear string `json:"academicYear"`
+			Name         string `json:"name"`
+			StartDate    string `json:"startDate"`
+			EndDate      string `json:"endDate"`
+		}
+		var req request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+		t, err := s.store.createTerm(Term{
+			AcademicYear: req.AcademicYear,
+			Name:         req.Name,
+			StartDate:    req.StartDate,
+			EndDate:      req.EndDate,
+		})
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusCreated, t)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleAdminTermByID изменяет (PATCH) или удаляет (DELETE) учебный период.
+func (s *Server) handleAdminTermByID(w http.ResponseWriter, r *http.Request, _ User) {
+	id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/api/admin/terms/"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	t, ok := s.store.getTerm(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, "term not found")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, t)
+	case http.MethodPatch:
+		type request struct {
+			AcademicYear *string `json:"academicYear"`
+			Name         *string `json:"name"`
+			StartDate    *string `json:"startDate"`
+			EndDate      *string `json:"endDate"`
+		}
+		var req request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+		if req.AcademicYear != nil {
+			t.AcademicYear = *req.AcademicYear
+		}
+		if req.Name != nil {
+			t.Name = *req.Name
+		}
+		if req.StartDate != nil {
+			t.StartDate = *req.StartDate
+		}
+		if req.EndDate != nil {
+			t.EndDate = *req.EndDate
+		}
+		updated, err := s.store.updateTerm(t)
+		if errors.Is(err, errTermInUse) {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, updated)
+	case http.MethodDelete:
+		deleted, err := s.store.deleteTerm(id)
+		if errors.Is(err, errTermInUse) {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to delete term")
+			return
+		}
+		if !deleted {
+			writeError(w, http.StatusNotFound, "term not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleAdminTermRounding выдает (GET) и меняет (PUT) правила округления итоговых оценок.
+func (s *Server) handleAdminTermRounding(w http.ResponseWriter, r *http.Request, _ User) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, s.store.termRounding())
+	case http.MethodPut:
+		var req TermRounding
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+		rounding, err := s.store.setTermRounding(req)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, rounding)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleAdminTermGrades возвращает утвержденные итоговые оценки.
+// Необязательные фильтры: ?termId=&studentId=.
+func (s *Server) handleAdminTermGrades(w http.ResponseWriter, r *http.Request, _ User) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	termID, ok := queryID(w, r, "termId")
+	if !ok {
+		return
+	}
+	studentID, ok := queryID(w, r, "studentId")
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, s.store.listTermGrades(termID, studentID))
+}
+
+// handleAdminTermGradeByID отменяет утверждение итоговой оценки (DELETE), снимая
+// блокировку оценок ученика по предмету за период.
+func (s *Server) handleAdminTermGradeByID(w http.ResponseWriter, r *http.Request, _ User) {
+	if r.Method != http.MethodDelete {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/api/admin/term-grades/"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	reopened, err := s.store.reopenTermGrade(id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to reopen term grade")
+		return
+	}
+	if !reopened {
+		writeError(w, http.StatusNotFound, "term grade not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "reopened"})
+}
+
+// handleAdminGradeTypes возвращает виды работ с действующими весами.
+func (s *Server) handleAdminGradeTypes(w http.ResponseWriter, r *http.Request, _ User) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.store.listGradeTypes())
+}
+
+// handleAdminGradeTypeByCode меняет вес вида работы: PATCH /api/admin/grade-types/{code}.
+func (s *Server) handleAdminGradeTypeByCode(w http.ResponseWriter, r *http.Request, _ User) {
+	if r.Method != http.MethodPatch {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	code := strings.TrimPrefix(r.URL.Path, "/api/admin/grade-types/")
+	type request struct {
+		Weight float64 `json:"weight"`
+	}
+	var req request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	t, err := s.store.setGradeTypeWeight(code, req.Weight)
+	if errors.Is(err, errUnknownGradeType) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, t)
+}
+
+// handleAdminAttendance возвращает итоги посещаемости по классам за период
+// (?className=&termId= или &from=&to=; без периода — за все время).
+func (s *Server) handleAdminAttendance(w http.ResponseWriter, r *http.Request, _ User) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	from, to, ok := s.queryPeriod(w, r, false)
+	if !ok {
+		return
+	}
+	className := r.URL.Query().Get("className")
+	if strings.TrimSpace(className) != "" {
+		if _, ok := s.store.getClass(className); !ok {
+			writeError(w, http.StatusNotFound, "class not found")
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"from":    from,
+		"to":      to,
+		"classes": attendanceReport(s.store, className, from, to),
+	})
+}
+
+// handleAdminGradingScales выдает шкалы оценивания и добавляет новые.
+func (s *Server) handleAdminGradingScales(w http.ResponseWriter, r *http.Request, _ User) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, s.store.listScales())
+	case http.MethodPost:
+		type request struct {
+			Name    string       `json:"name"`
+			Values  []ScaleValue `json:"values"`
+			Default bool         `json:"default"`
+		}
+		var req request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+		sc, err := s.store.createScale(GradingScale{Name: req.Name, Values: req.Values, Default: req.Default})
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusCreated, sc)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleAdminGradingScaleByID показывает, изменяет или удаляет шкалу /api/admin/grading-scales/{id}.
+func (s *Server) handleAdminGradingScaleByID(w http.ResponseWriter, r *http.Request, _ User) {
+	id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/api/admin/grading-scales/"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	sc, ok := s.store.getScale(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, "grading scale not found")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, sc)
+	case http.MethodPatch:
+		type request struct {
+			Name    *string       `json:"name"`
+			Values  *[]ScaleValue `json:"values"`
+			Default *bool         `json:"default"`
+		}
+		var req request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+		if req.Name != nil {
+			sc.Name = *req.Name
+		}
+		if req.Values != nil {
+			sc.Values = *req.Values
+		}
+		if req.Default != nil {
+			sc.Default = *req.Default
+		}
+		updated, err := s.store.updateScale(sc)
+		if errors.Is(err, errScaleInUse) {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, updated)
+	case http.MethodDelete:
+		deleted, err := s.store.deleteScale(id)
+		if errors.Is(err, errScaleInUse) {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to delete grading scale")
+			return
+		}
+		if !deleted {
+			writeError(w, http.StatusNotFound, "grading scale not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleAdminGradeRevisions возвращает историю исправлений оценок.
+// Необязательные фильтры: ?studentId=&gradeId=.
+func (s *Server) handleAdminGradeRevisions(w http.ResponseWriter, r *http.Request, _ User) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	studentID, ok := queryID(w, r, "studentId")
+	if !ok {
+		return
+	}
+	gradeID, ok := queryID(w, r, "gradeId")
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, s.store.listGradeRevisions(gradeID, studentID))
+}
+
+// handleAdminUserDelete удаляет пользователя по ID.
+func (s *Server) handleAdminUserDelete(w http.ResponseWriter, r *http.Request, id int64) {
+	if r.Method != http.MethodDelete {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	deleted, err := s.store.deleteUser(id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to delete user")
+		return
+	}
+	if !deleted {
 		writeError(w, http.StatusNotFound, "user not found")
 		return
 	}
 	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
 }
 
+// handleAdminLockouts показывает счетчики неудачных входов и снимает блокировки.
+func (s *Server) handleAdminLockouts(w http.ResponseWriter, r *http.Request, _ User) {
+	switch r.Method {
+	case http.MethodGet:
+		type lockoutView struct {
+			throttleEntry
+			Locked     bool `json:"locked"`
+			RetryAfter int  `json:"retryAfter"`
+		}
+		now := time.Now()
+		res := []lockoutView{}
+		for _, e := range s.throttle.list() {
+			view := lockoutView{throttleEntry: e}
+			if now.Before(e.LockedUntil) {
+				view.Locked = true
+				view.RetryAfter = retryAfterSeconds(e.LockedUntil.Sub(now))
+			}
+			res = append(res, view)
+		}
+		writeJSON(w, http.StatusOK, res)
+	case http.MethodDelete:
+		key := strings.TrimSpace(r.URL.Query().Get("key"))
+		if key == "" {
+			writeError(w, http.StatusBadRequest, "key is required")
+			return
+		}
+		if !s.throttle.clear(key) {
+			writeError(w, http.StatusNotFound, "lockout not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "cleared"})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
 // handleAdminScheduleImport загружает фото расписания для выбранного класса.
 func (s *Server) handleAdminScheduleImport(w http.ResponseWriter, r *http.Request, _ User) {
 	if r.Method != http.MethodPost {
@@ -70,7 +1197,15 @@ func (s *Server) handleAdminScheduleImport(w http.ResponseWriter, r *http.Reques
 		writeError(w, http.StatusBadRequest, "uploaded file must be an image")
 		return
 	}
-	photo := s.store.setSchedulePhoto(className, contentType, raw)
+	photo, err := s.store.setSchedulePhoto(className, contentType, raw)
+	if errors.Is(err, errUnknownClass) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to save schedule photo")
+		return
+	}
 	writeJSON(w, http.StatusOK, map[string]any{
 		"status":    "imported",
 		"className": photo.ClassName,
@@ -83,7 +1218,10 @@ func (s *Server) handleAdminScheduleClear(w http.ResponseWriter, r *http.Request
 		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
 		return
 	}
-	s.store.clearSchedule()
+	if err := s.store.clearSchedule(); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to clear schedule")
+		return
+	}
 	writeJSON(w, http.StatusOK, map[string]string{
 		"status": "schedule cleared",
 	})
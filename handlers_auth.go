@@ -2,24 +2,72 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 )
 
-// handleRegister регистрирует нового пользователя.
+// registrationMode — политика публичной регистрации (REGISTRATION_MODE).
+type registrationMode string
+
+const (
+	// registrationStudents — сами могут зарегистрироваться только ученики, коды приглашений тоже принимаются.
+	registrationStudents registrationMode = "students"
+	// registrationInvite — регистрация только по коду приглашения.
+	registrationInvite registrationMode = "invite"
+	// registrationClosed — публичная регистрация отключена.
+	registrationClosed registrationMode = "closed"
+)
+
+// parseRegistrationMode разбирает значение REGISTRATION_MODE.
+func parseRegistrationMode(raw string) (registrationMode, error) {
+	switch mode := registrationMode(strings.ToLower(strings.TrimSpace(raw))); mode {
+	case "":
+		return registrationStudents, nil
+	case registrationStudents, registrationInvite, registrationClosed:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("REGISTRATION_MODE must be students|invite|closed, got %q", raw)
+	}
+}
+
+// userRequest — поля учетной записи в запросах регистрации и создания пользователя.
+type userRequest struct {
+	FullName   string `json:"fullName"`
+	Email      string `json:"email"`
+	Password   string `json:"password"`
+	Role       Role   `json:"role"`
+	ClassName  string `json:"className"`
+	InviteCode string `json:"inviteCode"`
+}
+
+// validateUserRole проверяет роль и обязательность класса для ученика.
+func validateUserRole(role Role, className string) error {
+	if role != RoleAdmin && role != RoleTeacher && role != RoleStudent {
+		return errors.New("role must be admin|teacher|student")
+	}
+	if role == RoleStudent && normalizeClassName(className) == "" {
+		return errors.New("className is required for student")
+	}
+	return nil
+}
+
+// handleRegister регистрирует нового пользователя по публичной политике регистрации.
+// Учителя и администраторы создаются только через /api/admin/users.
 func (s *Server) handleRegister(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
 		return
 	}
-	type request struct {
-		FullName  string `json:"fullName"`
-		Email     string `json:"email"`
-		Password  string `json:"password"`
-		Role      Role   `json:"role"`
-		ClassName string `json:"className"`
+	if s.registration == registrationClosed {
+		writeError(w, http.StatusForbidden, "registration is disabled")
+		return
 	}
-	var req request
+	var req userRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid json")
 		return
@@ -28,19 +76,55 @@ func (s *Server) handleRegister(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "fullName, email and password are required")
 		return
 	}
-	if req.Role != RoleAdmin && req.Role != RoleTeacher && req.Role != RoleStudent {
-		writeError(w, http.StatusBadRequest, "role must be admin|teacher|student")
+
+	if code := strings.TrimSpace(req.InviteCode); code != "" {
+		hash, err := hashPassword(req.Password)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to hash password")
+			return
+		}
+		user, err := s.store.redeemInvite(code, User{
+			FullName:     strings.TrimSpace(req.FullName),
+			Email:        strings.TrimSpace(req.Email),
+			PasswordHash: hash,
+		})
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusCreated, user)
 		return
 	}
-	if req.Role == RoleStudent && strings.TrimSpace(req.ClassName) == "" {
-		writeError(w, http.StatusBadRequest, "className is required for student")
+
+	if s.registration == registrationInvite {
+		writeError(w, http.StatusForbidden, "invite code is required")
 		return
 	}
+	if req.Role == "" {
+		req.Role = RoleStudent
+	}
+	if req.Role != RoleStudent {
+		writeError(w, http.StatusForbidden, "only students can self-register")
+		return
+	}
+	if err := validateUserRole(req.Role, req.ClassName); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.createUserFromRequest(w, req)
+}
 
+// createUserFromRequest создает проверенного пользователя и пишет ответ.
+func (s *Server) createUserFromRequest(w http.ResponseWriter, req userRequest) {
+	hash, err := hashPassword(req.Password)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to hash password")
+		return
+	}
 	user, err := s.store.createUser(User{
 		FullName:     strings.TrimSpace(req.FullName),
 		Email:        strings.TrimSpace(req.Email),
-		PasswordHash: hashPassword(req.Password),
+		PasswordHash: hash,
 		Role:         req.Role,
 		ClassName:    normalizeClassName(req.ClassName),
 	})
@@ -66,22 +150,331 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "invalid json")
 		return
 	}
-	u, ok := s.store.findUserByEmail(req.Email)
-	if !ok || u.PasswordHash != hashPassword(req.Password) {
+	ip := clientIP(r)
+	// Попытка занимается до медленной проверки пароля, чтобы параллельные запросы
+	// не успели перебрать пароли до блокировки.
+	if wait := s.throttle.reserve(req.Email, ip); wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(wait)))
+		writeError(w, http.StatusTooManyRequests, "too many failed login attempts, try again later")
+		return
+	}
+	u, found := s.store.findUserByEmail(req.Email)
+	if !found {
+		verifyPassword(dummyPasswordHash, req.Password)
 		writeError(w, http.StatusUnauthorized, "invalid credentials")
 		return
 	}
-	token, err := s.store.createToken(u.ID)
+	ok, needsRehash := verifyPassword(u.PasswordHash, req.Password)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "invalid credentials")
+		return
+	}
+	s.throttle.succeed(req.Email, ip)
+	if u.Archived {
+		writeError(w, http.StatusForbidden, "account is archived")
+		return
+	}
+	if needsRehash {
+		// Пароль известен только в момент входа, поэтому устаревший хеш обновляется здесь.
+		hash, err := hashPassword(req.Password)
+		if err == nil {
+			err = s.store.setPasswordHash(u.ID, hash)
+		}
+		if err != nil {
+			log.Printf("password rehash for user %d failed: %v", u.ID, err)
+		}
+	}
+	_, tokens, err := s.store.createSession(u.ID, requestClient(r), s.sessionTTL)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "failed to create token")
+		writeError(w, http.StatusInternalServerError, "failed to create session")
 		return
 	}
 	writeJSON(w, http.StatusOK, map[string]any{
-		"token": token,
-		"user":  u,
+		"token":            tokens.Token,
+		"refreshToken":     tokens.RefreshToken,
+		"expiresAt":        tokens.ExpiresAt,
+		"refreshExpiresAt": tokens.RefreshExpiresAt,
+		"user":             u,
 	})
 }
 
+// handleRefresh выдает новую пару токенов по refresh-токену.
+func (s *Server) handleRefresh(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	type request struct {
+		RefreshToken string `json:"refreshToken"`
+	}
+	var req request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	if strings.TrimSpace(req.RefreshToken) == "" {
+		writeError(w, http.StatusBadRequest, "refreshToken is required")
+		return
+	}
+	_, tokens, u, err := s.store.refreshSession(strings.TrimSpace(req.RefreshToken), requestClient(r), s.sessionTTL)
+	if errors.Is(err, errSessionNotFound) {
+		writeError(w, http.StatusUnauthorized, "invalid refresh token")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to refresh session")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"token":            tokens.Token,
+		"refreshToken":     tokens.RefreshToken,
+		"expiresAt":        tokens.ExpiresAt,
+		"refreshExpiresAt": tokens.RefreshExpiresAt,
+		"user":             u,
+	})
+}
+
+// handleLogout отзывает текущую сессию.
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request, _ User) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	session, _, ok := s.store.sessionByToken(bearerToken(r))
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+	if _, err := s.store.revokeSession(session.ID); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to revoke session")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "logged out"})
+}
+
+// sessionView — сессия в ответе API с отметкой текущей.
+type sessionView struct {
+	Session
+	Current bool `json:"current"`
+}
+
+// sessionViews готовит список сессий к выдаче, отмечая сессию с ID currentID.
+func sessionViews(sessions []Session, currentID string) []sessionView {
+	res := make([]sessionView, 0, len(sessions))
+	for _, session := range sessions {
+		res = append(res, sessionView{Session: session, Current: session.ID == currentID})
+	}
+	return res
+}
+
+// handleSessions возвращает активные сессии текущего пользователя.
+func (s *Server) handleSessions(w http.ResponseWriter, r *http.Request, user User) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	current, _, _ := s.store.sessionByToken(bearerToken(r))
+	writeJSON(w, http.StatusOK, sessionViews(s.store.listSessionsByUser(user.ID), current.ID))
+}
+
+// handleSessionByID завершает одну из сессий текущего пользователя.
+func (s *Server) handleSessionByID(w http.ResponseWriter, r *http.Request, user User) {
+	if r.Method != http.MethodDelete {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	id := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, "/api/sessions/"))
+	owned := false
+	for _, session := range s.store.listSessionsByUser(user.ID) {
+		if session.ID == id {
+			owned = true
+			break
+		}
+	}
+	if id == "" || !owned {
+		writeError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	if _, err := s.store.revokeSession(id); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to revoke session")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "revoked"})
+}
+
+// handleChangePassword меняет пароль текущего пользователя по старому паролю
+// и завершает все его сессии, кроме текущей.
+func (s *Server) handleChangePassword(w http.ResponseWriter, r *http.Request, user User) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	type request struct {
+		OldPassword string `json:"oldPassword"`
+		NewPassword string `json:"newPassword"`
+	}
+	var req request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	if req.OldPassword == "" || req.NewPassword == "" {
+		writeError(w, http.StatusBadRequest, "oldPassword and newPassword are required")
+		return
+	}
+	ip := clientIP(r)
+	if wait := s.throttle.reserve(user.Email, ip); wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(wait)))
+		writeError(w, http.StatusTooManyRequests, "too many failed attempts, try again later")
+		return
+	}
+	if ok, _ := verifyPassword(user.PasswordHash, req.OldPassword); !ok {
+		writeError(w, http.StatusForbidden, "old password is incorrect")
+		return
+	}
+	s.throttle.succeed(user.Email, ip)
+	hash, err := hashPassword(req.NewPassword)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to hash password")
+		return
+	}
+	current, _, _ := s.store.sessionByToken(bearerToken(r))
+	n, err := s.store.changePassword(user.ID, hash, current.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to change password")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"status": "password changed", "revokedSessions": n})
+}
+
+// handlePasswordReset устанавливает новый пароль по коду сброса, выданному администратором.
+func (s *Server) handlePasswordReset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	type request struct {
+		Email       string `json:"email"`
+		Code        string `json:"code"`
+		NewPassword string `json:"newPassword"`
+	}
+	var req request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	if req.Email == "" || req.Code == "" || req.NewPassword == "" {
+		writeError(w, http.StatusBadRequest, "email, code and newPassword are required")
+		return
+	}
+	ip := clientIP(r)
+	if wait := s.throttle.reserve(req.Email, ip); wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(wait)))
+		writeError(w, http.StatusTooManyRequests, "too many failed attempts, try again later")
+		return
+	}
+	hash, err := hashPassword(req.NewPassword)
+	if err != nil {
+		s.throttle.release(req.Email, ip)
+		writeError(w, http.StatusInternalServerError, "failed to hash password")
+		return
+	}
+	_, err = s.store.redeemPasswordReset(req.Email, req.Code, hash)
+	if errors.Is(err, errResetInvalid) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		s.throttle.release(req.Email, ip)
+		writeError(w, http.StatusInternalServerError, "failed to reset password")
+		return
+	}
+	s.throttle.succeed(req.Email, ip)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "password reset"})
+}
+
+// handleClasses возвращает реестр классов (используется и формой регистрации).
+func (s *Server) handleClasses(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.store.listClasses())
+}
+
+// handleSubjects возвращает справочник предметов.
+func (s *Server) handleSubjects(w http.ResponseWriter, r *http.Request, _ User) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.store.listSubjects())
+}
+
+// handleTerms возвращает учебные периоды; необязательный фильтр ?academicYear=.
+func (s *Server) handleTerms(w http.ResponseWriter, r *http.Request, _ User) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.store.listTerms(strings.TrimSpace(r.URL.Query().Get("academicYear"))))
+}
+
+// queryPeriod читает период из ?termId= (даты учебного периода) или ?from=&to=.
+// Без параметров и с currentTerm берется период, в который попадает сегодня; иначе
+// период пуст (без ограничения). При ошибке пишет ответ и возвращает false.
+func (s *Server) queryPeriod(w http.ResponseWriter, r *http.Request, currentTerm bool) (from, to string, ok bool) {
+	termID, ok := queryID(w, r, "termId")
+	if !ok {
+		return "", "", false
+	}
+	if termID != 0 {
+		term, found := s.store.getTerm(termID)
+		if !found {
+			writeError(w, http.StatusNotFound, "term not found")
+			return "", "", false
+		}
+		return term.StartDate, term.EndDate, true
+	}
+	from = strings.TrimSpace(r.URL.Query().Get("from"))
+	to = strings.TrimSpace(r.URL.Query().Get("to"))
+	for _, p := range [][2]string{{"from", from}, {"to", to}} {
+		if _, err := time.Parse("2006-01-02", p[1]); p[1] != "" && err != nil {
+			writeError(w, http.StatusBadRequest, p[0]+" must be YYYY-MM-DD")
+			return "", "", false
+		}
+	}
+	if from == "" && to == "" && currentTerm {
+		today := time.Now().Format("2006-01-02")
+		for _, term := range s.store.listTerms("") {
+			if term.StartDate <= today && today <= term.EndDate {
+				return term.StartDate, term.EndDate, true
+			}
+		}
+		writeError(w, http.StatusBadRequest, "termId or from and to are required")
+		return "", "", false
+	}
+	return from, to, true
+}
+
+// handleGradeTypes возвращает виды работ и их веса.
+func (s *Server) handleGradeTypes(w http.ResponseWriter, r *http.Request, _ User) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.store.listGradeTypes())
+}
+
+// handleGradingScales возвращает шкалы оценивания с подписями значений.
+func (s *Server) handleGradingScales(w http.ResponseWriter, r *http.Request, _ User) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.store.listScales())
+}
+
 // handleMe возвращает профиль текущего авторизованного пользователя.
 func (s *Server) handleMe(w http.ResponseWriter, _ *http.Request, user User) {
 	writeJSON(w, http.StatusOK, user)
@@ -1,28 +1,119 @@
 package main
 
-import "net/http"
+import (
+	"net/http"
+	"strings"
+	"time"
+)
 
-// handleStudentSchedule возвращает фото расписания для класса текущего ученика.
+// handleStudentSchedule возвращает расписание класса текущего ученика: уроки по дням
+// недели и времени начала (entries) и фото расписания, если оно загружено.
 func (s *Server) handleStudentSchedule(w http.ResponseWriter, r *http.Request, student User) {
 	if r.Method != http.MethodGet {
 		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
 		return
 	}
-	photo, ok := s.store.getSchedulePhotoByClass(student.ClassName)
+	type response struct {
+		SchedulePhoto
+		Entries []ScheduleEntry `json:"entries"`
+	}
+	res := response{SchedulePhoto: SchedulePhoto{ClassName: student.ClassName}, Entries: s.store.listScheduleByClass(student.ClassName)}
+	if photo, ok := s.store.getSchedulePhotoByClass(student.ClassName); ok {
+		res.SchedulePhoto = photo
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// handleStudentGrades возвращает оценки текущего ученика, средневзвешенные баллы по предметам
+// и шкалы оценивания для подписей значений.
+func (s *Server) handleStudentGrades(w http.ResponseWriter, r *http.Request, student User) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	grades := s.store.listGradesByStudent(student.ID)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"grades":   grades,
+		"averages": gradeAverages(s.store, grades),
+		"scales":   s.store.listScales(),
+	})
+}
+
+// handleStudentGradeRevisions возвращает историю исправлений оценок текущего ученика.
+// Необязательный фильтр: ?gradeId=.
+func (s *Server) handleStudentGradeRevisions(w http.ResponseWriter, r *http.Request, student User) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	gradeID, ok := queryID(w, r, "gradeId")
 	if !ok {
-		writeJSON(w, http.StatusOK, map[string]any{})
 		return
 	}
-	writeJSON(w, http.StatusOK, photo)
+	writeJSON(w, http.StatusOK, s.store.listGradeRevisions(gradeID, student.ID))
 }
 
-// handleStudentGrades возвращает оценки текущего ученика.
-func (s *Server) handleStudentGrades(w http.ResponseWriter, r *http.Request, student User) {
+// handleStudentTermGrades возвращает утвержденные итоговые оценки текущего ученика.
+// Необязательный фильтр: ?termId=.
+func (s *Server) handleStudentTermGrades(w http.ResponseWriter, r *http.Request, student User) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	termID, ok := queryID(w, r, "termId")
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, s.store.listTermGrades(termID, student.ID))
+}
+
+// handleStudentAttendance возвращает пропуски и опоздания текущего ученика (отметки
+// кроме present) и итоги за период (?termId= или ?from=&to=, без них — за все время).
+func (s *Server) handleStudentAttendance(w http.ResponseWriter, r *http.Request, student User) {
 	if r.Method != http.MethodGet {
 		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
 		return
 	}
-	writeJSON(w, http.StatusOK, s.store.listGradesByStudent(student.ID))
+	from, to, ok := s.queryPeriod(w, r, false)
+	if !ok {
+		return
+	}
+	absences := []Attendance{}
+	var totals AttendanceTotals
+	for _, a := range s.store.listAttendance(attendanceFilter{StudentID: student.ID, From: from, To: to}) {
+		totals.count(a.Status)
+		if a.Status != attendancePresent {
+			absences = append(absences, a)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"from":     from,
+		"to":       to,
+		"absences": absences,
+		"totals":   totals,
+	})
+}
+
+// handleStudentDiary возвращает дневник текущего ученика за неделю (?week=YYYY-Www, по
+// умолчанию текущая): уроки по дням с темами, заданиями, оценками и пропусками.
+func (s *Server) handleStudentDiary(w http.ResponseWriter, r *http.Request, student User) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	week := strings.TrimSpace(r.URL.Query().Get("week"))
+	if week == "" {
+		week = isoWeek(time.Now())
+	}
+	d, err := studentDiary(s.store, student.ID, week)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"diary":  d,
+		"scales": s.store.listScales(),
+	})
 }
 
 // handleStudentHomework возвращает домашние задания класса текущего ученика.
@@ -2,15 +2,114 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 )
 
-// handleTeacherScheduleCreate добавляет структурную запись урока.
-func (s *Server) handleTeacherScheduleCreate(w http.ResponseWriter, r *http.Request, teacher User) {
-	if r.Method != http.MethodPost {
+var (
+	// errNotYourClass — учитель не ведет предметы в классе, к которому обращается.
+	errNotYourClass = errors.New("class is not assigned to the teacher")
+	// errNotYourSubject — учитель ведет предметы в классе, но не этот.
+	errNotYourSubject = errors.New("subject is not assigned to the teacher in this class")
+)
+
+// assignedSubject выбирает предмет, который учитель ведет в классе className, и проверяет,
+// что есть закрепление ровно на эту пару (учитель, предмет, класс). requested может быть
+// названием, сокращением или синонимом из справочника; пустой requested допустим, только
+// если предмет у учителя в классе один. Администратор указывает любой предмет справочника.
+func (s *Server) assignedSubject(user User, requested, className string) (string, error) {
+	requested = strings.TrimSpace(requested)
+	if sub, ok := s.store.resolveSubject(requested); ok {
+		requested = sub.Name
+	}
+	if user.Role == RoleAdmin {
+		if requested == "" {
+			return "", errors.New("subject is required")
+		}
+		return requested, nil
+	}
+	className = normalizeClassName(className)
+	if className == "" {
+		return "", errors.New("className is required")
+	}
+	seen := map[string]bool{}
+	subjects := []string{}
+	for _, a := range s.store.listAssignments(user.ID, className) {
+		if !seen[a.Subject] {
+			seen[a.Subject] = true
+			subjects = append(subjects, a.Subject)
+		}
+	}
+	if len(subjects) == 0 {
+		return "", errNotYourClass
+	}
+	if requested == "" {
+		if len(subjects) == 1 {
+			return subjects[0], nil
+		}
+		return "", fmt.Errorf("subject is required, one of: %s", strings.Join(subjects, ", "))
+	}
+	for _, subject := range subjects {
+		if strings.EqualFold(subject, requested) {
+			return subject, nil
+		}
+	}
+	return "", errNotYourSubject
+}
+
+// writeAssignmentError отвечает на ошибку assignedSubject: 403 для чужого класса или
+// предмета, 400 для неполного запроса.
+func writeAssignmentError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errNotYourClass) || errors.Is(err, errNotYourSubject) {
+		writeError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	writeError(w, http.StatusBadRequest, err.Error())
+}
+
+// teachesSubjectTo проверяет закрепление учителя на предмет subject в классе ученика.
+func (s *Server) teachesSubjectTo(user User, subject string, student User) bool {
+	_, err := s.assignedSubject(user, subject, student.ClassName)
+	return err == nil
+}
+
+// teachesStudent проверяет, что за учителем закреплен хотя бы один предмет в классе ученика.
+// Это то же закрепление, по которому assignedSubject разрешает оценки и домашние задания.
+func (s *Server) teachesStudent(user, student User) bool {
+	if user.Role == RoleAdmin {
+		return true
+	}
+	return student.ClassName != "" && len(s.store.listAssignments(user.ID, student.ClassName)) > 0
+}
+
+// handleTeacherSchedule возвращает уроки учителя по расписанию (GET) или добавляет
+// структурную запись урока по закреплению учителя (POST). Администратор добавляет урок за
+// учителя, указав teacherId, и может посмотреть расписание учителя (?teacherId=) или всей школы.
+func (s *Server) handleTeacherSchedule(w http.ResponseWriter, r *http.Request, teacher User) {
+	switch r.Method {
+	case http.MethodGet:
+		teacherID := teacher.ID
+		if teacher.Role == RoleAdmin {
+			var ok bool
+			if teacherID, ok = queryID(w, r, "teacherId"); !ok {
+				return
+			}
+		}
+		if teacherID == 0 {
+			entries := s.store.listAllSchedule()
+			sortSchedule(entries)
+			writeJSON(w, http.StatusOK, entries)
+			return
+		}
+		writeJSON(w, http.StatusOK, s.store.listScheduleByTeacher(teacherID))
+		return
+	case http.MethodPost:
+		// handled below
+	default:
 		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
 		return
 	}
@@ -21,6 +120,7 @@ func (s *Server) handleTeacherScheduleCreate(w http.ResponseWriter, r *http.Requ
 		StartTime string `json:"startTime"`
 		EndTime   string `json:"endTime"`
 		Room      string `json:"room"`
+		TeacherID int64  `json:"teacherId"`
 	}
 	var req request
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -31,71 +131,119 @@ func (s *Server) handleTeacherScheduleCreate(w http.ResponseWriter, r *http.Requ
 		writeError(w, http.StatusBadRequest, "className, subject, weekday, startTime, endTime are required")
 		return
 	}
-	entry := s.store.addSchedule(ScheduleEntry{
+	lessonTeacher := teacher
+	if teacher.Role == RoleAdmin {
+		if req.TeacherID == 0 {
+			writeError(w, http.StatusBadRequest, "teacherId is required")
+			return
+		}
+		u, ok := s.store.getUser(req.TeacherID)
+		if !ok || u.Role != RoleTeacher {
+			writeError(w, http.StatusBadRequest, "teacher not found")
+			return
+		}
+		lessonTeacher = u
+	}
+	subject, err := s.assignedSubject(lessonTeacher, req.Subject, req.ClassName)
+	if err != nil {
+		if teacher.Role == RoleAdmin && (errors.Is(err, errNotYourClass) || errors.Is(err, errNotYourSubject)) {
+			writeError(w, http.StatusBadRequest, errNoAssignment.Error())
+			return
+		}
+		writeAssignmentError(w, err)
+		return
+	}
+	entry, err := s.store.addSchedule(ScheduleEntry{
 		ClassName: strings.TrimSpace(req.ClassName),
-		Subject:   strings.TrimSpace(req.Subject),
+		Subject:   subject,
 		Weekday:   strings.TrimSpace(req.Weekday),
 		StartTime: strings.TrimSpace(req.StartTime),
 		EndTime:   strings.TrimSpace(req.EndTime),
 		Room:      strings.TrimSpace(req.Room),
-		TeacherID: teacher.ID,
+		TeacherID: lessonTeacher.ID,
 	})
+	if errors.Is(err, errUnknownClass) || errors.Is(err, errUnknownSubject) || errors.Is(err, errNoAssignment) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to save schedule entry")
+		return
+	}
 	writeJSON(w, http.StatusCreated, entry)
 }
 
-// handleTeacherStudents возвращает список учеников, отсортированный по классу.
-func (s *Server) handleTeacherStudents(w http.ResponseWriter, r *http.Request, _ User) {
+// handleTeacherStudents возвращает учеников из классов учителя (администратору — всех),
+// отсортированных по классу.
+func (s *Server) handleTeacherStudents(w http.ResponseWriter, r *http.Request, teacher User) {
 	if r.Method != http.MethodGet {
 		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
 		return
 	}
-	writeJSON(w, http.StatusOK, s.store.listStudentsSortedByClass())
-}
-
-// handleTeacherSubject позволяет учителю закрепить за собой предмет.
-func (s *Server) handleTeacherSubject(w http.ResponseWriter, r *http.Request, teacher User) {
-	switch r.Method {
-	case http.MethodGet:
-		writeJSON(w, http.StatusOK, map[string]string{
-			"subject": s.store.getTeacherSubject(teacher.ID),
-		})
+	students := s.store.listStudentsSortedByClass()
+	if teacher.Role == RoleAdmin {
+		writeJSON(w, http.StatusOK, students)
 		return
-	case http.MethodPost:
-		type request struct {
-			Subject string `json:"subject"`
-		}
-		var req request
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			writeError(w, http.StatusBadRequest, "invalid json")
-			return
-		}
-		subject := strings.TrimSpace(req.Subject)
-		if subject == "" {
-			writeError(w, http.StatusBadRequest, "subject is required")
-			return
+	}
+	res := make([]User, 0, len(students))
+	for _, u := range students {
+		if s.teachesStudent(teacher, u) {
+			res = append(res, u)
 		}
-		subject = s.store.setTeacherSubject(teacher.ID, subject)
-		writeJSON(w, http.StatusOK, map[string]string{
-			"status":  "saved",
-			"subject": subject,
-		})
-		return
-	default:
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// handleTeacherAssignments возвращает закрепления учителя и список его предметов.
+func (s *Server) handleTeacherAssignments(w http.ResponseWriter, r *http.Request, teacher User) {
+	if r.Method != http.MethodGet {
 		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
 		return
 	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"assignments": s.store.listAssignments(teacher.ID, ""),
+		"subjects":    s.store.teacherSubjects(teacher.ID),
+	})
 }
 
-// handleTeacherGradesJournal возвращает оценки учителя по его предмету за диапазон дат.
+// applyLesson подставляет предмет (если он не указан) и дату урока из журнала уроков,
+// когда оценка выставляется за записанный урок. Возвращает false, если ответ уже отправлен.
+func (s *Server) applyLesson(w http.ResponseWriter, lessonID int64, subject, date *string) bool {
+	if lessonID == 0 {
+		return true
+	}
+	l, ok := s.store.getLesson(lessonID)
+	if !ok {
+		writeError(w, http.StatusNotFound, errLessonNotFound.Error())
+		return false
+	}
+	if strings.TrimSpace(*subject) == "" {
+		*subject = l.Subject
+	}
+	*date = l.Date
+	return true
+}
+
+// handleTeacherGradesJournal возвращает оценки учителя по одному из его предметов
+// (?subject=, можно не указывать, если предмет один) за диапазон дат и средневзвешенные
+// баллы учеников за этот период. Шкалы оценивания нужны клиенту, чтобы показать
+// подписи значений (scaleId оценки).
 func (s *Server) handleTeacherGradesJournal(w http.ResponseWriter, r *http.Request, teacher User) {
 	if r.Method != http.MethodGet {
 		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
 		return
 	}
-	subject := strings.TrimSpace(s.store.getTeacherSubject(teacher.ID))
+	subject := strings.TrimSpace(r.URL.Query().Get("subject"))
+	if sub, ok := s.store.resolveSubject(subject); ok {
+		subject = sub.Name
+	}
 	if subject == "" {
-		writeError(w, http.StatusBadRequest, "teacher subject is not set")
-		return
+		subjects := s.store.teacherSubjects(teacher.ID)
+		if len(subjects) != 1 {
+			writeError(w, http.StatusBadRequest, "subject is required")
+			return
+		}
+		subject = subjects[0]
 	}
 	from := strings.TrimSpace(r.URL.Query().Get("from"))
 	to := strings.TrimSpace(r.URL.Query().Get("to"))
@@ -113,8 +261,48 @@ func (s *Server) handleTeacherGradesJournal(w http.ResponseWriter, r *http.Reque
 	}
 	rows := s.store.listGradesByTeacherSubjectDateRange(teacher.ID, subject, from, to)
 	writeJSON(w, http.StatusOK, map[string]any{
-		"subject": subject,
-		"grades":  rows,
+		"subject":  subject,
+		"grades":   rows,
+		"averages": gradeAverages(s.store, rows),
+		"scales":   s.store.listScales(),
+	})
+}
+
+// handleTeacherJournal возвращает журнал класса по предмету в табличном виде
+// (GET ?className=&subject=&termId= или &from=&to=): ученики, даты уроков, оценки в
+// ячейках и средние баллы. Без периода берется учебный период, в который попадает сегодня.
+func (s *Server) handleTeacherJournal(w http.ResponseWriter, r *http.Request, teacher User) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	q := r.URL.Query()
+	className := normalizeClassName(q.Get("className"))
+	if className == "" {
+		writeError(w, http.StatusBadRequest, "className is required")
+		return
+	}
+	subject, err := s.assignedSubject(teacher, q.Get("subject"), className)
+	if err != nil {
+		writeAssignmentError(w, err)
+		return
+	}
+	from, to, ok := s.queryPeriod(w, r, true)
+	if !ok {
+		return
+	}
+	m, err := journalMatrix(s.store, className, subject, from, to)
+	if errors.Is(err, errUnknownClass) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"journal": m,
+		"scales":  s.store.listScales(),
 	})
 }
 
@@ -133,6 +321,10 @@ func (s *Server) handleTeacherGradeCreate(w http.ResponseWriter, r *http.Request
 			writeError(w, http.StatusBadRequest, "student not found")
 			return
 		}
+		if !s.teachesStudent(teacher, student) {
+			writeError(w, http.StatusForbidden, "student is not in the teacher's classes")
+			return
+		}
 		writeJSON(w, http.StatusOK, s.store.listGradesByStudent(studentID))
 		return
 	case http.MethodPost:
@@ -142,34 +334,34 @@ func (s *Server) handleTeacherGradeCreate(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	subject := strings.TrimSpace(s.store.getTeacherSubject(teacher.ID))
-	if subject == "" {
-		writeError(w, http.StatusBadRequest, "teacher subject is not set")
-		return
-	}
-
 	type request struct {
 		StudentID int64  `json:"studentId"`
+		Subject   string `json:"subject"`
 		Value     int    `json:"value"`
+		Type      string `json:"type"`
 		Comment   string `json:"comment"`
 		Date      string `json:"date"`
+		LessonID  int64  `json:"lessonId"`
 	}
 	var req request
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid json")
 		return
 	}
+	if !s.applyLesson(w, req.LessonID, &req.Subject, &req.Date) {
+		return
+	}
 
 	student, ok := s.store.getUser(req.StudentID)
 	if !ok || student.Role != RoleStudent {
 		writeError(w, http.StatusBadRequest, "student not found")
 		return
 	}
-	if req.Value < 1 || req.Value > 5 {
-		writeError(w, http.StatusBadRequest, "grade value 1..5 is required")
+	subject, err := s.assignedSubject(teacher, req.Subject, student.ClassName)
+	if err != nil {
+		writeAssignmentError(w, err)
 		return
 	}
-
 	date := strings.TrimSpace(req.Date)
 	if date == "" {
 		date = time.Now().Format("2006-01-02")
@@ -179,18 +371,605 @@ func (s *Server) handleTeacherGradeCreate(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	g := s.store.addGrade(Grade{
+	g, err := s.store.addGrade(Grade{
 		StudentID: req.StudentID,
 		Subject:   subject,
 		Value:     req.Value,
+		Type:      req.Type,
 		Comment:   strings.TrimSpace(req.Comment),
 		TeacherID: teacher.ID,
 		Date:      date,
+		LessonID:  req.LessonID,
 	})
+	if errors.Is(err, errUnknownSubject) || errors.Is(err, errUnknownGradeType) || errors.Is(err, errInvalidGradeValue) ||
+		errors.Is(err, errLessonMismatch) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if errors.Is(err, errTermLocked) {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to save grade")
+		return
+	}
 	writeJSON(w, http.StatusCreated, g)
 }
 
-// handleTeacherHomeworkCreate добавляет домашнее задание для класса.
+// handleTeacherGradesBatch выставляет оценки за урок нескольким ученикам сразу: общие
+// предмет, дата и вид работы, по строке на ученика. Сначала проверяются все строки;
+// при ошибках ничего не сохраняется и возвращается 422 со списком ошибочных строк.
+func (s *Server) handleTeacherGradesBatch(w http.ResponseWriter, r *http.Request, teacher User) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	type row struct {
+		StudentID int64  `json:"studentId"`
+		Value     int    `json:"value"`
+		Comment   string `json:"comment"`
+	}
+	type request struct {
+		Subject  string `json:"subject"`
+		Type     string `json:"type"`
+		Date     string `json:"date"`
+		LessonID int64  `json:"lessonId"`
+		Grades   []row  `json:"grades"`
+	}
+	var req request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	if len(req.Grades) == 0 {
+		writeError(w, http.StatusBadRequest, "grades are required")
+		return
+	}
+	if !s.applyLesson(w, req.LessonID, &req.Subject, &req.Date) {
+		return
+	}
+	// Предмет выбирается по классу урока, а без урока — по классу первого найденного ученика;
+	// закрепление на пару (предмет, класс) затем проверяется для каждой строки.
+	className := ""
+	if l, ok := s.store.getLesson(req.LessonID); ok {
+		className = l.ClassName
+	}
+	for _, row := range req.Grades {
+		if className != "" {
+			break
+		}
+		if student, ok := s.store.getUser(row.StudentID); ok && student.Role == RoleStudent {
+			className = student.ClassName
+		}
+	}
+	subject, err := s.assignedSubject(teacher, req.Subject, className)
+	if err != nil {
+		writeAssignmentError(w, err)
+		return
+	}
+	if _, err := normalizeGradeType(req.Type); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	date := strings.TrimSpace(req.Date)
+	if date == "" {
+		date = time.Now().Format("2006-01-02")
+	}
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		writeError(w, http.StatusBadRequest, "date must be YYYY-MM-DD")
+		return
+	}
+
+	rowErrors := []rowError{}
+	seen := make(map[int64]bool, len(req.Grades))
+	grades := make([]Grade, 0, len(req.Grades))
+	for i, row := range req.Grades {
+		student, ok := s.store.getUser(row.StudentID)
+		switch {
+		case !ok || student.Role != RoleStudent:
+			rowErrors = append(rowErrors, rowError{Row: i, StudentID: row.StudentID, Error: "student not found"})
+		case !s.teachesSubjectTo(teacher, subject, student):
+			rowErrors = append(rowErrors, rowError{Row: i, StudentID: row.StudentID, Error: errNotYourSubject.Error()})
+		case seen[row.StudentID]:
+			rowErrors = append(rowErrors, rowError{Row: i, StudentID: row.StudentID, Error: "student is listed twice"})
+		}
+		seen[row.StudentID] = true
+		grades = append(grades, Grade{
+			StudentID: row.StudentID,
+			Subject:   subject,
+			Value:     row.Value,
+			Type:      req.Type,
+			Comment:   strings.TrimSpace(row.Comment),
+			TeacherID: teacher.ID,
+			Date:      date,
+			LessonID:  req.LessonID,
+		})
+	}
+	if len(rowErrors) > 0 {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]any{"errors": rowErrors})
+		return
+	}
+
+	created, err := s.store.addGrades(grades)
+	var batchErr *batchError
+	if errors.As(err, &batchErr) {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]any{"errors": batchErr.Rows})
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to save grades")
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]any{
+		"subject": subject,
+		"date":    date,
+		"grades":  created,
+	})
+}
+
+// handleTeacherGradeByID исправляет (PUT) или удаляет (DELETE) оценку. Менять оценку
+// может только ее автор или администратор; причина обязательна и попадает в ревизию.
+func (s *Server) handleTeacherGradeByID(w http.ResponseWriter, r *http.Request, teacher User) {
+	if r.Method != http.MethodPut && r.Method != http.MethodDelete {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/api/teacher/grades/"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	g, ok := s.store.getGrade(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, "grade not found")
+		return
+	}
+	if teacher.Role != RoleAdmin && g.TeacherID != teacher.ID {
+		writeError(w, http.StatusForbidden, "only the grade's author can change it")
+		return
+	}
+
+	type request struct {
+		Value   *int    `json:"value"`
+		Type    *string `json:"type"`
+		Comment *string `json:"comment"`
+		Date    *string `json:"date"`
+		Reason  string  `json:"reason"`
+	}
+	var req request
+	if r.Method == http.MethodDelete {
+		// для DELETE тело необязательно, причину можно передать в ?reason=
+		req.Reason = r.URL.Query().Get("reason")
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeError(w, http.StatusBadRequest, "invalid json")
+				return
+			}
+		}
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		writeError(w, http.StatusBadRequest, "reason is required")
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		rev, err := s.store.deleteGrade(id, teacher.ID, reason)
+		if errors.Is(err, errGradeNotFound) {
+			writeError(w, http.StatusNotFound, "grade not found")
+			return
+		}
+		if errors.Is(err, errTermLocked) {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to delete grade")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"status": "deleted", "revision": rev})
+		return
+	}
+
+	if req.Value != nil {
+		g.Value = *req.Value
+	}
+	if req.Type != nil {
+		g.Type = *req.Type
+	}
+	if req.Comment != nil {
+		g.Comment = strings.TrimSpace(*req.Comment)
+	}
+	if req.Date != nil {
+		date := strings.TrimSpace(*req.Date)
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			writeError(w, http.StatusBadRequest, "date must be YYYY-MM-DD")
+			return
+		}
+		g.Date = date
+	}
+	updated, rev, err := s.store.updateGrade(g, teacher.ID, reason)
+	if errors.Is(err, errGradeNotFound) {
+		writeError(w, http.StatusNotFound, "grade not found")
+		return
+	}
+	if errors.Is(err, errUnknownGradeType) || errors.Is(err, errInvalidGradeValue) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if errors.Is(err, errTermLocked) {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to update grade")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"grade": updated, "revision": rev})
+}
+
+// handleTeacherTermGrades показывает предложенные итоговые оценки класса по предмету
+// за период (GET ?termId=&className=&subject=) и утверждает их (POST).
+func (s *Server) handleTeacherTermGrades(w http.ResponseWriter, r *http.Request, teacher User) {
+	switch r.Method {
+	case http.MethodGet:
+		termID, ok := queryID(w, r, "termId")
+		if !ok {
+			return
+		}
+		className := normalizeClassName(r.URL.Query().Get("className"))
+		if termID == 0 || className == "" {
+			writeError(w, http.StatusBadRequest, "termId and className are required")
+			return
+		}
+		subject, err := s.assignedSubject(teacher, r.URL.Query().Get("subject"), className)
+		if err != nil {
+			writeAssignmentError(w, err)
+			return
+		}
+		var ids []int64
+		for _, u := range s.store.listStudentsSortedByClass() {
+			if u.ClassName == className && !u.Archived {
+				ids = append(ids, u.ID)
+			}
+		}
+		proposals, err := termGradeProposals(s.store, termID, subject, ids)
+		if errors.Is(err, errUnknownTerm) {
+			writeError(w, http.StatusNotFound, "term not found")
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"termId":    termID,
+			"className": className,
+			"subject":   subject,
+			"rounding":  s.store.termRounding(),
+			"proposals": proposals,
+			"scales":    s.store.listScales(),
+		})
+		return
+	case http.MethodPost:
+		// handled below
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	type request struct {
+		TermID    int64  `json:"termId"`
+		StudentID int64  `json:"studentId"`
+		Subject   string `json:"subject"`
+		Value     int    `json:"value"`
+		Reason    string `json:"reason"`
+	}
+	var req request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	student, ok := s.store.getUser(req.StudentID)
+	if !ok || student.Role != RoleStudent {
+		writeError(w, http.StatusBadRequest, "student not found")
+		return
+	}
+	subject, err := s.assignedSubject(teacher, req.Subject, student.ClassName)
+	if err != nil {
+		writeAssignmentError(w, err)
+		return
+	}
+	tg, err := s.store.approveTermGrade(termGradeApproval{
+		TermID:    req.TermID,
+		StudentID: student.ID,
+		Subject:   subject,
+		Value:     req.Value,
+		Reason:    req.Reason,
+		TeacherID: teacher.ID,
+	})
+	switch {
+	case errors.Is(err, errUnknownTerm):
+		writeError(w, http.StatusNotFound, "term not found")
+	case errors.Is(err, errTermGradeExists):
+		writeError(w, http.StatusConflict, err.Error())
+	case err != nil:
+		writeError(w, http.StatusBadRequest, err.Error())
+	default:
+		writeJSON(w, http.StatusCreated, tg)
+	}
+}
+
+// handleTeacherAttendance показывает отметки посещаемости класса за урок предмета
+// (GET ?className=&subject=&date=, дата по умолчанию — сегодня) и сохраняет их (POST).
+// Вместо класса, предмета и даты можно указать lessonId из журнала уроков.
+// Ученики без отметки возвращаются с пустым status.
+func (s *Server) handleTeacherAttendance(w http.ResponseWriter, r *http.Request, teacher User) {
+	type mark struct {
+		StudentID int64  `json:"studentId"`
+		Status    string `json:"status"`
+		Comment   string `json:"comment"`
+	}
+	type request struct {
+		ClassName string `json:"className"`
+		Subject   string `json:"subject"`
+		Date      string `json:"date"`
+		LessonID  int64  `json:"lessonId"`
+		Marks     []mark `json:"marks"`
+	}
+	var req request
+	switch r.Method {
+	case http.MethodGet:
+		q := r.URL.Query()
+		req.ClassName, req.Subject, req.Date = q.Get("className"), q.Get("subject"), q.Get("date")
+		var ok bool
+		if req.LessonID, ok = queryID(w, r, "lessonId"); !ok {
+			return
+		}
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid json")
+			return
+		}
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if req.LessonID != 0 {
+		l, ok := s.store.getLesson(req.LessonID)
+		if !ok {
+			writeError(w, http.StatusNotFound, errLessonNotFound.Error())
+			return
+		}
+		req.ClassName, req.Subject, req.Date = l.ClassName, l.Subject, l.Date
+	}
+	className := normalizeClassName(req.ClassName)
+	if className == "" {
+		writeError(w, http.StatusBadRequest, "className is required")
+		return
+	}
+	subject, err := s.assignedSubject(teacher, req.Subject, className)
+	if err != nil {
+		writeAssignmentError(w, err)
+		return
+	}
+	date := strings.TrimSpace(req.Date)
+	if date == "" {
+		date = time.Now().Format("2006-01-02")
+	}
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		writeError(w, http.StatusBadRequest, "date must be YYYY-MM-DD")
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		sheet := attendanceSheet{ClassName: className, Subject: subject, Date: date, LessonID: req.LessonID, TeacherID: teacher.ID}
+		for _, m := range req.Marks {
+			sheet.Marks = append(sheet.Marks, Attendance{StudentID: m.StudentID, Status: m.Status, Comment: m.Comment})
+		}
+		marks, err := s.store.markAttendance(sheet)
+		var batchErr *batchError
+		switch {
+		case errors.As(err, &batchErr):
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]any{"errors": batchErr.Rows})
+		case errors.Is(err, errUnknownClass) || errors.Is(err, errLessonNotFound):
+			writeError(w, http.StatusNotFound, err.Error())
+		case err != nil:
+			writeError(w, http.StatusBadRequest, err.Error())
+		default:
+			writeJSON(w, http.StatusOK, map[string]any{"marks": marks})
+		}
+		return
+	}
+
+	type studentMark struct {
+		StudentID int64  `json:"studentId"`
+		FullName  string `json:"fullName"`
+		Status    string `json:"status"`
+		Comment   string `json:"comment,omitempty"`
+	}
+	marked := map[int64]Attendance{}
+	for _, a := range s.store.listAttendance(attendanceFilter{ClassName: className, Subject: subject, LessonID: req.LessonID, From: date, To: date}) {
+		marked[a.StudentID] = a
+	}
+	students := []studentMark{}
+	for _, u := range s.store.listStudentsSortedByClass() {
+		if u.ClassName == className {
+			students = append(students, studentMark{StudentID: u.ID, FullName: u.FullName, Status: marked[u.ID].Status, Comment: marked[u.ID].Comment})
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"className": className,
+		"subject":   subject,
+		"date":      date,
+		"lessonId":  req.LessonID,
+		"students":  students,
+	})
+}
+
+// handleTeacherLessons ведет журнал уроков: список записанных уроков (GET ?className=&subject=
+// и ?termId= или &from=&to=; без класса — уроки самого учителя) и запись проведенного урока (POST).
+func (s *Server) handleTeacherLessons(w http.ResponseWriter, r *http.Request, teacher User) {
+	switch r.Method {
+	case http.MethodGet:
+		q := r.URL.Query()
+		f := lessonFilter{ClassName: normalizeClassName(q.Get("className")), Subject: q.Get("subject")}
+		if f.ClassName == "" && teacher.Role != RoleAdmin {
+			f.TeacherID = teacher.ID
+		}
+		if f.ClassName != "" && teacher.Role != RoleAdmin {
+			subject, err := s.assignedSubject(teacher, f.Subject, f.ClassName)
+			if err != nil {
+				writeAssignmentError(w, err)
+				return
+			}
+			f.Subject = subject
+		}
+		var ok bool
+		if f.From, f.To, ok = s.queryPeriod(w, r, false); !ok {
+			return
+		}
+		writeJSON(w, http.StatusOK, s.store.listLessons(f))
+		return
+	case http.MethodPost:
+		// handled below
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	type request struct {
+		ClassName  string `json:"className"`
+		Subject    string `json:"subject"`
+		Date       string `json:"date"`
+		Period     int    `json:"period"`
+		Topic      string `json:"topic"`
+		HomeworkID int64  `json:"homeworkId"`
+	}
+	var req request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	className := normalizeClassName(req.ClassName)
+	if className == "" {
+		writeError(w, http.StatusBadRequest, "className is required")
+		return
+	}
+	subject, err := s.assignedSubject(teacher, req.Subject, className)
+	if err != nil {
+		writeAssignmentError(w, err)
+		return
+	}
+	date := strings.TrimSpace(req.Date)
+	if date == "" {
+		date = time.Now().Format("2006-01-02")
+	}
+	l, err := s.store.createLesson(Lesson{
+		ClassName:  className,
+		Subject:    subject,
+		Date:       date,
+		Period:     req.Period,
+		Topic:      req.Topic,
+		HomeworkID: req.HomeworkID,
+		TeacherID:  teacher.ID,
+	})
+	if errors.Is(err, errLessonExists) {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, l)
+}
+
+// handleTeacherLessonByID показывает (GET), исправляет (PATCH: period, topic, homeworkId)
+// или удаляет (DELETE) запись урока. Менять запись может только ее автор или администратор.
+func (s *Server) handleTeacherLessonByID(w http.ResponseWriter, r *http.Request, teacher User) {
+	id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/api/teacher/lessons/"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	l, ok := s.store.getLesson(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, errLessonNotFound.Error())
+		return
+	}
+	if _, err := s.assignedSubject(teacher, l.Subject, l.ClassName); err != nil {
+		writeAssignmentError(w, err)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, l)
+		return
+	case http.MethodPatch, http.MethodDelete:
+		if teacher.Role != RoleAdmin && l.TeacherID != teacher.ID {
+			writeError(w, http.StatusForbidden, "only the lesson's author can change it")
+			return
+		}
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		_, err := s.store.deleteLesson(id, teacher.ID)
+		if errors.Is(err, errTermLocked) {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to delete lesson")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+		return
+	}
+
+	type request struct {
+		Period     *int    `json:"period"`
+		Topic      *string `json:"topic"`
+		HomeworkID *int64  `json:"homeworkId"`
+	}
+	var req request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	if req.Period != nil {
+		l.Period = *req.Period
+	}
+	if req.Topic != nil {
+		l.Topic = *req.Topic
+	}
+	if req.HomeworkID != nil {
+		l.HomeworkID = *req.HomeworkID
+	}
+	updated, err := s.store.updateLesson(l)
+	if errors.Is(err, errLessonNotFound) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if errors.Is(err, errLessonExists) {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, updated)
+}
+
+// handleTeacherHomeworkCreate добавляет домашнее задание для класса учителя.
 func (s *Server) handleTeacherHomeworkCreate(w http.ResponseWriter, r *http.Request, teacher User) {
 	if r.Method != http.MethodPost {
 		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
@@ -211,12 +990,25 @@ func (s *Server) handleTeacherHomeworkCreate(w http.ResponseWriter, r *http.Requ
 		writeError(w, http.StatusBadRequest, "className, subject, description, dueDate are required")
 		return
 	}
-	hw := s.store.addHomework(Homework{
+	subject, err := s.assignedSubject(teacher, req.Subject, req.ClassName)
+	if err != nil {
+		writeAssignmentError(w, err)
+		return
+	}
+	hw, err := s.store.addHomework(Homework{
 		ClassName:   strings.TrimSpace(req.ClassName),
-		Subject:     strings.TrimSpace(req.Subject),
+		Subject:     subject,
 		Description: strings.TrimSpace(req.Description),
 		DueDate:     strings.TrimSpace(req.DueDate),
 		TeacherID:   teacher.ID,
 	})
+	if errors.Is(err, errUnknownClass) || errors.Is(err, errUnknownSubject) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to save homework")
+		return
+	}
 	writeJSON(w, http.StatusCreated, hw)
 }
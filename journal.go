@@ -0,0 +1,668 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	journalFileName  = "journal.jsonl"
+	snapshotFileName = "snapshot.jsonl"
+
+	// snapshotEvery — сколько записей журнала копится до очередного снимка.
+	snapshotEvery = 1000
+)
+
+// Операции журнала изменений хранилища.
+const (
+	opSnapshot           = "snapshot"
+	opCounters           = "counters"
+	opPutUser            = "putUser"
+	opDeleteUser         = "deleteUser"
+	opPutSession         = "putSession"
+	opDeleteSession      = "deleteSession"
+	opPruneSessions      = "pruneSessions"
+	opRevokeUserSessions = "revokeUserSessions"
+	opPutSchedule        = "putSchedule"
+	opReplaceSchedule    = "replaceSchedule"
+	opClearSchedule      = "clearSchedule"
+	opPutPhoto           = "putPhoto"
+	opPutGrade           = "putGrade"
+	opPutGrades          = "putGrades"
+	opPutHomework        = "putHomework"
+	opPutInvite          = "putInvite"
+	opDeleteInvite       = "deleteInvite"
+	opRedeemInvite       = "redeemInvite"
+	opPutPasswordReset   = "putPasswordReset"
+	opChangePassword     = "changePassword"
+	opUpdateUser         = "updateUser"
+	opPutAudit           = "putAudit"
+	opPutUsers           = "putUsers"
+	opPutClass           = "putClass"
+	opDeleteClass        = "deleteClass"
+	opRollover           = "rollover"
+	opPutArchive         = "putArchive"
+	opPutAssignment      = "putAssignment"
+	opDeleteAssignment   = "deleteAssignment"
+	opPutSubject         = "putSubject"
+	opDeleteSubject      = "deleteSubject"
+	opMigrateSubjects    = "migrateSubjects"
+	opUpdateGrade        = "updateGrade"
+	opDeleteGrade        = "deleteGrade"
+	opPutGradeRevision   = "putGradeRevision"
+	opSetGradeWeight     = "setGradeWeight"
+	opPutTerm            = "putTerm"
+	opDeleteTerm         = "deleteTerm"
+	opSetTermRounding    = "setTermRounding"
+	opPutTermGrade       = "putTermGrade"
+	opDeleteTermGrade    = "deleteTermGrade"
+	opPutScale           = "putScale"
+	opDeleteScale        = "deleteScale"
+	opPutAttendance      = "putAttendance"
+	opPutLesson          = "putLesson"
+	opDeleteLesson       = "deleteLesson"
+)
+
+// persistedUser — пользователь вместе с хешем пароля для записи на диск.
+type persistedUser struct {
+	User
+	PasswordHash string `json:"passwordHash"`
+}
+
+// user восстанавливает пользователя из записи журнала.
+func (p *persistedUser) user() User {
+	u := p.User
+	u.PasswordHash = p.PasswordHash
+	return u
+}
+
+// persistedSession — сессия вместе с хешами токенов для записи на диск.
+type persistedSession struct {
+	Session
+	TokenHash   string `json:"tokenHash"`
+	RefreshHash string `json:"refreshHash"`
+}
+
+// persistSession готовит сессию к записи в журнал.
+func persistSession(session Session) *persistedSession {
+	return &persistedSession{Session: session, TokenHash: session.TokenHash, RefreshHash: session.RefreshHash}
+}
+
+// session восстанавливает сессию из записи журнала.
+func (p *persistedSession) session() Session {
+	session := p.Session
+	session.TokenHash = p.TokenHash
+	session.RefreshHash = p.RefreshHash
+	return session
+}
+
+// persistedInvite — приглашение вместе с хешем кода для записи на диск.
+type persistedInvite struct {
+	Invite
+	CodeHash string `json:"codeHash"`
+}
+
+// persistInvite готовит приглашение к записи в журнал.
+func persistInvite(inv Invite) *persistedInvite {
+	return &persistedInvite{Invite: inv, CodeHash: inv.CodeHash}
+}
+
+// invite восстанавливает приглашение из записи журнала.
+func (p *persistedInvite) invite() Invite {
+	inv := p.Invite
+	inv.CodeHash = p.CodeHash
+	return inv
+}
+
+// persistedPasswordReset — код сброса пароля вместе с хешем для записи на диск.
+type persistedPasswordReset struct {
+	PasswordReset
+	CodeHash string `json:"codeHash"`
+}
+
+// persistPasswordReset готовит код сброса к записи в журнал.
+func persistPasswordReset(reset PasswordReset) *persistedPasswordReset {
+	return &persistedPasswordReset{PasswordReset: reset, CodeHash: reset.CodeHash}
+}
+
+// passwordReset восстанавливает код сброса из записи журнала.
+func (p *persistedPasswordReset) passwordReset() PasswordReset {
+	reset := p.PasswordReset
+	reset.CodeHash = p.CodeHash
+	return reset
+}
+
+// storageCounters — счетчики идентификаторов, сохраняемые в снимке.
+type storageCounters struct {
+	User          int64 `json:"user"`
+	Schedule      int64 `json:"schedule"`
+	Grade         int64 `json:"grade"`
+	Homework      int64 `json:"homework"`
+	Invite        int64 `json:"invite"`
+	Audit         int64 `json:"audit"`
+	Assignment    int64 `json:"assignment"`
+	Subject       int64 `json:"subject"`
+	GradeRevision int64 `json:"gradeRevision"`
+	Term          int64 `json:"term"`
+	TermGrade     int64 `json:"termGrade"`
+	Scale         int64 `json:"scale"`
+	Attendance    int64 `json:"attendance"`
+	Lesson        int64 `json:"lesson"`
+}
+
+// journalRecord — одна операция изменения хранилища.
+type journalRecord struct {
+	Seq       int64      `json:"seq,omitempty"`
+	Op        string     `json:"op"`
+	ID        int64      `json:"id,omitempty"`
+	SessionID string     `json:"sessionId,omitempty"`
+	Token     string     `json:"token,omitempty"`
+	ClassName string     `json:"className,omitempty"`
+	Time      *time.Time `json:"time,omitempty"`
+
+	User             *persistedUser          `json:"user,omitempty"`
+	Users            []persistedUser         `json:"users,omitempty"`
+	Session          *persistedSession       `json:"session,omitempty"`
+	Schedule         []ScheduleEntry         `json:"schedule,omitempty"`
+	Photo            *SchedulePhoto          `json:"photo,omitempty"`
+	Grade            *Grade                  `json:"grade,omitempty"`
+	Grades           []Grade                 `json:"grades,omitempty"`
+	GradeRevision    *GradeRevision          `json:"gradeRevision,omitempty"`
+	GradeRevisions   []GradeRevision         `json:"gradeRevisions,omitempty"`
+	GradeType        *GradeType              `json:"gradeType,omitempty"`
+	Term             *Term                   `json:"term,omitempty"`
+	TermGrade        *TermGrade              `json:"termGrade,omitempty"`
+	Rounding         *TermRounding           `json:"rounding,omitempty"`
+	Scale            *GradingScale           `json:"scale,omitempty"`
+	Attendance       []Attendance            `json:"attendance,omitempty"`
+	Lesson           *Lesson                 `json:"lesson,omitempty"`
+	Homework         *Homework               `json:"homework,omitempty"`
+	Invite           *persistedInvite        `json:"invite,omitempty"`
+	Reset            *persistedPasswordReset `json:"reset,omitempty"`
+	Audit            *AuditEntry             `json:"audit,omitempty"`
+	Class            *Class                  `json:"class,omitempty"`
+	Rollover         *rolloverParams         `json:"rollover,omitempty"`
+	Archive          *YearArchive            `json:"archive,omitempty"`
+	Assignment       *Assignment             `json:"assignment,omitempty"`
+	SubjectEntry     *Subject                `json:"subjectEntry,omitempty"`
+	SubjectMigration *subjectMigrationParams `json:"subjectMigration,omitempty"`
+	Counters         *storageCounters        `json:"counters,omitempty"`
+}
+
+// journal — append-only файл операций и снимок состояния в каталоге данных.
+type journal struct {
+	dir     string
+	file    *os.File
+	size    int64
+	pending int
+}
+
+// openJournal открывает (или создает) журнал в каталоге dir.
+func openJournal(dir string) (*journal, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, journalFileName), os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &journal{dir: dir, file: f}, nil
+}
+
+// append дописывает запись в конец журнала и дожидается сброса на диск.
+func (j *journal) append(rec journalRecord) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	if _, err := j.file.WriteAt(line, j.size); err != nil {
+		// Отрезаем недописанный хвост, чтобы следующие записи не легли после мусора.
+		_ = j.file.Truncate(j.size)
+		return err
+	}
+	if err := j.file.Sync(); err != nil {
+		_ = j.file.Truncate(j.size)
+		return err
+	}
+	j.size += int64(len(line))
+	j.pending++
+	return nil
+}
+
+// shouldSnapshot сообщает, что в журнале накопилось snapshotEvery записей.
+func (j *journal) shouldSnapshot() bool {
+	return j.pending >= snapshotEvery
+}
+
+// snapshot атомарно заменяет снимок и очищает журнал.
+func (j *journal) snapshot(records []journalRecord) error {
+	path := filepath.Join(j.dir, snapshotFileName)
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, rec := range records {
+		if err := enc.Encode(rec); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	syncDir(j.dir)
+
+	// Записи журнала до этого момента уже вошли в снимок; если процесс упадет
+	// до очистки, при чтении их отсечет номер последовательности снимка.
+	if err := j.file.Truncate(0); err != nil {
+		return err
+	}
+	if err := j.file.Sync(); err != nil {
+		return err
+	}
+	j.size = 0
+	j.pending = 0
+	return nil
+}
+
+// close закрывает файл журнала.
+func (j *journal) close() error {
+	return j.file.Close()
+}
+
+// syncDir сбрасывает на диск метаданные каталога (на Windows не поддерживается).
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	_ = d.Sync()
+	_ = d.Close()
+}
+
+// readJournalFile читает записи из файла и возвращает длину корректной части.
+// Недописанная последняя строка (сбой во время записи) отбрасывается.
+func readJournalFile(path string, fn func(journalRecord) error) (int64, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var offset int64
+	for {
+		line, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(bytes.TrimSpace(line)) > 0 {
+				log.Printf("journal %s: dropping incomplete tail at offset %d", path, offset)
+			}
+			return offset, nil
+		}
+		if err != nil {
+			return offset, err
+		}
+		if len(bytes.TrimSpace(line)) > 0 {
+			var rec journalRecord
+			if err := json.Unmarshal(line, &rec); err != nil {
+				return offset, fmt.Errorf("journal %s: corrupted record at offset %d: %w", path, offset, err)
+			}
+			if err := fn(rec); err != nil {
+				return offset, err
+			}
+		}
+		offset += int64(len(line))
+	}
+}
+
+// load передает apply записи снимка и затем журнала, пропуская записи журнала, уже
+// вошедшие в снимок, и отрезает недописанный хвост журнала.
+func (j *journal) load(apply func(journalRecord) error) (int64, error) {
+	var seq, snapshotSeq int64
+	first := true
+	_, err := readJournalFile(filepath.Join(j.dir, snapshotFileName), func(rec journalRecord) error {
+		if first {
+			first = false
+			if rec.Op != opSnapshot {
+				return errors.New("snapshot: missing header")
+			}
+			snapshotSeq = rec.Seq
+			seq = rec.Seq
+			return nil
+		}
+		return apply(rec)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	size, err := readJournalFile(filepath.Join(j.dir, journalFileName), func(rec journalRecord) error {
+		j.pending++
+		if rec.Seq <= snapshotSeq {
+			return nil
+		}
+		seq = rec.Seq
+		return apply(rec)
+	})
+	if err != nil {
+		return 0, err
+	}
+	if err := j.file.Truncate(size); err != nil {
+		return 0, err
+	}
+	j.size = size
+	return seq, nil
+}
+
+// commitLocked записывает операцию в журнал и применяет ее к памяти.
+// Вызывающий должен держать s.mu на запись.
+func (s *Storage) commitLocked(rec journalRecord) error {
+	if s.journal == nil {
+		return errStorageClosed
+	}
+	rec.Seq = s.seq + 1
+	if err := s.journal.append(rec); err != nil {
+		return fmt.Errorf("journal write failed: %w", err)
+	}
+	s.seq = rec.Seq
+	if err := s.applyLocked(rec); err != nil {
+		return err
+	}
+	if s.journal.shouldSnapshot() {
+		if err := s.journal.snapshot(s.dumpLocked()); err != nil {
+			log.Printf("snapshot error: %v", err)
+		}
+	}
+	return nil
+}
+
+// bumpCounter сдвигает счетчик ID так, чтобы он был больше id.
+func bumpCounter(next *int64, id int64) {
+	if id >= *next {
+		*next = id + 1
+	}
+}
+
+// applyLocked применяет операцию журнала к данным в памяти.
+func (s *Storage) applyLocked(rec journalRecord) error {
+	switch rec.Op {
+	case opCounters:
+		if rec.Counters != nil {
+			s.nextUserID = max(s.nextUserID, rec.Counters.User)
+			s.nextScheduleID = max(s.nextScheduleID, rec.Counters.Schedule)
+			s.nextGradeID = max(s.nextGradeID, rec.Counters.Grade)
+			s.nextHomeworkID = max(s.nextHomeworkID, rec.Counters.Homework)
+			s.nextInviteID = max(s.nextInviteID, rec.Counters.Invite)
+			s.nextAuditID = max(s.nextAuditID, rec.Counters.Audit)
+			s.nextAssignmentID = max(s.nextAssignmentID, rec.Counters.Assignment)
+			s.nextSubjectID = max(s.nextSubjectID, rec.Counters.Subject)
+			s.nextGradeRevisionID = max(s.nextGradeRevisionID, rec.Counters.GradeRevision)
+			s.nextTermID = max(s.nextTermID, rec.Counters.Term)
+			s.nextTermGradeID = max(s.nextTermGradeID, rec.Counters.TermGrade)
+			s.nextScaleID = max(s.nextScaleID, rec.Counters.Scale)
+			s.nextAttendanceID = max(s.nextAttendanceID, rec.Counters.Attendance)
+			s.nextLessonID = max(s.nextLessonID, rec.Counters.Lesson)
+		}
+	case opPutUser:
+		s.putUserLocked(rec.User.user())
+	case opPutInvite:
+		s.putInviteLocked(rec.Invite.invite())
+	case opDeleteInvite:
+		s.deleteInviteLocked(rec.ID)
+	case opRedeemInvite:
+		s.putUserLocked(rec.User.user())
+		s.putInviteLocked(rec.Invite.invite())
+	case opPutPasswordReset:
+		reset := rec.Reset.passwordReset()
+		s.resets[reset.UserID] = reset
+	case opChangePassword:
+		// Смена пароля завершает все сессии, кроме SessionID, и гасит код сброса.
+		u := rec.User.user()
+		s.putUserLocked(u)
+		delete(s.resets, u.ID)
+		for id, session := range s.sessions {
+			if session.UserID == u.ID && id != rec.SessionID {
+				s.deleteSessionLocked(id)
+			}
+		}
+	case opPutUsers:
+		for i := range rec.Users {
+			s.putUserLocked(rec.Users[i].user())
+		}
+	case opPutClass:
+		s.classes[rec.Class.Name] = *rec.Class
+	case opDeleteClass:
+		delete(s.classes, rec.ClassName)
+	case opRollover:
+		s.rolloverLocked(*rec.Rollover)
+		s.putAuditLocked(*rec.Audit)
+	case opPutArchive:
+		s.archives[archiveKey(rec.Archive.AcademicYear)] = *rec.Archive
+	case opUpdateUser:
+		u := rec.User.user()
+		prev := s.users[u.ID]
+		s.putUserLocked(u)
+		if prev.Role != u.Role {
+			s.revokeUserSessionsLocked(u.ID)
+		}
+		if u.Role != RoleTeacher {
+			s.dropTeacherRoleLocked(u.ID)
+		}
+		s.putAuditLocked(*rec.Audit)
+	case opPutAudit:
+		s.putAuditLocked(*rec.Audit)
+	case opDeleteUser:
+		s.deleteUserLocked(rec.ID)
+	case opPutSession:
+		s.putSessionLocked(rec.Session.session())
+	case opDeleteSession:
+		s.deleteSessionLocked(rec.SessionID)
+	case opRevokeUserSessions:
+		s.revokeUserSessionsLocked(rec.ID)
+	case opPruneSessions:
+		s.pruneSessionsLocked(*rec.Time)
+	case opPutAssignment:
+		s.putAssignmentLocked(*rec.Assignment)
+	case opDeleteAssignment:
+		delete(s.assignments, rec.ID)
+	case opPutSubject:
+		s.putSubjectLocked(*rec.SubjectEntry)
+	case opDeleteSubject:
+		delete(s.subjects, rec.ID)
+	case opMigrateSubjects:
+		s.migrateSubjectsLocked(*rec.SubjectMigration)
+	case opPutSchedule:
+		for _, entry := range rec.Schedule {
+			s.schedule[entry.ID] = entry
+			bumpCounter(&s.nextScheduleID, entry.ID)
+		}
+	case opReplaceSchedule:
+		s.schedule = make(map[int64]ScheduleEntry)
+		s.nextScheduleID = 1
+		for _, entry := range rec.Schedule {
+			s.schedule[entry.ID] = entry
+			bumpCounter(&s.nextScheduleID, entry.ID)
+		}
+	case opClearSchedule:
+		s.schedule = make(map[int64]ScheduleEntry)
+		s.photos = make(map[string]SchedulePhoto)
+		s.nextScheduleID = 1
+	case opPutPhoto:
+		s.photos[rec.Photo.ClassName] = *rec.Photo
+	case opPutGrade:
+		s.putGradeLocked(*rec.Grade)
+	case opPutGrades:
+		for _, g := range rec.Grades {
+			s.putGradeLocked(g)
+		}
+	case opSetGradeWeight:
+		s.gradeWeights[rec.GradeType.Code] = rec.GradeType.Weight
+	case opPutTerm:
+		s.putTermLocked(*rec.Term)
+	case opDeleteTerm:
+		delete(s.terms, rec.ID)
+	case opSetTermRounding:
+		s.rounding = *rec.Rounding
+	case opPutTermGrade:
+		s.putTermGradeLocked(*rec.TermGrade)
+	case opDeleteTermGrade:
+		delete(s.termGrades, rec.ID)
+	case opPutScale:
+		s.putScaleLocked(*rec.Scale)
+	case opDeleteScale:
+		delete(s.scales, rec.ID)
+	case opPutAttendance:
+		for _, a := range rec.Attendance {
+			s.putAttendanceLocked(a)
+		}
+	case opPutLesson:
+		s.putLessonLocked(*rec.Lesson)
+	case opDeleteLesson:
+		s.deleteLessonLocked(rec.ID)
+		for _, rev := range rec.GradeRevisions {
+			s.putGradeRevisionLocked(rev)
+		}
+	case opUpdateGrade:
+		s.grades[rec.Grade.ID] = *rec.Grade
+		s.putGradeRevisionLocked(*rec.GradeRevision)
+	case opDeleteGrade:
+		delete(s.grades, rec.ID)
+		s.putGradeRevisionLocked(*rec.GradeRevision)
+	case opPutGradeRevision:
+		s.putGradeRevisionLocked(*rec.GradeRevision)
+	case opPutHomework:
+		s.homework[rec.Homework.ID] = *rec.Homework
+		bumpCounter(&s.nextHomeworkID, rec.Homework.ID)
+	default:
+		return fmt.Errorf("journal: unknown op %q", rec.Op)
+	}
+	return nil
+}
+
+// dumpLocked представляет текущее состояние набором записей для снимка.
+func (s *Storage) dumpLocked() []journalRecord {
+	res := []journalRecord{{Seq: s.seq, Op: opSnapshot}}
+	for _, sc := range s.scales {
+		sc := sc
+		res = append(res, journalRecord{Op: opPutScale, Scale: &sc})
+	}
+	for _, c := range s.classes {
+		c := c
+		res = append(res, journalRecord{Op: opPutClass, Class: &c})
+	}
+	for _, sub := range s.subjects {
+		sub := sub
+		res = append(res, journalRecord{Op: opPutSubject, SubjectEntry: &sub})
+	}
+	for _, a := range s.archives {
+		a := a
+		res = append(res, journalRecord{Op: opPutArchive, Archive: &a})
+	}
+	for _, u := range s.users {
+		res = append(res, journalRecord{Op: opPutUser, User: &persistedUser{User: u, PasswordHash: u.PasswordHash}})
+	}
+	for _, session := range s.sessions {
+		res = append(res, journalRecord{Op: opPutSession, Session: persistSession(session)})
+	}
+	for _, inv := range s.invites {
+		res = append(res, journalRecord{Op: opPutInvite, Invite: persistInvite(inv)})
+	}
+	for _, reset := range s.resets {
+		res = append(res, journalRecord{Op: opPutPasswordReset, Reset: persistPasswordReset(reset)})
+	}
+	for _, entry := range s.audit {
+		entry := entry
+		res = append(res, journalRecord{Op: opPutAudit, Audit: &entry})
+	}
+	for _, a := range s.assignments {
+		a := a
+		res = append(res, journalRecord{Op: opPutAssignment, Assignment: &a})
+	}
+	if schedule := s.listAllScheduleLocked(); len(schedule) > 0 {
+		res = append(res, journalRecord{Op: opPutSchedule, Schedule: schedule})
+	}
+	for _, photo := range s.photos {
+		photo := photo
+		res = append(res, journalRecord{Op: opPutPhoto, Photo: &photo})
+	}
+	for _, g := range s.grades {
+		g := g
+		res = append(res, journalRecord{Op: opPutGrade, Grade: &g})
+	}
+	rounding := s.rounding
+	res = append(res, journalRecord{Op: opSetTermRounding, Rounding: &rounding})
+	for _, t := range s.terms {
+		t := t
+		res = append(res, journalRecord{Op: opPutTerm, Term: &t})
+	}
+	for _, tg := range s.termGrades {
+		tg := tg
+		res = append(res, journalRecord{Op: opPutTermGrade, TermGrade: &tg})
+	}
+	for code, weight := range s.gradeWeights {
+		t := GradeType{Code: code, Weight: weight}
+		res = append(res, journalRecord{Op: opSetGradeWeight, GradeType: &t})
+	}
+	for _, rev := range s.gradeRevisions {
+		rev := rev
+		res = append(res, journalRecord{Op: opPutGradeRevision, GradeRevision: &rev})
+	}
+	for _, hw := range s.homework {
+		hw := hw
+		res = append(res, journalRecord{Op: opPutHomework, Homework: &hw})
+	}
+	for _, l := range s.lessons {
+		l := l
+		res = append(res, journalRecord{Op: opPutLesson, Lesson: &l})
+	}
+	if len(s.attendance) > 0 {
+		attendance := make([]Attendance, 0, len(s.attendance))
+		for _, a := range s.attendance {
+			attendance = append(attendance, a)
+		}
+		res = append(res, journalRecord{Op: opPutAttendance, Attendance: attendance})
+	}
+	res = append(res, journalRecord{Op: opCounters, Counters: &storageCounters{
+		User:          s.nextUserID,
+		Schedule:      s.nextScheduleID,
+		Grade:         s.nextGradeID,
+		Homework:      s.nextHomeworkID,
+		Invite:        s.nextInviteID,
+		Audit:         s.nextAuditID,
+		Assignment:    s.nextAssignmentID,
+		Subject:       s.nextSubjectID,
+		GradeRevision: s.nextGradeRevisionID,
+		Term:          s.nextTermID,
+		TermGrade:     s.nextTermGradeID,
+		Scale:         s.nextScaleID,
+		Attendance:    s.nextAttendanceID,
+		Lesson:        s.nextLessonID,
+	}})
+	return res
+}
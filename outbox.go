@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// outboxMessage — сообщение пользователю (код сброса пароля и т.п.).
+type outboxMessage struct {
+	To        string    `json:"to"`
+	Subject   string    `json:"subject"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Outbox доставляет сообщения пользователям. Реализация выбирается через OUTBOX.
+type Outbox interface {
+	send(msg outboxMessage) error
+}
+
+// fileOutbox дописывает сообщения в JSONL-файл; подходит для локальной разработки.
+type fileOutbox struct {
+	mu   sync.Mutex
+	path string
+}
+
+// send дописывает сообщение в конец файла.
+func (o *fileOutbox) send(msg outboxMessage) error {
+	line, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(o.path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(o.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// logOutbox печатает сообщения в лог сервера.
+type logOutbox struct{}
+
+// send выводит сообщение в лог.
+func (logOutbox) send(msg outboxMessage) error {
+	log.Printf("outbox: to=%s subject=%q\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// outboxBackends — зарегистрированные способы доставки; path — файл для файловой реализации.
+var outboxBackends = map[string]func(path string) Outbox{
+	"file": func(path string) Outbox { return &fileOutbox{path: path} },
+	"log":  func(string) Outbox { return logOutbox{} },
+}
+
+// openOutbox создает outbox выбранного типа.
+func openOutbox(kind, path string) (Outbox, error) {
+	open, ok := outboxBackends[kind]
+	if !ok {
+		names := make([]string, 0, len(outboxBackends))
+		for name := range outboxBackends {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown outbox %q (available: %v)", kind, names)
+	}
+	return open(path), nil
+}
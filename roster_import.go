@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+const (
+	// rosterMaxRows — предел числа строк в одном CSV-импорте.
+	rosterMaxRows = 5000
+	// rosterMaxBytes — предел размера тела запроса импорта.
+	rosterMaxBytes = 5 << 20
+)
+
+// rosterRow — строка CSV-файла со списком пользователей.
+type rosterRow struct {
+	Line      int    `json:"line"`
+	FullName  string `json:"fullName"`
+	Email     string `json:"email"`
+	Role      Role   `json:"role"`
+	ClassName string `json:"className,omitempty"`
+	// Password — начальный пароль из файла; пустой означает, что пароль будет сгенерирован.
+	Password string `json:"-"`
+}
+
+// rosterRowError — ошибка проверки строки импорта.
+type rosterRowError struct {
+	Line  int    `json:"line"`
+	Email string `json:"email,omitempty"`
+	Error string `json:"error"`
+}
+
+// rosterHeaders — допустимые названия колонок заголовка (в нижнем регистре).
+var rosterHeaders = map[string]bool{
+	"fullname": true, "full name": true, "name": true, "фио": true,
+}
+
+// parseRoster разбирает CSV со столбцами: ФИО, email, роль, класс, [пароль].
+// Разделитель — запятая или точка с запятой (определяется по первой строке),
+// строка заголовка необязательна.
+func parseRoster(raw []byte) ([]rosterRow, error) {
+	raw = bytes.TrimPrefix(raw, []byte("\xef\xbb\xbf"))
+	firstLine, _, _ := bytes.Cut(raw, []byte("\n"))
+
+	cr := csv.NewReader(bytes.NewReader(raw))
+	if bytes.Count(firstLine, []byte(";")) > bytes.Count(firstLine, []byte(",")) {
+		cr.Comma = ';'
+	}
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	rows := []rosterRow{}
+	for first := true; ; first = false {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid csv: %w", err)
+		}
+		line, _ := cr.FieldPos(0)
+		if first && len(rec) > 0 && rosterHeaders[strings.ToLower(strings.TrimSpace(rec[0]))] {
+			continue
+		}
+		if len(rec) == 1 && strings.TrimSpace(rec[0]) == "" {
+			continue
+		}
+		if len(rows) >= rosterMaxRows {
+			return nil, fmt.Errorf("too many rows (max %d)", rosterMaxRows)
+		}
+		field := func(i int) string {
+			if i < len(rec) {
+				return strings.TrimSpace(rec[i])
+			}
+			return ""
+		}
+		rows = append(rows, rosterRow{
+			Line:      line,
+			FullName:  field(0),
+			Email:     field(1),
+			Role:      Role(strings.ToLower(field(2))),
+			ClassName: field(3),
+			Password:  field(4),
+		})
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("csv has no rows")
+	}
+	return rows, nil
+}
+
+// validateRoster проверяет строки по тем же правилам, что и создание пользователя:
+// класс должен быть в реестре, email не должен повторяться в файле и среди существующих пользователей.
+func validateRoster(rows []rosterRow, st *Storage) []rosterRowError {
+	errs := []rosterRowError{}
+	seen := map[string]int{}
+	for i := range rows {
+		row := &rows[i]
+		if row.Role == "" {
+			row.Role = RoleStudent
+		}
+		row.ClassName = normalizeClassName(row.ClassName)
+		fail := func(msg string) {
+			errs = append(errs, rosterRowError{Line: row.Line, Email: row.Email, Error: msg})
+		}
+		if row.FullName == "" || row.Email == "" {
+			fail("fullName and email are required")
+			continue
+		}
+		if !strings.Contains(row.Email, "@") {
+			fail("invalid email")
+			continue
+		}
+		if err := validateUserRole(row.Role, row.ClassName); err != nil {
+			fail(err.Error())
+			continue
+		}
+		if row.ClassName != "" {
+			if _, ok := st.getClass(row.ClassName); !ok {
+				fail(fmt.Sprintf("unknown class %q", row.ClassName))
+				continue
+			}
+		}
+		key := emailKey(row.Email)
+		if line, dup := seen[key]; dup {
+			fail(fmt.Sprintf("duplicate email (also on line %d)", line))
+			continue
+		}
+		seen[key] = row.Line
+		if _, exists := st.findUserByEmail(row.Email); exists {
+			fail("email already exists")
+		}
+	}
+	return errs
+}
+
+// hashRosterPasswords вычисляет хеши паролей параллельно: PBKDF2 намеренно медленный,
+// а в импорте бывают сотни строк. Возвращает первую ошибку хеширования.
+func hashRosterPasswords(passwords []string) ([]string, error) {
+	hashes := make([]string, len(passwords))
+	errs := make([]error, len(passwords))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range runtime.NumCPU() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				hashes[i], errs[i] = hashPassword(passwords[i])
+			}
+		}()
+	}
+	for i := range passwords {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return hashes, nil
+}
@@ -1,10 +1,15 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
+	"time"
 )
 
 // routes регистрирует все HTTP-маршруты приложения.
@@ -13,24 +18,72 @@ func (s *Server) routes() *http.ServeMux {
 
 	mux.HandleFunc("/api/register", s.handleRegister)
 	mux.HandleFunc("/api/login", s.handleLogin)
+	mux.HandleFunc("/api/refresh", s.handleRefresh)
+	mux.HandleFunc("/api/logout", s.withAuth(s.handleLogout, RoleAdmin, RoleTeacher, RoleStudent))
 	mux.HandleFunc("/api/me", s.withAuth(s.handleMe, RoleAdmin, RoleTeacher, RoleStudent))
+	mux.HandleFunc("/api/me/password", s.withAuth(s.handleChangePassword, RoleAdmin, RoleTeacher, RoleStudent))
+	mux.HandleFunc("/api/password/reset", s.handlePasswordReset)
+	mux.HandleFunc("/api/classes", s.handleClasses)
+	mux.HandleFunc("/api/subjects", s.withAuth(s.handleSubjects, RoleAdmin, RoleTeacher, RoleStudent))
+	mux.HandleFunc("/api/grade-types", s.withAuth(s.handleGradeTypes, RoleAdmin, RoleTeacher, RoleStudent))
+	mux.HandleFunc("/api/grading-scales", s.withAuth(s.handleGradingScales, RoleAdmin, RoleTeacher, RoleStudent))
+	mux.HandleFunc("/api/terms", s.withAuth(s.handleTerms, RoleAdmin, RoleTeacher, RoleStudent))
+	mux.HandleFunc("/api/sessions", s.withAuth(s.handleSessions, RoleAdmin, RoleTeacher, RoleStudent))
+	mux.HandleFunc("/api/sessions/", s.withAuth(s.handleSessionByID, RoleAdmin, RoleTeacher, RoleStudent))
 
 	mux.HandleFunc("/api/admin/users", s.withAuth(s.handleAdminUsers, RoleAdmin))
+	mux.HandleFunc("/api/admin/users/import", s.withAuth(s.handleAdminUsersImport, RoleAdmin))
 	mux.HandleFunc("/api/admin/users/", s.withAuth(s.handleAdminUserByID, RoleAdmin))
+	mux.HandleFunc("/api/admin/classes", s.withAuth(s.handleAdminClasses, RoleAdmin))
+	mux.HandleFunc("/api/admin/classes/", s.withAuth(s.handleAdminClassByID, RoleAdmin))
+	mux.HandleFunc("/api/admin/subjects", s.withAuth(s.handleAdminSubjects, RoleAdmin))
+	mux.HandleFunc("/api/admin/subjects/migrate", s.withAuth(s.handleAdminSubjectsMigrate, RoleAdmin))
+	mux.HandleFunc("/api/admin/subjects/", s.withAuth(s.handleAdminSubjectByID, RoleAdmin))
+	mux.HandleFunc("/api/admin/assignments", s.withAuth(s.handleAdminAssignments, RoleAdmin))
+	mux.HandleFunc("/api/admin/assignments/", s.withAuth(s.handleAdminAssignmentByID, RoleAdmin))
+	mux.HandleFunc("/api/admin/rollover", s.withAuth(s.handleAdminRollover, RoleAdmin))
+	mux.HandleFunc("/api/admin/archives", s.withAuth(s.handleAdminArchives, RoleAdmin))
+	mux.HandleFunc("/api/admin/archives/", s.withAuth(s.handleAdminArchiveByYear, RoleAdmin))
+	mux.HandleFunc("/api/admin/invites", s.withAuth(s.handleAdminInvites, RoleAdmin))
+	mux.HandleFunc("/api/admin/invites/", s.withAuth(s.handleAdminInviteByID, RoleAdmin))
+	mux.HandleFunc("/api/admin/audit", s.withAuth(s.handleAdminAudit, RoleAdmin))
+	mux.HandleFunc("/api/admin/grades/revisions", s.withAuth(s.handleAdminGradeRevisions, RoleAdmin))
+	mux.HandleFunc("/api/admin/grade-types", s.withAuth(s.handleAdminGradeTypes, RoleAdmin))
+	mux.HandleFunc("/api/admin/grade-types/", s.withAuth(s.handleAdminGradeTypeByCode, RoleAdmin))
+	mux.HandleFunc("/api/admin/attendance", s.withAuth(s.handleAdminAttendance, RoleAdmin))
+	mux.HandleFunc("/api/admin/grading-scales", s.withAuth(s.handleAdminGradingScales, RoleAdmin))
+	mux.HandleFunc("/api/admin/grading-scales/", s.withAuth(s.handleAdminGradingScaleByID, RoleAdmin))
+	mux.HandleFunc("/api/admin/terms", s.withAuth(s.handleAdminTerms, RoleAdmin))
+	mux.HandleFunc("/api/admin/terms/", s.withAuth(s.handleAdminTermByID, RoleAdmin))
+	mux.HandleFunc("/api/admin/term-rounding", s.withAuth(s.handleAdminTermRounding, RoleAdmin))
+	mux.HandleFunc("/api/admin/term-grades", s.withAuth(s.handleAdminTermGrades, RoleAdmin))
+	mux.HandleFunc("/api/admin/term-grades/", s.withAuth(s.handleAdminTermGradeByID, RoleAdmin))
+	mux.HandleFunc("/api/admin/lockouts", s.withAuth(s.handleAdminLockouts, RoleAdmin))
 	mux.HandleFunc("/api/admin/schedule/import", s.withAuth(s.handleAdminScheduleImport, RoleAdmin))
 	mux.HandleFunc("/api/admin/schedule", s.withAuth(s.handleAdminScheduleClear, RoleAdmin))
 	mux.HandleFunc("/api/admin/schedule/stats", s.withAuth(s.handleAdminScheduleStats, RoleAdmin))
 
-	mux.HandleFunc("/api/teacher/schedule", s.withAuth(s.handleTeacherScheduleCreate, RoleTeacher))
-	mux.HandleFunc("/api/teacher/subject", s.withAuth(s.handleTeacherSubject, RoleTeacher))
-	mux.HandleFunc("/api/teacher/grades", s.withAuth(s.handleTeacherGradeCreate, RoleTeacher))
-	mux.HandleFunc("/api/teacher/grades/journal", s.withAuth(s.handleTeacherGradesJournal, RoleTeacher))
-	mux.HandleFunc("/api/teacher/homework", s.withAuth(s.handleTeacherHomeworkCreate, RoleTeacher))
-	mux.HandleFunc("/api/teacher/students", s.withAuth(s.handleTeacherStudents, RoleTeacher))
+	mux.HandleFunc("/api/teacher/schedule", s.withAuth(s.handleTeacherSchedule, RoleTeacher, RoleAdmin))
+	mux.HandleFunc("/api/teacher/assignments", s.withAuth(s.handleTeacherAssignments, RoleTeacher))
+	mux.HandleFunc("/api/teacher/grades", s.withAuth(s.handleTeacherGradeCreate, RoleTeacher, RoleAdmin))
+	mux.HandleFunc("/api/teacher/grades/batch", s.withAuth(s.handleTeacherGradesBatch, RoleTeacher, RoleAdmin))
+	mux.HandleFunc("/api/teacher/grades/journal", s.withAuth(s.handleTeacherGradesJournal, RoleTeacher, RoleAdmin))
+	mux.HandleFunc("/api/teacher/grades/", s.withAuth(s.handleTeacherGradeByID, RoleTeacher, RoleAdmin))
+	mux.HandleFunc("/api/teacher/journal", s.withAuth(s.handleTeacherJournal, RoleTeacher, RoleAdmin))
+	mux.HandleFunc("/api/teacher/attendance", s.withAuth(s.handleTeacherAttendance, RoleTeacher, RoleAdmin))
+	mux.HandleFunc("/api/teacher/lessons", s.withAuth(s.handleTeacherLessons, RoleTeacher, RoleAdmin))
+	mux.HandleFunc("/api/teacher/lessons/", s.withAuth(s.handleTeacherLessonByID, RoleTeacher, RoleAdmin))
+	mux.HandleFunc("/api/teacher/homework", s.withAuth(s.handleTeacherHomeworkCreate, RoleTeacher, RoleAdmin))
+	mux.HandleFunc("/api/teacher/term-grades", s.withAuth(s.handleTeacherTermGrades, RoleTeacher, RoleAdmin))
+	mux.HandleFunc("/api/teacher/students", s.withAuth(s.handleTeacherStudents, RoleTeacher, RoleAdmin))
 
 	mux.HandleFunc("/api/student/schedule", s.withAuth(s.handleStudentSchedule, RoleStudent))
 	mux.HandleFunc("/api/student/grades", s.withAuth(s.handleStudentGrades, RoleStudent))
+	mux.HandleFunc("/api/student/grades/revisions", s.withAuth(s.handleStudentGradeRevisions, RoleStudent))
+	mux.HandleFunc("/api/student/term-grades", s.withAuth(s.handleStudentTermGrades, RoleStudent))
+	mux.HandleFunc("/api/student/attendance", s.withAuth(s.handleStudentAttendance, RoleStudent))
 	mux.HandleFunc("/api/student/homework", s.withAuth(s.handleStudentHomework, RoleStudent))
+	mux.HandleFunc("/api/student/diary", s.withAuth(s.handleStudentDiary, RoleStudent))
 
 	staticDir := "static"
 	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir(staticDir))))
@@ -45,10 +98,70 @@ func (s *Server) routes() *http.ServeMux {
 	return mux
 }
 
+// housekeepingLoop периодически удаляет истекшие сессии и устаревшие
+// счетчики неудачных входов до отмены ctx.
+func (s *Server) housekeepingLoop(ctx context.Context, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.throttle.prune()
+			n, err := s.store.pruneSessions()
+			if err != nil {
+				log.Printf("session pruning failed: %v", err)
+				continue
+			}
+			if n > 0 {
+				log.Printf("pruned %d expired sessions", n)
+			}
+		}
+	}
+}
+
 // main запускает HTTP-сервер и логирует параметры старта.
 func main() {
-	store := NewStorage()
-	srv := &Server{store: store}
+	backend := os.Getenv("STORAGE_BACKEND")
+	if backend == "" {
+		backend = "file"
+	}
+	dataDir := os.Getenv("DATA_DIR")
+	if dataDir == "" {
+		dataDir = "data"
+	}
+	store, err := openStore(backend, dataDir)
+	if err != nil {
+		log.Fatalf("failed to open storage: %v", err)
+	}
+	registration, err := parseRegistrationMode(os.Getenv("REGISTRATION_MODE"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	outboxKind := os.Getenv("OUTBOX")
+	if outboxKind == "" {
+		outboxKind = "file"
+	}
+	outboxPath := os.Getenv("OUTBOX_FILE")
+	if outboxPath == "" {
+		outboxPath = filepath.Join(dataDir, "outbox.jsonl")
+	}
+	outbox, err := openOutbox(outboxKind, outboxPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	srv := &Server{
+		store:        store,
+		registration: registration,
+		throttle:     newLoginThrottle(),
+		outbox:       outbox,
+		resetTTL:     envDuration("PASSWORD_RESET_TTL", 24*time.Hour),
+		sessionTTL: sessionTTL{
+			Access:  envDuration("SESSION_TTL", time.Hour),
+			Refresh: envDuration("REFRESH_TTL", 30*24*time.Hour),
+		},
+	}
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -56,9 +169,26 @@ func main() {
 	}
 	addr := ":" + port
 
-	log.Printf("server started on %s", addr)
+	httpServer := &http.Server{Addr: addr, Handler: srv.routes()}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go srv.housekeepingLoop(ctx, 10*time.Minute)
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			log.Printf("shutdown error: %v", err)
+		}
+	}()
+
+	log.Printf("server started on %s, storage %s (%s), registration %s", addr, backend, dataDir, registration)
 	log.Printf("default admin: admin@school.local / admin123")
-	if err := http.ListenAndServe(addr, srv.routes()); err != nil {
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Fatal(err)
 	}
+	if err := store.Close(); err != nil {
+		log.Printf("storage close error: %v", err)
+	}
 }
@@ -0,0 +1,386 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testServer — сервер на хранилище в памяти для HTTP-тестов.
+type testServer struct {
+	t       *testing.T
+	srv     *Server
+	handler http.Handler
+}
+
+// newTestServer создает сервер с указанной политикой регистрации и классами 5A и 6B.
+func newTestServer(t *testing.T, mode registrationMode) *testServer {
+	t.Helper()
+	st, err := NewStorage(memoryJournal{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { st.Close() })
+	if err := addClasses(st, "5A", "6B"); err != nil {
+		t.Fatal(err)
+	}
+	srv := &Server{
+		store:        st,
+		registration: mode,
+		throttle:     newLoginThrottle(),
+		outbox:       logOutbox{},
+		resetTTL:     time.Hour,
+		sessionTTL:   sessionTTL{Access: time.Hour, Refresh: 24 * time.Hour},
+	}
+	return &testServer{t: t, srv: srv, handler: srv.routes()}
+}
+
+// do выполняет запрос с JSON-телом body (nil — без тела) и токеном token.
+func (ts *testServer) do(method, path, token string, body any) *httptest.ResponseRecorder {
+	ts.t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			ts.t.Fatal(err)
+		}
+	}
+	r := httptest.NewRequest(method, path, &buf)
+	r.RemoteAddr = "192.0.2.1:1234"
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	ts.handler.ServeHTTP(w, r)
+	return w
+}
+
+// user создает пользователя напрямую в хранилище и открывает ему сессию.
+func (ts *testServer) user(u User) (User, string) {
+	ts.t.Helper()
+	u, err := ts.srv.store.createUser(u)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	_, tokens, err := ts.srv.store.createSession(u.ID, sessionClient{}, ts.srv.sessionTTL)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	return u, tokens.Token
+}
+
+// mustHash хеширует пароль тестового пользователя.
+func mustHash(t *testing.T, password string) string {
+	t.Helper()
+	hash, err := hashPassword(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+// expectStatus проверяет код ответа.
+func expectStatus(t *testing.T, w *httptest.ResponseRecorder, want int) {
+	t.Helper()
+	if w.Code != want {
+		t.Fatalf("status = %d, want %d; body: %s", w.Code, want, w.Body.String())
+	}
+}
+
+func TestLoginAndTokens(t *testing.T) {
+	ts := newTestServer(t, registrationStudents)
+	if _, err := ts.srv.store.createUser(User{FullName: "S", Email: "s@school.local", PasswordHash: mustHash(t, "pw"), Role: RoleStudent, ClassName: "5A"}); err != nil {
+		t.Fatal(err)
+	}
+
+	expectStatus(t, ts.do(http.MethodGet, "/api/me", "", nil), http.StatusUnauthorized)
+	expectStatus(t, ts.do(http.MethodGet, "/api/me", "bogus", nil), http.StatusUnauthorized)
+	expectStatus(t, ts.do(http.MethodPost, "/api/login", "", map[string]string{"email": "s@school.local", "password": "wrong"}), http.StatusUnauthorized)
+
+	w := ts.do(http.MethodPost, "/api/login", "", map[string]string{"email": "S@school.local", "password": "pw"})
+	expectStatus(t, w, http.StatusOK)
+	var login struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refreshToken"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&login); err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, ts.do(http.MethodGet, "/api/me", login.Token, nil), http.StatusOK)
+
+	w = ts.do(http.MethodPost, "/api/refresh", "", map[string]string{"refreshToken": login.RefreshToken})
+	expectStatus(t, w, http.StatusOK)
+	var refreshed struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&refreshed); err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, ts.do(http.MethodGet, "/api/me", login.Token, nil), http.StatusUnauthorized)
+	expectStatus(t, ts.do(http.MethodPost, "/api/refresh", "", map[string]string{"refreshToken": login.RefreshToken}), http.StatusUnauthorized)
+
+	expectStatus(t, ts.do(http.MethodPost, "/api/logout", refreshed.Token, nil), http.StatusOK)
+	expectStatus(t, ts.do(http.MethodGet, "/api/me", refreshed.Token, nil), http.StatusUnauthorized)
+}
+
+func TestLoginThrottle(t *testing.T) {
+	ts := newTestServer(t, registrationStudents)
+	if _, err := ts.srv.store.createUser(User{FullName: "S", Email: "s@school.local", PasswordHash: mustHash(t, "pw"), Role: RoleStudent, ClassName: "5A"}); err != nil {
+		t.Fatal(err)
+	}
+	bad := map[string]string{"email": "s@school.local", "password": "wrong"}
+	for i := 0; i < ts.srv.throttle.byEmail.FreeAttempts; i++ {
+		expectStatus(t, ts.do(http.MethodPost, "/api/login", "", bad), http.StatusUnauthorized)
+	}
+	expectStatus(t, ts.do(http.MethodPost, "/api/login", "", bad), http.StatusUnauthorized)
+	w := ts.do(http.MethodPost, "/api/login", "", map[string]string{"email": "s@school.local", "password": "pw"})
+	expectStatus(t, w, http.StatusTooManyRequests)
+	if w.Header().Get("Retry-After") == "" {
+		t.Fatal("Retry-After header is missing")
+	}
+
+	if !ts.srv.throttle.clear("email:s@school.local") {
+		t.Fatal("lockout entry not found")
+	}
+	expectStatus(t, ts.do(http.MethodPost, "/api/login", "", map[string]string{"email": "s@school.local", "password": "pw"}), http.StatusOK)
+}
+
+func TestRegistrationModes(t *testing.T) {
+	student := map[string]string{"fullName": "S", "email": "s@school.local", "password": "pw", "className": "5a"}
+
+	ts := newTestServer(t, registrationStudents)
+	teacher := map[string]string{"fullName": "T", "email": "t@school.local", "password": "pw", "role": "teacher"}
+	expectStatus(t, ts.do(http.MethodPost, "/api/register", "", teacher), http.StatusForbidden)
+	expectStatus(t, ts.do(http.MethodPost, "/api/register", "", map[string]string{"fullName": "S", "email": "x@school.local", "password": "pw", "className": "9Z"}), http.StatusBadRequest)
+	expectStatus(t, ts.do(http.MethodPost, "/api/register", "", student), http.StatusCreated)
+	expectStatus(t, ts.do(http.MethodPost, "/api/register", "", student), http.StatusBadRequest)
+
+	ts = newTestServer(t, registrationInvite)
+	expectStatus(t, ts.do(http.MethodPost, "/api/register", "", student), http.StatusForbidden)
+	_, adminToken := ts.user(User{FullName: "A", Email: "a@school.local", Role: RoleAdmin})
+	expectStatus(t, ts.do(http.MethodPost, "/api/admin/invites", adminToken, map[string]string{"role": "teacher"}), http.StatusBadRequest)
+	_, code, err := ts.srv.store.createInvite(Invite{Role: RoleStudent, ClassName: "5A", ExpiresAt: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	invited := map[string]string{"fullName": "T", "email": "t@school.local", "password": "pw", "role": "teacher", "inviteCode": code}
+	w := ts.do(http.MethodPost, "/api/register", "", invited)
+	expectStatus(t, w, http.StatusCreated)
+	var u User
+	if err := json.NewDecoder(w.Body).Decode(&u); err != nil {
+		t.Fatal(err)
+	}
+	if u.Role != RoleStudent || u.ClassName != "5A" {
+		t.Fatalf("invited user = %+v, want student of 5A", u)
+	}
+	invited["email"] = "t2@school.local"
+	expectStatus(t, ts.do(http.MethodPost, "/api/register", "", invited), http.StatusBadRequest)
+
+	ts = newTestServer(t, registrationClosed)
+	expectStatus(t, ts.do(http.MethodPost, "/api/register", "", student), http.StatusForbidden)
+}
+
+func TestRoleScoping(t *testing.T) {
+	ts := newTestServer(t, registrationStudents)
+	if err := addSubjects(ts.srv.store, "Математика"); err != nil {
+		t.Fatal(err)
+	}
+	teacher, teacherToken := ts.user(User{FullName: "T", Email: "t@school.local", Role: RoleTeacher})
+	if _, err := ts.srv.store.createAssignment(Assignment{TeacherID: teacher.ID, Subject: "Математика", ClassName: "5A"}); err != nil {
+		t.Fatal(err)
+	}
+	own, studentToken := ts.user(User{FullName: "S", Email: "s@school.local", Role: RoleStudent, ClassName: "5A"})
+	other, _ := ts.user(User{FullName: "O", Email: "o@school.local", Role: RoleStudent, ClassName: "6B"})
+	_, adminToken := ts.user(User{FullName: "A", Email: "a@school.local", Role: RoleAdmin})
+
+	expectStatus(t, ts.do(http.MethodGet, "/api/admin/users", studentToken, nil), http.StatusForbidden)
+	expectStatus(t, ts.do(http.MethodGet, "/api/admin/users", teacherToken, nil), http.StatusForbidden)
+	expectStatus(t, ts.do(http.MethodPost, "/api/teacher/grades", studentToken, map[string]any{"studentId": own.ID, "value": 5}), http.StatusForbidden)
+	expectStatus(t, ts.do(http.MethodGet, "/api/student/grades", teacherToken, nil), http.StatusForbidden)
+
+	w := ts.do(http.MethodGet, "/api/teacher/students", teacherToken, nil)
+	expectStatus(t, w, http.StatusOK)
+	var students []User
+	if err := json.NewDecoder(w.Body).Decode(&students); err != nil {
+		t.Fatal(err)
+	}
+	if len(students) != 1 || students[0].ID != own.ID {
+		t.Fatalf("teacher students = %+v, want only %d", students, own.ID)
+	}
+
+	grade := map[string]any{"studentId": own.ID, "value": 5, "date": "2026-09-10"}
+	expectStatus(t, ts.do(http.MethodPost, "/api/teacher/grades", teacherToken, grade), http.StatusCreated)
+	grade["studentId"] = other.ID
+	expectStatus(t, ts.do(http.MethodPost, "/api/teacher/grades", teacherToken, grade), http.StatusForbidden)
+	expectStatus(t, ts.do(http.MethodGet, "/api/teacher/journal?className=6B&from=2026-09-01&to=2026-09-30", teacherToken, nil), http.StatusForbidden)
+
+	grade["subject"] = "Математика"
+	expectStatus(t, ts.do(http.MethodPost, "/api/teacher/grades", adminToken, grade), http.StatusCreated)
+}
+
+func TestTeacherAssignmentPairs(t *testing.T) {
+	ts := newTestServer(t, registrationStudents)
+	if err := addSubjects(ts.srv.store, "Математика", "Физика"); err != nil {
+		t.Fatal(err)
+	}
+	teacher, token := ts.user(User{FullName: "T", Email: "t@school.local", Role: RoleTeacher})
+	for _, a := range []Assignment{{Subject: "Математика", ClassName: "5A"}, {Subject: "Физика", ClassName: "6B"}} {
+		a.TeacherID = teacher.ID
+		if _, err := ts.srv.store.createAssignment(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	student, _ := ts.user(User{FullName: "S", Email: "s@school.local", Role: RoleStudent, ClassName: "5A"})
+	term, err := ts.srv.store.createTerm(Term{AcademicYear: "2026/2027", Name: "1", StartDate: "2026-09-01", EndDate: "2026-12-31"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	physicsIn5A := []struct {
+		method, path string
+		body         any
+	}{
+		{http.MethodPost, "/api/teacher/grades", map[string]any{"studentId": student.ID, "subject": "Физика", "value": 5, "date": "2026-09-10"}},
+		{http.MethodPost, "/api/teacher/grades/batch", map[string]any{"subject": "Физика", "date": "2026-09-10", "grades": []map[string]any{{"studentId": student.ID, "value": 5}}}},
+		{http.MethodGet, "/api/teacher/journal?className=5A&subject=Физика&from=2026-09-01&to=2026-09-30", nil},
+		{http.MethodPost, "/api/teacher/attendance", map[string]any{"className": "5A", "subject": "Физика", "date": "2026-09-10"}},
+		{http.MethodPost, "/api/teacher/lessons", map[string]any{"className": "5A", "subject": "Физика", "date": "2026-09-10", "period": 1}},
+		{http.MethodGet, "/api/teacher/term-grades?termId=" + itoa(term.ID) + "&className=5A&subject=Физика", nil},
+		{http.MethodPost, "/api/teacher/term-grades", map[string]any{"termId": term.ID, "studentId": student.ID, "subject": "Физика", "value": 5}},
+		{http.MethodPost, "/api/teacher/homework", map[string]any{"className": "5A", "subject": "Физика", "description": "§1", "dueDate": "2026-09-11"}},
+	}
+	for _, c := range physicsIn5A {
+		w := ts.do(c.method, c.path, token, c.body)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("%s %s: status = %d, want 403; body: %s", c.method, c.path, w.Code, w.Body.String())
+		}
+	}
+
+	expectStatus(t, ts.do(http.MethodPost, "/api/teacher/grades", token, map[string]any{"studentId": student.ID, "subject": "Математика", "value": 5, "date": "2026-09-10"}), http.StatusCreated)
+	expectStatus(t, ts.do(http.MethodPost, "/api/teacher/homework", token, map[string]any{"className": "5A", "subject": "Математика", "description": "§1", "dueDate": "2026-09-11"}), http.StatusCreated)
+	expectStatus(t, ts.do(http.MethodPost, "/api/teacher/lessons", token, map[string]any{"className": "5A", "date": "2026-09-10", "period": 1, "topic": "Дроби"}), http.StatusCreated)
+
+	_, adminToken := ts.user(User{FullName: "A", Email: "a@school.local", Role: RoleAdmin})
+	lesson := map[string]any{"className": "5A", "subject": "Физика", "weekday": "monday", "startTime": "09:00", "endTime": "09:45"}
+	expectStatus(t, ts.do(http.MethodPost, "/api/teacher/schedule", token, lesson), http.StatusForbidden)
+	expectStatus(t, ts.do(http.MethodPost, "/api/teacher/schedule", adminToken, lesson), http.StatusBadRequest)
+	lesson["teacherId"] = teacher.ID
+	expectStatus(t, ts.do(http.MethodPost, "/api/teacher/schedule", adminToken, lesson), http.StatusBadRequest)
+	lesson["subject"] = "Математика"
+	expectStatus(t, ts.do(http.MethodPost, "/api/teacher/schedule", adminToken, lesson), http.StatusCreated)
+}
+
+// itoa форматирует ID для query-параметров.
+func itoa(id int64) string {
+	return strconv.FormatInt(id, 10)
+}
+
+func TestRosterImportSizeLimit(t *testing.T) {
+	ts := newTestServer(t, registrationStudents)
+	_, adminToken := ts.user(User{FullName: "A", Email: "a@school.local", Role: RoleAdmin})
+	before := len(ts.srv.store.listUsers())
+
+	csvBody := "fullName,email,role,className\n" + strings.Repeat("Ученик,s@school.local,student,5A\n", rosterMaxBytes/30)
+	r := httptest.NewRequest(http.MethodPost, "/api/admin/users/import", strings.NewReader(csvBody))
+	r.Header.Set("Authorization", "Bearer "+adminToken)
+	w := httptest.NewRecorder()
+	ts.handler.ServeHTTP(w, r)
+	expectStatus(t, w, http.StatusRequestEntityTooLarge)
+
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	part, err := mw.CreateFormFile("file", "roster.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write([]byte(csvBody))
+	mw.Close()
+	r = httptest.NewRequest(http.MethodPost, "/api/admin/users/import", &form)
+	r.Header.Set("Authorization", "Bearer "+adminToken)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	w = httptest.NewRecorder()
+	ts.handler.ServeHTTP(w, r)
+	expectStatus(t, w, http.StatusRequestEntityTooLarge)
+
+	if n := len(ts.srv.store.listUsers()); n != before {
+		t.Fatalf("users after rejected import = %d, want %d", n, before)
+	}
+}
+
+func TestAdminUserUpdate(t *testing.T) {
+	ts := newTestServer(t, registrationStudents)
+	admin, adminToken := ts.user(User{FullName: "A", Email: "a@school.local", Role: RoleAdmin})
+	student, studentToken := ts.user(User{FullName: "S", Email: "s@school.local", Role: RoleStudent, ClassName: "5A"})
+	path := "/api/admin/users/" + itoa(student.ID)
+
+	expectStatus(t, ts.do(http.MethodPatch, "/api/admin/users/999", adminToken, map[string]string{"fullName": "X"}), http.StatusNotFound)
+	expectStatus(t, ts.do(http.MethodPatch, "/api/admin/users/"+itoa(admin.ID), adminToken, map[string]string{"role": "teacher"}), http.StatusBadRequest)
+	expectStatus(t, ts.do(http.MethodPatch, path, adminToken, map[string]string{"className": ""}), http.StatusBadRequest)
+
+	expectStatus(t, ts.do(http.MethodGet, "/api/me", studentToken, nil), http.StatusOK)
+	w := ts.do(http.MethodPatch, path, adminToken, map[string]string{"role": "teacher"})
+	expectStatus(t, w, http.StatusOK)
+	var resp struct {
+		User User `json:"user"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.User.Role != RoleTeacher || resp.User.ClassName != "" {
+		t.Fatalf("user after role change = %+v", resp.User)
+	}
+	expectStatus(t, ts.do(http.MethodGet, "/api/me", studentToken, nil), http.StatusUnauthorized)
+}
+
+func TestCreateClassCyrillicLetter(t *testing.T) {
+	ts := newTestServer(t, registrationStudents)
+	_, adminToken := ts.user(User{FullName: "A", Email: "a@school.local", Role: RoleAdmin})
+
+	w := ts.do(http.MethodPost, "/api/admin/classes", adminToken, map[string]string{"name": "5 б"})
+	expectStatus(t, w, http.StatusCreated)
+	var c Class
+	if err := json.NewDecoder(w.Body).Decode(&c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Name != "5Б" || c.Grade != 5 || c.Letter != "Б" {
+		t.Fatalf("created class = %+v", c)
+	}
+	expectStatus(t, ts.do(http.MethodPost, "/api/admin/classes", adminToken, map[string]any{"grade": 5, "letter": "Б"}), http.StatusBadRequest)
+	expectStatus(t, ts.do(http.MethodPost, "/api/admin/classes", adminToken, map[string]any{"grade": 9, "letter": "г"}), http.StatusCreated)
+	expectStatus(t, ts.do(http.MethodPost, "/api/admin/classes", adminToken, map[string]string{"name": "5λ"}), http.StatusBadRequest)
+	expectStatus(t, ts.do(http.MethodPost, "/api/admin/classes", adminToken, map[string]any{"grade": 5, "letter": "БВ"}), http.StatusBadRequest)
+}
+
+func TestLoginThrottleConcurrent(t *testing.T) {
+	ts := newTestServer(t, registrationStudents)
+	if _, err := ts.srv.store.createUser(User{FullName: "S", Email: "s@school.local", PasswordHash: mustHash(t, "pw"), Role: RoleStudent, ClassName: "5A"}); err != nil {
+		t.Fatal(err)
+	}
+	const attempts = 20
+	codes := make(chan int, attempts)
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- ts.do(http.MethodPost, "/api/login", "", map[string]string{"email": "s@school.local", "password": "wrong"}).Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+	guesses := 0
+	for code := range codes {
+		if code == http.StatusUnauthorized {
+			guesses++
+		}
+	}
+	if limit := ts.srv.throttle.byEmail.FreeAttempts + 1; guesses > limit {
+		t.Fatalf("%d passwords checked concurrently, want at most %d", guesses, limit)
+	}
+}
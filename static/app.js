@@ -1,5 +1,6 @@
 const state = {
   token: localStorage.getItem("token") || "",
+  refreshToken: localStorage.getItem("refreshToken") || "",
   user: null,
 };
 
@@ -9,6 +10,8 @@ const teacherJournal = {
   students: [],
   dates: [],
   gradesMap: new Map(),
+  averages: new Map(),
+  scales: [],
 };
 
 const logBox = document.getElementById("log");
@@ -17,11 +20,12 @@ const logoutBtn = document.getElementById("logoutBtn");
 const authSection = document.getElementById("authSection");
 const dashboard = document.getElementById("dashboard");
 
-const registerRole = document.getElementById("registerRole");
+const registerInvite = document.getElementById("registerInvite");
 const classLabel = document.getElementById("classLabel");
 
-registerRole.addEventListener("change", () => {
-  classLabel.classList.toggle("hidden", registerRole.value !== "student");
+// С кодом приглашения роль и класс задает администратор.
+registerInvite.addEventListener("input", () => {
+  classLabel.classList.toggle("hidden", registerInvite.value.trim() !== "");
 });
 
 function log(msg, data) {
@@ -29,10 +33,41 @@ function log(msg, data) {
   logBox.textContent = data ? `${line}\n${JSON.stringify(data, null, 2)}` : line;
 }
 
-async function api(path, options = {}) {
+function saveSession(result) {
+  state.token = result.token || "";
+  state.refreshToken = result.refreshToken || "";
+  localStorage.setItem("token", state.token);
+  localStorage.setItem("refreshToken", state.refreshToken);
+}
+
+function clearSession() {
+  state.token = "";
+  state.refreshToken = "";
+  state.user = null;
+  localStorage.removeItem("token");
+  localStorage.removeItem("refreshToken");
+}
+
+// Обновляет истекший токен доступа по refresh-токену.
+async function refreshSession() {
+  if (!state.refreshToken) return false;
+  const res = await fetch("/api/refresh", {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({ refreshToken: state.refreshToken }),
+  });
+  if (!res.ok) return false;
+  saveSession(await res.json());
+  return true;
+}
+
+async function api(path, options = {}, retry = true) {
   const headers = { "Content-Type": "application/json", ...(options.headers || {}) };
   if (state.token) headers.Authorization = `Bearer ${state.token}`;
   const res = await fetch(path, { ...options, headers });
+  if (res.status === 401 && retry && state.token && (await refreshSession())) {
+    return api(path, options, false);
+  }
   const data = await res.json().catch(() => ({}));
   if (!res.ok) throw new Error(data.error || `HTTP ${res.status}`);
   return data;
@@ -55,6 +90,17 @@ function card(title, body) {
   return `<div class="panel"><h3>${title}</h3>${body}</div>`;
 }
 
+// Строки расписания: день, время, предмет, кабинет (и класс для учителя).
+function scheduleItems(entries, withClass = false) {
+  return (entries || [])
+    .map(
+      (e) =>
+        `<div class="item">${escapeHtml(e.weekday)} ${escapeHtml(e.startTime)}–${escapeHtml(e.endTime)}: ${escapeHtml(e.subject)}` +
+        `${withClass ? `, ${escapeHtml(e.className)}` : ""}${e.room ? ` (каб. ${escapeHtml(e.room)})` : ""}</div>`,
+    )
+    .join("");
+}
+
 function dateToISO(d) {
   const y = d.getFullYear();
   const m = String(d.getMonth() + 1).padStart(2, "0");
@@ -75,8 +121,16 @@ function buildDateWindow() {
   return res;
 }
 
-// Строит HTML-таблицу оценок ученика: строки=предметы, столбцы=даты.
-function buildStudentGradesTable(rows) {
+// Возвращает подпись значения оценки по ее шкале (например, «зачет»).
+function gradeLabel(scales, scaleId, value) {
+  const scale = (scales || []).find((sc) => sc.id === scaleId) || (scales || []).find((sc) => sc.default);
+  const item = scale && scale.values.find((v) => v.value === value);
+  return item ? item.label : String(value);
+}
+
+// Строит HTML-таблицу оценок ученика: строки=предметы, столбцы=даты, последний столбец — средний балл.
+function buildStudentGradesTable(rows, averages, scales) {
+  const avgBySubject = new Map((averages || []).map((a) => [a.subject, a.average]));
   const subjects = [...new Set((rows || []).map((r) => (r.subject || "").trim()).filter(Boolean))]
     .sort((a, b) => a.localeCompare(b, "ru"));
   const dates = [...new Set((rows || []).map((r) => (r.date || "").trim()).filter(Boolean))]
@@ -101,15 +155,16 @@ function buildStudentGradesTable(rows) {
         .map((date) => {
           const row = map.get(`${subject}__${date}`);
           if (!row) return `<td class="empty-cell">-</td>`;
-          const title = escapeHtml(row.comment || "Без комментария");
-          return `<td><span class="grade-badge" title="${title}">${escapeHtml(row.value)}</span></td>`;
+          const title = escapeHtml(`${row.type || ""} ${row.comment || "Без комментария"}`.trim());
+          return `<td><span class="grade-badge" title="${title}">${escapeHtml(gradeLabel(scales, row.scaleId, row.value))}</span></td>`;
         })
         .join("");
-      return `<tr><th>${escapeHtml(subject)}</th>${tds}</tr>`;
+      const avg = avgBySubject.has(subject) ? avgBySubject.get(subject).toFixed(2) : "-";
+      return `<tr><th>${escapeHtml(subject)}</th>${tds}<td><b>${avg}</b></td></tr>`;
     })
     .join("");
 
-  return `<div class="table-wrap"><table class="grade-table"><thead><tr><th>Предмет \\ Дата</th>${head}</tr></thead><tbody>${body}</tbody></table></div>`;
+  return `<div class="table-wrap"><table class="grade-table"><thead><tr><th>Предмет \\ Дата</th>${head}<th>Средний</th></tr></thead><tbody>${body}</tbody></table></div>`;
 }
 
 // Рисует журнал учителя: строки=ученики, столбцы=даты, ячейка кликабельна.
@@ -130,27 +185,29 @@ function renderTeacherJournalTable() {
         .map((date) => {
           const key = `${student.id}__${date}`;
           const row = teacherJournal.gradesMap.get(key);
-          const value = row ? escapeHtml(row.value) : "+";
+          const value = row ? escapeHtml(gradeLabel(teacherJournal.scales, row.scaleId, row.value)) : "+";
           const title = escapeHtml((row && row.comment) || "Кликните, чтобы поставить оценку");
           const cls = row ? "grade-cell has-grade" : "grade-cell empty-grade";
           return `<td><button type="button" class="${cls}" data-student-id="${student.id}" data-date="${date}" title="${title}">${value}</button></td>`;
         })
         .join("");
-      return `<tr>${firstCol}${tds}</tr>`;
+      const avg = teacherJournal.averages.has(student.id) ? teacherJournal.averages.get(student.id).toFixed(2) : "-";
+      return `<tr>${firstCol}${tds}<td><b>${avg}</b></td></tr>`;
     })
     .join("");
 
-  box.innerHTML = `<div class="table-wrap"><table class="grade-table"><thead><tr><th>Ученик</th>${head}</tr></thead><tbody>${body}</tbody></table></div>`;
+  box.innerHTML = `<div class="table-wrap"><table class="grade-table"><thead><tr><th>Ученик</th>${head}<th>Средний</th></tr></thead><tbody>${body}</tbody></table></div>`;
 }
 
 // Загружает оценки учителя за диапазон дат и обновляет карту оценок.
 async function loadTeacherJournalData() {
   const from = teacherJournal.dates[0];
   const to = teacherJournal.dates[teacherJournal.dates.length - 1];
-  const payload = await api(`/api/teacher/grades/journal?from=${encodeURIComponent(from)}&to=${encodeURIComponent(to)}`);
+  teacherJournal.subject = document.getElementById("teacherSubjectSelect").value || "";
+  const params = new URLSearchParams({ from, to });
+  if (teacherJournal.subject) params.set("subject", teacherJournal.subject);
+  const payload = await api(`/api/teacher/grades/journal?${params}`);
   teacherJournal.subject = payload.subject || "";
-  document.getElementById("teacherSubjectInput").value = teacherJournal.subject;
-  document.getElementById("teacherSubjectCurrent").textContent = teacherJournal.subject || "не задан";
 
   teacherJournal.gradesMap = new Map();
   for (const g of payload.grades || []) {
@@ -158,32 +215,22 @@ async function loadTeacherJournalData() {
     const prev = teacherJournal.gradesMap.get(key);
     if (!prev || (g.id || 0) > (prev.id || 0)) teacherJournal.gradesMap.set(key, g);
   }
+  teacherJournal.averages = new Map((payload.averages || []).map((a) => [a.studentId, a.average]));
+  teacherJournal.scales = payload.scales || [];
   renderTeacherJournalTable();
 }
 
-// Навешивает действия для предмета и кликов по ячейкам журнала.
+// Навешивает действия для выбора предмета и кликов по ячейкам журнала.
 function setupTeacherJournalActions() {
-  const saveSubjectBtn = document.getElementById("saveTeacherSubjectBtn");
+  const subjectSelect = document.getElementById("teacherSubjectSelect");
   const loadJournalBtn = document.getElementById("loadTeacherJournalBtn");
   const tableWrap = document.getElementById("teacherJournalWrap");
-  const subjectInput = document.getElementById("teacherSubjectInput");
 
-  saveSubjectBtn.onclick = async () => {
-    const subject = (subjectInput.value || "").trim();
-    if (!subject) {
-      log("Предмет", { error: "Введите предмет" });
-      return;
-    }
+  subjectSelect.onchange = async () => {
     try {
-      const data = await api("/api/teacher/subject", {
-        method: "POST",
-        body: JSON.stringify({ subject }),
-      });
-      teacherJournal.subject = data.subject || "";
-      document.getElementById("teacherSubjectCurrent").textContent = teacherJournal.subject || "не задан";
-      log("Предмет учителя сохранен", data);
+      await loadTeacherJournalData();
     } catch (e) {
-      log("Ошибка сохранения предмета", { error: e.message });
+      log("Ошибка загрузки журнала", { error: e.message });
     }
   };
 
@@ -199,7 +246,7 @@ function setupTeacherJournalActions() {
     const btn = e.target.closest("button.grade-cell");
     if (!btn) return;
     if (!teacherJournal.subject) {
-      log("Журнал", { error: "Сначала задайте предмет учителя" });
+      log("Журнал", { error: "Сначала загрузите журнал по одному из ваших предметов" });
       return;
     }
 
@@ -208,11 +255,11 @@ function setupTeacherJournalActions() {
     if (!studentId || !date) return;
 
     const existing = teacherJournal.gradesMap.get(`${studentId}__${date}`);
-    const valueRaw = prompt(`Оценка на ${date} (1-5):`, existing ? String(existing.value) : "5");
+    const valueRaw = prompt(`Оценка на ${date} (значение по шкале предмета):`, existing ? String(existing.value) : "5");
     if (valueRaw === null) return;
     const value = Number(valueRaw);
-    if (!Number.isInteger(value) || value < 1 || value > 5) {
-      log("Ошибка", { error: "Оценка должна быть от 1 до 5" });
+    if (!Number.isInteger(value) || value < 1) {
+      log("Ошибка", { error: "Оценка должна быть целым числом" });
       return;
     }
     const comment = prompt("Комментарий:", existing ? (existing.comment || "") : "");
@@ -223,7 +270,9 @@ function setupTeacherJournalActions() {
         method: "POST",
         body: JSON.stringify({
           studentId,
+          subject: teacherJournal.subject,
           value,
+          type: document.getElementById("teacherGradeTypeSelect").value,
           comment,
           date,
         }),
@@ -256,6 +305,79 @@ async function loadTeacherStudents(selectId = "") {
   }
 }
 
+// Показывает предложенные итоговые оценки класса и утверждает их по клику.
+function setupTeacherTermGrades() {
+  const list = document.getElementById("termGradesList");
+  api("/api/terms")
+    .then((terms) => {
+      document.getElementById("termSelect").innerHTML = terms
+        .map((t) => `<option value="${t.id}">${escapeHtml(t.name)} (${t.startDate} — ${t.endDate})</option>`)
+        .join("");
+    })
+    .catch(() => {});
+
+  const load = async () => {
+    const params = new URLSearchParams({
+      termId: document.getElementById("termSelect").value,
+      className: document.getElementById("termClassName").value.trim(),
+    });
+    const subject = document.getElementById("teacherSubjectSelect").value;
+    if (subject) params.set("subject", subject);
+    const data = await api(`/api/teacher/term-grades?${params}`);
+    const names = new Map(teacherJournal.students.map((st) => [st.id, st.fullName]));
+    list.innerHTML = data.proposals
+      .map((p) => {
+        const who = escapeHtml(names.get(p.studentId) || `#${p.studentId}`);
+        const stats = `средний ${p.average.toFixed(2)}, оценок ${p.count}`;
+        const label = (v) => escapeHtml(gradeLabel(data.scales, p.scaleId, v));
+        if (p.approved) return `<div class="item">${who}: <b>${label(p.approved.value)}</b> утверждена (${stats})</div>`;
+        const proposed = p.proposed ? `предложено <b>${label(p.proposed)}</b>` : "оценок недостаточно";
+        return `<div class="item">${who}: ${proposed} (${stats}) <button type="button" data-student-id="${p.studentId}" data-proposed="${p.proposed}">Утвердить</button></div>`;
+      })
+      .join("") || `<div class="item">В классе нет учеников.</div>`;
+    list.dataset.termId = data.termId;
+    list.dataset.subject = data.subject;
+  };
+
+  document.getElementById("loadTermGradesBtn").onclick = async () => {
+    try {
+      await load();
+    } catch (e) {
+      log("Ошибка итоговых оценок", { error: e.message });
+    }
+  };
+
+  list.onclick = async (e) => {
+    const btn = e.target.closest("button[data-student-id]");
+    if (!btn) return;
+    const proposed = Number(btn.dataset.proposed);
+    const valueRaw = prompt("Итоговая оценка (значение по шкале предмета):", proposed ? String(proposed) : "");
+    if (valueRaw === null) return;
+    const value = Number(valueRaw);
+    let reason = "";
+    if (value !== proposed) {
+      reason = prompt("Причина изменения предложенной оценки:", "");
+      if (reason === null) return;
+    }
+    try {
+      const tg = await api("/api/teacher/term-grades", {
+        method: "POST",
+        body: JSON.stringify({
+          termId: Number(list.dataset.termId),
+          studentId: Number(btn.dataset.studentId),
+          subject: list.dataset.subject,
+          value,
+          reason,
+        }),
+      });
+      log("Итоговая оценка утверждена", tg);
+      await load();
+    } catch (err) {
+      log("Ошибка утверждения", { error: err.message });
+    }
+  };
+}
+
 function submitForm(path, mapper = (x) => x) {
   return async (e) => {
     e.preventDefault();
@@ -279,6 +401,74 @@ function renderDashboard() {
         <button id="loadUsers">Обновить список</button>
         <div id="usersList" class="list"></div>
       `),
+      card("Классы", `
+        <form id="classForm" class="grid">
+          ${formField("name", "text", "например, 7A")}
+          <label>Учебный год<input name="academicYear" placeholder="по умолчанию текущий, например 2026/2027" /></label>
+          <button type="submit">Добавить класс</button>
+        </form>
+        <button id="loadClasses" type="button">Обновить список</button>
+        <div id="classesList" class="list"></div>
+      `),
+      card("Предметы", `
+        <form id="subjectForm" class="grid">
+          ${formField("name", "text", "например, Математика")}
+          <label>Сокращение<input name="shortName" placeholder="необязательно, например Мат" /></label>
+          <label>Синонимы<input name="aliases" placeholder="через запятую, например Алгебра, Геометрия" /></label>
+          <button type="submit">Добавить предмет</button>
+        </form>
+        <button id="loadSubjects" type="button">Обновить список</button>
+        <button id="previewSubjectMigration" type="button">Проверить старые записи</button>
+        <button id="applySubjectMigration" type="button">Сопоставить (создать недостающие)</button>
+        <div id="subjectsList" class="list"></div>
+      `),
+      card("Виды работ и веса", `
+        <form id="gradeTypeForm" class="grid">
+          <label>Код<input name="code" placeholder="test, control, homework, oral, lab" required /></label>
+          <label>Вес<input name="weight" type="number" min="0.1" max="10" step="0.1" required /></label>
+          <button type="submit">Сохранить вес</button>
+        </form>
+        <button id="loadGradeTypes" type="button">Обновить список</button>
+        <div id="gradeTypesList" class="list"></div>
+      `),
+      card("Учебные периоды", `
+        <form id="termForm" class="grid">
+          ${formField("name", "text", "например, 1 четверть")}
+          ${formField("startDate", "date")}
+          ${formField("endDate", "date")}
+          <button type="submit">Добавить период</button>
+        </form>
+        <form id="termRoundingForm" class="grid">
+          <label>Округлять вверх с дробной части<input name="threshold" type="number" min="0.01" max="0.99" step="0.01" required /></label>
+          <label>Минимум оценок<input name="minGrades" type="number" min="1" required /></label>
+          <button type="submit">Сохранить правила округления</button>
+        </form>
+        <button id="loadTerms" type="button">Обновить список</button>
+        <div id="termsList" class="list"></div>
+      `),
+      card("Закрепления учителей", `
+        <form id="assignmentForm" class="grid">
+          <label>ID учителя<input name="teacherId" type="number" min="1" required /></label>
+          ${formField("subject", "text", "например, Математика")}
+          ${formField("className", "text", "например, 7A")}
+          <label>Группа<input name="group" placeholder="необязательно, например 1" /></label>
+          <button type="submit">Закрепить</button>
+        </form>
+        <button id="loadAssignments" type="button">Обновить список</button>
+        <div id="assignmentsList" class="list"></div>
+      `),
+      card("Приглашения", `
+        <form id="inviteForm" class="grid">
+          <label>Роль
+            <select name="role">
+              <option value="student">Ученик</option>
+              <option value="teacher">Учитель</option>
+            </select>
+          </label>
+          <label>Класс (для ученика)<input name="className" placeholder="например, 7A" /></label>
+          <button type="submit">Создать код приглашения</button>
+        </form>
+      `),
       card("Расписание (фото по классу)", `
         <form id="scheduleImportForm" class="grid">
           ${formField("className", "text", "например, 7A")}
@@ -300,6 +490,119 @@ function renderDashboard() {
       }
     };
 
+    document.getElementById("classForm").onsubmit = submitForm("/api/admin/classes");
+    document.getElementById("loadClasses").onclick = async () => {
+      try {
+        const classes = await api("/api/admin/classes");
+        document.getElementById("classesList").innerHTML = classes
+          .map((c) => `<div class="item">${c.name} | ${c.academicYear}${c.homeroomTeacherId ? ` | кл. рук. #${c.homeroomTeacherId}` : ""}</div>`)
+          .join("");
+      } catch (e) {
+        log("Ошибка загрузки классов", { error: e.message });
+      }
+    };
+
+    document.getElementById("subjectForm").onsubmit = submitForm("/api/admin/subjects", (body) => ({
+      ...body,
+      aliases: (body.aliases || "").split(",").map((x) => x.trim()).filter(Boolean),
+    }));
+    document.getElementById("loadSubjects").onclick = async () => {
+      try {
+        const subjects = await api("/api/admin/subjects");
+        document.getElementById("subjectsList").innerHTML = subjects
+          .map((x) => `<div class="item">#${x.id} ${escapeHtml(x.name)}${x.shortName ? ` (${escapeHtml(x.shortName)})` : ""}${x.aliases.length ? ` | ${escapeHtml(x.aliases.join(", "))}` : ""}</div>`)
+          .join("");
+      } catch (e) {
+        log("Ошибка загрузки предметов", { error: e.message });
+      }
+    };
+    document.getElementById("previewSubjectMigration").onclick = async () => {
+      try {
+        log("План сопоставления предметов", await api("/api/admin/subjects/migrate?dryRun=true", { method: "POST" }));
+      } catch (e) {
+        log("Ошибка", { error: e.message });
+      }
+    };
+    document.getElementById("applySubjectMigration").onclick = async () => {
+      try {
+        const data = await api("/api/admin/subjects/migrate", {
+          method: "POST",
+          body: JSON.stringify({ createMissing: true }),
+        });
+        log("Предметы сопоставлены", data);
+      } catch (e) {
+        log("Ошибка", { error: e.message });
+      }
+    };
+
+    document.getElementById("gradeTypeForm").onsubmit = async (e) => {
+      e.preventDefault();
+      const body = Object.fromEntries(new FormData(e.target).entries());
+      try {
+        const result = await api(`/api/admin/grade-types/${encodeURIComponent(body.code.trim())}`, {
+          method: "PATCH",
+          body: JSON.stringify({ weight: Number(body.weight) }),
+        });
+        e.target.reset();
+        log("Вес сохранен", result);
+      } catch (err) {
+        log("Ошибка", { error: err.message });
+      }
+    };
+    document.getElementById("loadGradeTypes").onclick = async () => {
+      try {
+        const types = await api("/api/admin/grade-types");
+        document.getElementById("gradeTypesList").innerHTML = types
+          .map((t) => `<div class="item">${t.code} | ${escapeHtml(t.name)} | вес ${t.weight}</div>`)
+          .join("");
+      } catch (e) {
+        log("Ошибка загрузки видов работ", { error: e.message });
+      }
+    };
+
+    document.getElementById("termForm").onsubmit = submitForm("/api/admin/terms");
+    document.getElementById("termRoundingForm").onsubmit = async (e) => {
+      e.preventDefault();
+      const body = Object.fromEntries(new FormData(e.target).entries());
+      try {
+        const result = await api("/api/admin/term-rounding", {
+          method: "PUT",
+          body: JSON.stringify({ threshold: Number(body.threshold), minGrades: Number(body.minGrades) }),
+        });
+        log("Правила округления сохранены", result);
+      } catch (err) {
+        log("Ошибка", { error: err.message });
+      }
+    };
+    document.getElementById("loadTerms").onclick = async () => {
+      try {
+        const [terms, rounding] = await Promise.all([api("/api/admin/terms"), api("/api/admin/term-rounding")]);
+        document.getElementById("termsList").innerHTML = [
+          `<div class="item">Округление вверх с ${rounding.threshold}, минимум оценок: ${rounding.minGrades}</div>`,
+          ...terms.map((t) => `<div class="item">#${t.id} ${escapeHtml(t.name)} | ${t.academicYear} | ${t.startDate} — ${t.endDate}</div>`),
+        ].join("");
+      } catch (e) {
+        log("Ошибка загрузки периодов", { error: e.message });
+      }
+    };
+
+    document.getElementById("assignmentForm").onsubmit = submitForm("/api/admin/assignments", (body) => ({
+      ...body,
+      teacherId: Number(body.teacherId),
+    }));
+    document.getElementById("loadAssignments").onclick = async () => {
+      try {
+        const assignments = await api("/api/admin/assignments");
+        document.getElementById("assignmentsList").innerHTML = assignments
+          .map((a) => `<div class="item">#${a.id} учитель #${a.teacherId} | ${a.className}${a.group ? ` (${a.group})` : ""} | ${a.subject}</div>`)
+          .join("");
+      } catch (e) {
+        log("Ошибка загрузки закреплений", { error: e.message });
+      }
+    };
+
+    document.getElementById("inviteForm").onsubmit = submitForm("/api/admin/invites");
+
     document.getElementById("scheduleImportForm").onsubmit = async (e) => {
       e.preventDefault();
       const form = e.target;
@@ -344,6 +647,7 @@ function renderDashboard() {
     teacherJournal.subject = "";
 
     dashboard.innerHTML = [
+      card("Мои уроки", `<button id="loadTeacherSchedule" type="button">Загрузить</button><div id="teacherScheduleList" class="list"></div>`),
       card("Добавить урок в расписание", `
         <form id="scheduleForm" class="grid">
           ${formField("className")}
@@ -357,14 +661,23 @@ function renderDashboard() {
       `),
       card("Журнал оценок учителя", `
         <div class="grid">
-          <label>Предмет учителя<input id="teacherSubjectInput" placeholder="например, Математика" /></label>
-          <button id="saveTeacherSubjectBtn" type="button">Сохранить предмет</button>
-          <div class="item">Текущий предмет: <b id="teacherSubjectCurrent">не задан</b></div>
+          <label>Предмет<select id="teacherSubjectSelect"></select></label>
+          <label>Вид работы<select id="teacherGradeTypeSelect"></select></label>
+          <div class="item">Классы: <b id="teacherAssignments">нет закреплений</b></div>
           <button id="loadTeacherJournalBtn" type="button">Загрузить журнал</button>
         </div>
         <div id="teacherJournalWrap"></div>
         <div class="hint">Столбцы дат: неделя назад + сегодня + неделя вперед. Клик по ячейке выставляет оценку и комментарий.</div>
       `),
+      card("Итоговые оценки за период", `
+        <div class="grid">
+          <label>Период<select id="termSelect"></select></label>
+          <label>Класс<input id="termClassName" placeholder="например, 7A" /></label>
+          <button id="loadTermGradesBtn" type="button">Показать предложенные оценки</button>
+        </div>
+        <div id="termGradesList" class="list"></div>
+        <div class="hint">Оценка предлагается по средневзвешенному баллу за период. После утверждения оценки ученика по предмету за период блокируются.</div>
+      `),
       card("Выдать домашнее задание", `
         <form id="homeworkForm" class="grid">
           ${formField("className")}
@@ -377,15 +690,38 @@ function renderDashboard() {
     ].join("");
 
     document.getElementById("scheduleForm").onsubmit = submitForm("/api/teacher/schedule");
+    document.getElementById("loadTeacherSchedule").onclick = async () => {
+      try {
+        const entries = await api("/api/teacher/schedule");
+        document.getElementById("teacherScheduleList").innerHTML =
+          scheduleItems(entries, true) || `<div class="item">Уроков в расписании нет.</div>`;
+      } catch (e) {
+        log("Ошибка расписания", { error: e.message });
+      }
+    };
     document.getElementById("homeworkForm").onsubmit = submitForm("/api/teacher/homework");
     loadTeacherStudents();
     setupTeacherJournalActions();
+    setupTeacherTermGrades();
 
-    api("/api/teacher/subject")
+    api("/api/grade-types")
+      .then((types) => {
+        document.getElementById("teacherGradeTypeSelect").innerHTML = types
+          .map((t) => `<option value="${t.code}"${t.code === "oral" ? " selected" : ""}>${escapeHtml(t.name)} (×${t.weight})</option>`)
+          .join("");
+      })
+      .catch(() => {});
+
+    api("/api/teacher/assignments")
       .then((d) => {
-        teacherJournal.subject = d.subject || "";
-        document.getElementById("teacherSubjectInput").value = teacherJournal.subject;
-        document.getElementById("teacherSubjectCurrent").textContent = teacherJournal.subject || "не задан";
+        const subjects = d.subjects || [];
+        document.getElementById("teacherSubjectSelect").innerHTML = subjects
+          .map((subject) => `<option value="${escapeHtml(subject)}">${escapeHtml(subject)}</option>`)
+          .join("");
+        const assignments = (d.assignments || []).map(
+          (a) => `${a.className}${a.group ? ` (${a.group})` : ""} — ${a.subject}`,
+        );
+        document.getElementById("teacherAssignments").textContent = assignments.join(", ") || "нет закреплений";
       })
       .catch(() => {});
 
@@ -395,19 +731,21 @@ function renderDashboard() {
   dashboard.innerHTML = [
     card("Моё расписание", `<button id="loadSchedule">Загрузить</button><div id="scheduleList" class="list"></div>`),
     card("Мои оценки", `<button id="loadGrades">Загрузить</button><div id="gradesList"></div>`),
+    card("Итоговые оценки", `<button id="loadTermGrades">Загрузить</button><div id="termGradesList" class="list"></div>`),
     card("Моя домашка", `<button id="loadHomework">Загрузить</button><div id="homeworkList" class="list"></div>`),
   ].join("");
 
   document.getElementById("loadSchedule").onclick = async () => {
     try {
       const data = await api("/api/student/schedule");
-      if (!data.imageData) {
+      if (!data.imageData && !(data.entries || []).length) {
         document.getElementById("scheduleList").innerHTML = `<div class="item">Расписание пока не найдено для вашего класса.</div>`;
         return;
       }
       document.getElementById("scheduleList").innerHTML = `
         <div class="item">Класс: ${data.className || "-"}</div>
-        <div class="item"><img src="${data.imageData}" alt="Расписание класса" style="max-width:100%;height:auto;border-radius:8px;" /></div>
+        ${scheduleItems(data.entries)}
+        ${data.imageData ? `<div class="item"><img src="${data.imageData}" alt="Расписание класса" style="max-width:100%;height:auto;border-radius:8px;" /></div>` : ""}
       `;
     } catch (e) {
       log("Ошибка расписания", { error: e.message });
@@ -416,13 +754,25 @@ function renderDashboard() {
 
   document.getElementById("loadGrades").onclick = async () => {
     try {
-      const rows = await api("/api/student/grades");
-      document.getElementById("gradesList").innerHTML = buildStudentGradesTable(rows);
+      const data = await api("/api/student/grades");
+      document.getElementById("gradesList").innerHTML = buildStudentGradesTable(data.grades, data.averages, data.scales);
     } catch (e) {
       log("Ошибка оценок", { error: e.message });
     }
   };
 
+  document.getElementById("loadTermGrades").onclick = async () => {
+    try {
+      const [terms, rows] = await Promise.all([api("/api/terms"), api("/api/student/term-grades")]);
+      const names = new Map(terms.map((t) => [t.id, t.name]));
+      document.getElementById("termGradesList").innerHTML = rows.length
+        ? rows.map((r) => `<div class="item">${escapeHtml(names.get(r.termId) || `#${r.termId}`)} | ${escapeHtml(r.subject)}: <b>${r.value}</b> (средний ${r.average.toFixed(2)})</div>`).join("")
+        : `<div class="item">Итоговых оценок пока нет.</div>`;
+    } catch (e) {
+      log("Ошибка итоговых оценок", { error: e.message });
+    }
+  };
+
   document.getElementById("loadHomework").onclick = async () => {
     try {
       const rows = await api("/api/student/homework");
@@ -457,9 +807,8 @@ document.getElementById("loginForm").onsubmit = async (e) => {
   const body = Object.fromEntries(fd.entries());
   try {
     const result = await api("/api/login", { method: "POST", body: JSON.stringify(body) });
-    state.token = result.token;
+    saveSession(result);
     state.user = result.user;
-    localStorage.setItem("token", state.token);
     renderSession();
     log("Вход выполнен", state.user);
   } catch (err) {
@@ -472,21 +821,26 @@ document.getElementById("registerForm").onsubmit = async (e) => {
   const form = e.target;
   const fd = new FormData(form);
   const body = Object.fromEntries(fd.entries());
-  if (body.role !== "student") body.className = "";
+  body.inviteCode = (body.inviteCode || "").trim();
+  if (body.inviteCode) body.className = "";
+  else body.role = "student";
   try {
     const result = await api("/api/register", { method: "POST", body: JSON.stringify(body) });
     form.reset();
-    registerRole.dispatchEvent(new Event("change"));
+    registerInvite.dispatchEvent(new Event("input"));
     log("Регистрация успешна", result);
   } catch (err) {
     log("Ошибка регистрации", { error: err.message });
   }
 };
 
-logoutBtn.onclick = () => {
-  state.token = "";
-  state.user = null;
-  localStorage.removeItem("token");
+logoutBtn.onclick = async () => {
+  try {
+    await api("/api/logout", { method: "POST" });
+  } catch {
+    // сессия уже могла истечь — локальные токены удаляем в любом случае
+  }
+  clearSession();
   renderSession();
 };
 
@@ -498,11 +852,10 @@ async function bootstrap() {
   try {
     state.user = await api("/api/me");
   } catch {
-    state.token = "";
-    localStorage.removeItem("token");
+    clearSession();
   }
   renderSession();
 }
 
 bootstrap();
-registerRole.dispatchEvent(new Event("change"));
+registerInvite.dispatchEvent(new Event("input"));
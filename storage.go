@@ -1,13 +1,13 @@
 package main
 
 import (
-	"crypto/rand"
 	"encoding/base64"
-	"encoding/hex"
 	"errors"
+	"fmt"
 	"sort"
 	"strings"
 	"sync"
+	"time"
 )
 
 // Storage — потокобезопасное in-memory хранилище приложения.
@@ -15,55 +15,153 @@ type Storage struct {
 	mu       sync.RWMutex
 	users    map[int64]User
 	emailIdx map[string]int64
-	tokens   map[string]int64
+	sessions map[string]Session
+	// tokens и refreshTokens отображают хеш токена в ID сессии.
+	tokens        map[string]string
+	refreshTokens map[string]string
+
+	invites     map[int64]Invite
+	inviteCodes map[string]int64
+	// resets — действующие коды сброса пароля по ID пользователя.
+	resets map[int64]PasswordReset
+	// classes — реестр классов по каноничному имени.
+	classes map[string]Class
+	// archives — архивы прошедших учебных лет по ключу archiveKey.
+	archives map[string]YearArchive
+	audit    map[int64]AuditEntry
 
 	schedule map[int64]ScheduleEntry
 	photos   map[string]SchedulePhoto
 	grades   map[int64]Grade
 	homework map[int64]Homework
-	subjects map[int64]string
-
-	nextUserID     int64
-	nextScheduleID int64
-	nextGradeID    int64
-	nextHomeworkID int64
+	// subjects — справочник предметов.
+	subjects map[int64]Subject
+	// assignments — закрепления учителей за предметами и классами.
+	assignments map[int64]Assignment
+	// gradeRevisions — история исправлений и удалений оценок.
+	gradeRevisions map[int64]GradeRevision
+	// gradeWeights — веса видов работ, измененные администратором; остальные берутся из gradeTypeCatalog.
+	gradeWeights map[string]float64
+	// terms — учебные периоды, termGrades — утвержденные итоговые оценки за них.
+	terms      map[int64]Term
+	termGrades map[int64]TermGrade
+	// rounding — правила округления итоговых оценок.
+	rounding TermRounding
+	// scales — шкалы оценивания.
+	scales map[int64]GradingScale
+	// attendance — отметки посещаемости.
+	attendance map[int64]Attendance
+	// lessons — журнал проведенных уроков.
+	lessons map[int64]Lesson
+
+	nextUserID          int64
+	nextScheduleID      int64
+	nextGradeID         int64
+	nextHomeworkID      int64
+	nextInviteID        int64
+	nextAuditID         int64
+	nextAssignmentID    int64
+	nextSubjectID       int64
+	nextGradeRevisionID int64
+	nextTermID          int64
+	nextTermGradeID     int64
+	nextScaleID         int64
+	nextAttendanceID    int64
+	nextLessonID        int64
+
+	journal persister
+	seq     int64
 }
 
-// NewStorage создает и инициализирует хранилище начальными структурами.
-func NewStorage() *Storage {
+// errStorageClosed — хранилище закрыто, изменения больше не принимаются.
+var errStorageClosed = errors.New("storage is closed")
+
+// NewStorage создает хранилище поверх журнала j: состояние восстанавливается из него,
+// а все изменения записываются в него. Хранилище владеет журналом и закрывает его в Close.
+func NewStorage(j persister) (*Storage, error) {
 	s := &Storage{
 		users:    make(map[int64]User),
 		emailIdx: make(map[string]int64),
-		tokens:   make(map[string]int64),
+		sessions: make(map[string]Session),
 		schedule: make(map[int64]ScheduleEntry),
 		photos:   make(map[string]SchedulePhoto),
 		grades:   make(map[int64]Grade),
 		homework: make(map[int64]Homework),
-		subjects: make(map[int64]string),
 
-		nextUserID:     1,
-		nextScheduleID: 1,
-		nextGradeID:    1,
-		nextHomeworkID: 1,
+		subjects:       make(map[int64]Subject),
+		assignments:    make(map[int64]Assignment),
+		gradeRevisions: make(map[int64]GradeRevision),
+		gradeWeights:   make(map[string]float64),
+		terms:          make(map[int64]Term),
+		termGrades:     make(map[int64]TermGrade),
+		rounding:       defaultTermRounding,
+		scales:         make(map[int64]GradingScale),
+		attendance:     make(map[int64]Attendance),
+		lessons:        make(map[int64]Lesson),
+
+		tokens:        make(map[string]string),
+		refreshTokens: make(map[string]string),
+
+		invites:     make(map[int64]Invite),
+		inviteCodes: make(map[string]int64),
+		resets:      make(map[int64]PasswordReset),
+		classes:     make(map[string]Class),
+		archives:    make(map[string]YearArchive),
+		audit:       make(map[int64]AuditEntry),
+
+		nextUserID:          1,
+		nextScheduleID:      1,
+		nextGradeID:         1,
+		nextHomeworkID:      1,
+		nextInviteID:        1,
+		nextAuditID:         1,
+		nextAssignmentID:    1,
+		nextSubjectID:       1,
+		nextGradeRevisionID: 1,
+		nextTermID:          1,
+		nextTermGradeID:     1,
+		nextScaleID:         1,
+		nextAttendanceID:    1,
+		nextLessonID:        1,
 	}
-	s.seed()
-	return s
-}
-
-// getTeacherSubject возвращает закрепленный предмет учителя.
-func (s *Storage) getTeacherSubject(teacherID int64) string {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	return strings.TrimSpace(s.subjects[teacherID])
+	seq, err := j.load(s.applyLocked)
+	if err != nil {
+		j.close()
+		return nil, err
+	}
+	s.seq = seq
+	s.journal = j
+	if len(s.users) == 0 {
+		if err := s.seed(); err != nil {
+			s.Close()
+			return nil, err
+		}
+	}
+	if err := s.seedGradingScales(); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
 }
 
-// setTeacherSubject сохраняет закрепленный предмет учителя.
-func (s *Storage) setTeacherSubject(teacherID int64, subject string) string {
+// Close сохраняет снимок состояния и закрывает журнал.
+func (s *Storage) Close() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	subject = strings.TrimSpace(subject)
-	s.subjects[teacherID] = subject
-	return subject
+	if s.journal == nil {
+		return nil
+	}
+	err := s.journal.snapshot(s.dumpLocked())
+	if cerr := s.journal.close(); err == nil {
+		err = cerr
+	}
+	s.journal = nil
+	return err
+}
+
+// emailKey приводит email к ключу индекса.
+func emailKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
 }
 
 // listGradesByTeacherSubjectDateRange возвращает оценки учителя по предмету и диапазону дат.
@@ -91,17 +189,18 @@ func (s *Storage) listGradesByTeacherSubjectDateRange(teacherID int64, subject,
 }
 
 // seed добавляет стартовые данные (дефолтного администратора).
-func (s *Storage) seed() {
-	admin := User{
-		ID:           s.nextUserID,
+func (s *Storage) seed() error {
+	hash, err := hashPassword("admin123")
+	if err != nil {
+		return err
+	}
+	_, err = s.createUser(User{
 		FullName:     "System Admin",
 		Email:        "admin@school.local",
-		PasswordHash: hashPassword("admin123"),
+		PasswordHash: hash,
 		Role:         RoleAdmin,
-	}
-	s.users[admin.ID] = admin
-	s.emailIdx[strings.ToLower(admin.Email)] = admin.ID
-	s.nextUserID++
+	})
+	return err
 }
 
 // createUser создает нового пользователя и индексирует его email.
@@ -109,26 +208,166 @@ func (s *Storage) createUser(u User) (User, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	emailKey := strings.ToLower(strings.TrimSpace(u.Email))
-	if emailKey == "" {
+	key := emailKey(u.Email)
+	if key == "" {
 		return User{}, errors.New("email is required")
 	}
-	if _, exists := s.emailIdx[emailKey]; exists {
+	if _, exists := s.emailIdx[key]; exists {
 		return User{}, errors.New("email already exists")
 	}
+	u.ClassName = normalizeClassName(u.ClassName)
+	if u.ClassName != "" {
+		if err := s.requireClassLocked(u.ClassName); err != nil {
+			return User{}, err
+		}
+	}
 
 	u.ID = s.nextUserID
-	s.nextUserID++
-	s.users[u.ID] = u
-	s.emailIdx[emailKey] = u.ID
+	if err := s.commitLocked(journalRecord{Op: opPutUser, User: &persistedUser{User: u, PasswordHash: u.PasswordHash}}); err != nil {
+		return User{}, err
+	}
 	return u, nil
 }
 
+// createUsers создает пользователей одной операцией: либо все, либо ни одного.
+func (s *Storage) createUsers(users []User) ([]User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seen := make(map[string]bool, len(users))
+	rec := journalRecord{Op: opPutUsers, Users: make([]persistedUser, 0, len(users))}
+	for i := range users {
+		key := emailKey(users[i].Email)
+		if key == "" {
+			return nil, errors.New("email is required")
+		}
+		if _, exists := s.emailIdx[key]; exists || seen[key] {
+			return nil, fmt.Errorf("email already exists: %s", users[i].Email)
+		}
+		seen[key] = true
+		users[i].ClassName = normalizeClassName(users[i].ClassName)
+		if users[i].ClassName != "" {
+			if err := s.requireClassLocked(users[i].ClassName); err != nil {
+				return nil, err
+			}
+		}
+		users[i].ID = s.nextUserID + int64(i)
+		rec.Users = append(rec.Users, persistedUser{User: users[i], PasswordHash: users[i].PasswordHash})
+	}
+	if err := s.commitLocked(rec); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// setPasswordHash заменяет хеш пароля пользователя.
+func (s *Storage) setPasswordHash(userID int64, hash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[userID]
+	if !ok {
+		return errors.New("user not found")
+	}
+	return s.commitLocked(journalRecord{Op: opPutUser, User: &persistedUser{User: u, PasswordHash: hash}})
+}
+
+// errUserNotFound — пользователь не найден.
+var errUserNotFound = errors.New("user not found")
+
+// userPatch — изменяемые поля пользователя; nil означает «не менять».
+type userPatch struct {
+	FullName  *string
+	Email     *string
+	Role      *Role
+	ClassName *string
+}
+
+// updateUser применяет изменения к текущим полям пользователя под блокировкой (пароль не
+// меняется) и одной операцией записывает в журнал аудита, кто и что изменил. При смене
+// роли прежний класс сбрасывается (новый можно передать в том же запросе), а сессии
+// пользователя завершаются. Свою роль
+// администратор менять не может. Если поля не изменились, запись не создается и changes пуст.
+func (s *Storage) updateUser(id int64, p userPatch, actorID int64) (User, []FieldChange, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev, ok := s.users[id]
+	if !ok {
+		return User{}, nil, errUserNotFound
+	}
+	u := prev
+	if p.FullName != nil {
+		u.FullName = strings.TrimSpace(*p.FullName)
+		if u.FullName == "" {
+			return User{}, nil, errors.New("fullName must not be empty")
+		}
+	}
+	if p.Email != nil {
+		u.Email = strings.TrimSpace(*p.Email)
+	}
+	if p.Role != nil && *p.Role != prev.Role {
+		if id == actorID {
+			return User{}, nil, errors.New("cannot change your own role")
+		}
+		u.Role = *p.Role
+		u.ClassName = ""
+	}
+	if p.ClassName != nil {
+		u.ClassName = *p.ClassName
+	}
+	u.ClassName = normalizeClassName(u.ClassName)
+	if err := validateUserRole(u.Role, u.ClassName); err != nil {
+		return User{}, nil, err
+	}
+	key := emailKey(u.Email)
+	if key == "" {
+		return User{}, nil, errors.New("email is required")
+	}
+	if other, exists := s.emailIdx[key]; exists && other != id {
+		return User{}, nil, errors.New("email already exists")
+	}
+	if u.ClassName != "" && u.ClassName != prev.ClassName {
+		if err := s.requireClassLocked(u.ClassName); err != nil {
+			return User{}, nil, err
+		}
+	}
+
+	changes := []FieldChange{}
+	for _, f := range []FieldChange{
+		{Field: "fullName", From: prev.FullName, To: u.FullName},
+		{Field: "email", From: prev.Email, To: u.Email},
+		{Field: "role", From: string(prev.Role), To: string(u.Role)},
+		{Field: "className", From: prev.ClassName, To: u.ClassName},
+	} {
+		if f.From != f.To {
+			changes = append(changes, f)
+		}
+	}
+	if len(changes) == 0 {
+		return prev, changes, nil
+	}
+	if err := s.commitLocked(journalRecord{
+		Op:   opUpdateUser,
+		User: &persistedUser{User: u, PasswordHash: u.PasswordHash},
+		Audit: &AuditEntry{
+			ID:       s.nextAuditID,
+			ActorID:  actorID,
+			Action:   "update",
+			Entity:   "user",
+			EntityID: u.ID,
+			Changes:  changes,
+			At:       time.Now().UTC(),
+		},
+	}); err != nil {
+		return User{}, nil, err
+	}
+	return u, changes, nil
+}
+
 // findUserByEmail ищет пользователя по email.
 func (s *Storage) findUserByEmail(email string) (User, bool) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	id, ok := s.emailIdx[strings.ToLower(strings.TrimSpace(email))]
+	id, ok := s.emailIdx[emailKey(email)]
 	if !ok {
 		return User{}, false
 	}
@@ -155,13 +394,13 @@ func (s *Storage) listUsers() []User {
 	return res
 }
 
-// listStudentsSortedByClass возвращает учеников, отсортированных по классу и ФИО.
+// listStudentsSortedByClass возвращает учеников (без выпускников), отсортированных по классу и ФИО.
 func (s *Storage) listStudentsSortedByClass() []User {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	res := make([]User, 0)
 	for _, u := range s.users {
-		if u.Role == RoleStudent {
+		if u.Role == RoleStudent && !u.Archived {
 			res = append(res, u)
 		}
 	}
@@ -177,90 +416,119 @@ func (s *Storage) listStudentsSortedByClass() []User {
 }
 
 // deleteUser удаляет пользователя и все его активные токены.
-func (s *Storage) deleteUser(id int64) bool {
+func (s *Storage) deleteUser(id int64) (bool, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	u, ok := s.users[id]
-	if !ok {
-		return false
+	if _, ok := s.users[id]; !ok {
+		return false, nil
 	}
-	delete(s.users, id)
-	delete(s.emailIdx, strings.ToLower(u.Email))
-	for token, userID := range s.tokens {
-		if userID == id {
-			delete(s.tokens, token)
-		}
+	if err := s.commitLocked(journalRecord{Op: opDeleteUser, ID: id}); err != nil {
+		return false, err
 	}
-	return true
+	return true, nil
 }
 
-// createToken создает и сохраняет токен сессии.
-func (s *Storage) createToken(userID int64) (string, error) {
-	b := make([]byte, 24)
-	if _, err := rand.Read(b); err != nil {
-		return "", err
+// putUserLocked сохраняет пользователя в памяти и обновляет индекс email.
+func (s *Storage) putUserLocked(u User) {
+	if prev, ok := s.users[u.ID]; ok {
+		delete(s.emailIdx, emailKey(prev.Email))
 	}
-	token := hex.EncodeToString(b)
-	s.mu.Lock()
-	s.tokens[token] = userID
-	s.mu.Unlock()
-	return token, nil
+	s.users[u.ID] = u
+	s.emailIdx[emailKey(u.Email)] = u.ID
+	bumpCounter(&s.nextUserID, u.ID)
 }
 
-// userByToken возвращает пользователя по токену.
-func (s *Storage) userByToken(token string) (User, bool) {
-	s.mu.RLock()
-	userID, ok := s.tokens[token]
+// deleteUserLocked удаляет пользователя из памяти вместе с его сессиями, кодом сброса пароля
+// и закреплениями и снимает его с классного руководства.
+func (s *Storage) deleteUserLocked(id int64) {
+	u, ok := s.users[id]
 	if !ok {
-		s.mu.RUnlock()
-		return User{}, false
+		return
+	}
+	delete(s.users, id)
+	delete(s.emailIdx, emailKey(u.Email))
+	delete(s.resets, id)
+	s.revokeUserSessionsLocked(id)
+	s.dropTeacherRoleLocked(id)
+}
+
+// dropTeacherRoleLocked удаляет закрепления учителя и снимает его с классного руководства.
+func (s *Storage) dropTeacherRoleLocked(id int64) {
+	s.deleteTeacherAssignmentsLocked(id)
+	for name, c := range s.classes {
+		if c.HomeroomTeacherID == id {
+			c.HomeroomTeacherID = 0
+			s.classes[name] = c
+		}
 	}
-	u, ok := s.users[userID]
-	s.mu.RUnlock()
-	return u, ok
 }
 
-// addSchedule добавляет запись урока в расписание.
-func (s *Storage) addSchedule(entry ScheduleEntry) ScheduleEntry {
+// addSchedule добавляет запись урока в расписание. Учитель урока, если указан, должен
+// быть закреплен за предметом в классе.
+func (s *Storage) addSchedule(entry ScheduleEntry) (ScheduleEntry, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	entry.ClassName = normalizeClassName(entry.ClassName)
+	if err := s.requireClassLocked(entry.ClassName); err != nil {
+		return ScheduleEntry{}, err
+	}
+	sub, err := s.requireSubjectLocked(entry.Subject)
+	if err != nil {
+		return ScheduleEntry{}, err
+	}
+	entry.SubjectID, entry.Subject = sub.ID, sub.Name
+	if err := s.requireScheduleTeacherLocked(entry); err != nil {
+		return ScheduleEntry{}, err
+	}
 	entry.ID = s.nextScheduleID
-	s.nextScheduleID++
-	s.schedule[entry.ID] = entry
-	return entry
+	if err := s.commitLocked(journalRecord{Op: opPutSchedule, Schedule: []ScheduleEntry{entry}}); err != nil {
+		return ScheduleEntry{}, err
+	}
+	return entry, nil
 }
 
-// replaceSchedule полностью заменяет структурное расписание.
-func (s *Storage) replaceSchedule(entries []ScheduleEntry) int {
+// replaceSchedule полностью заменяет структурное расписание; учителя уроков проверяются,
+// как в addSchedule.
+func (s *Storage) replaceSchedule(entries []ScheduleEntry) (int, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.schedule = make(map[int64]ScheduleEntry)
-	s.nextScheduleID = 1
 	for i := range entries {
 		entries[i].ClassName = normalizeClassName(entries[i].ClassName)
-		entries[i].ID = s.nextScheduleID
-		s.schedule[entries[i].ID] = entries[i]
-		s.nextScheduleID++
+		if err := s.requireClassLocked(entries[i].ClassName); err != nil {
+			return 0, err
+		}
+		sub, err := s.requireSubjectLocked(entries[i].Subject)
+		if err != nil {
+			return 0, err
+		}
+		entries[i].SubjectID, entries[i].Subject = sub.ID, sub.Name
+		if err := s.requireScheduleTeacherLocked(entries[i]); err != nil {
+			return 0, err
+		}
+		entries[i].ID = int64(i + 1)
+	}
+	if err := s.commitLocked(journalRecord{Op: opReplaceSchedule, Schedule: entries}); err != nil {
+		return 0, err
 	}
-	return len(entries)
+	return len(entries), nil
 }
 
 // clearSchedule очищает структурное расписание и фото расписаний.
-func (s *Storage) clearSchedule() {
+func (s *Storage) clearSchedule() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.schedule = make(map[int64]ScheduleEntry)
-	s.photos = make(map[string]SchedulePhoto)
-	s.nextScheduleID = 1
+	return s.commitLocked(journalRecord{Op: opClearSchedule})
 }
 
 // setSchedulePhoto сохраняет фото расписания для класса.
-func (s *Storage) setSchedulePhoto(className, contentType string, raw []byte) SchedulePhoto {
+func (s *Storage) setSchedulePhoto(className, contentType string, raw []byte) (SchedulePhoto, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	className = normalizeClassName(className)
+	if err := s.requireClassLocked(className); err != nil {
+		return SchedulePhoto{}, err
+	}
 	if contentType == "" {
 		contentType = "image/jpeg"
 	}
@@ -269,8 +537,10 @@ func (s *Storage) setSchedulePhoto(className, contentType string, raw []byte) Sc
 		ContentType: contentType,
 		ImageData:   "data:" + contentType + ";base64," + base64.StdEncoding.EncodeToString(raw),
 	}
-	s.photos[className] = photo
-	return photo
+	if err := s.commitLocked(journalRecord{Op: opPutPhoto, Photo: &photo}); err != nil {
+		return SchedulePhoto{}, err
+	}
+	return photo, nil
 }
 
 // getSchedulePhotoByClass возвращает фото расписания конкретного класса.
@@ -292,7 +562,8 @@ func (s *Storage) schedulePhotoStats() map[string]int {
 	return classes
 }
 
-// listScheduleByClass возвращает структурные записи расписания класса.
+// listScheduleByClass возвращает структурные записи расписания класса, упорядоченные
+// по дню недели и времени начала.
 func (s *Storage) listScheduleByClass(className string) []ScheduleEntry {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -303,6 +574,22 @@ func (s *Storage) listScheduleByClass(className string) []ScheduleEntry {
 			res = append(res, entry)
 		}
 	}
+	sortSchedule(res)
+	return res
+}
+
+// listScheduleByTeacher возвращает уроки учителя по расписанию всех классов, упорядоченные
+// по дню недели и времени начала.
+func (s *Storage) listScheduleByTeacher(teacherID int64) []ScheduleEntry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	res := []ScheduleEntry{}
+	for _, entry := range s.schedule {
+		if entry.TeacherID == teacherID {
+			res = append(res, entry)
+		}
+	}
+	sortSchedule(res)
 	return res
 }
 
@@ -322,14 +609,18 @@ func (s *Storage) listAllScheduleLocked() []ScheduleEntry {
 	return res
 }
 
-// addGrade добавляет оценку.
-func (s *Storage) addGrade(g Grade) Grade {
+// addGrade добавляет оценку по предмету из справочника.
+func (s *Storage) addGrade(g Grade) (Grade, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	if err := s.prepareGradeLocked(&g); err != nil {
+		return Grade{}, err
+	}
 	g.ID = s.nextGradeID
-	s.nextGradeID++
-	s.grades[g.ID] = g
-	return g
+	if err := s.commitLocked(journalRecord{Op: opPutGrade, Grade: &g}); err != nil {
+		return Grade{}, err
+	}
+	return g, nil
 }
 
 // listGradesByStudent возвращает оценки конкретного ученика.
@@ -346,14 +637,23 @@ func (s *Storage) listGradesByStudent(studentID int64) []Grade {
 }
 
 // addHomework добавляет домашнее задание для класса.
-func (s *Storage) addHomework(hw Homework) Homework {
+func (s *Storage) addHomework(hw Homework) (Homework, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	hw.ClassName = normalizeClassName(hw.ClassName)
+	if err := s.requireClassLocked(hw.ClassName); err != nil {
+		return Homework{}, err
+	}
+	sub, err := s.requireSubjectLocked(hw.Subject)
+	if err != nil {
+		return Homework{}, err
+	}
+	hw.SubjectID, hw.Subject = sub.ID, sub.Name
 	hw.ID = s.nextHomeworkID
-	s.nextHomeworkID++
-	s.homework[hw.ID] = hw
-	return hw
+	if err := s.commitLocked(journalRecord{Op: opPutHomework, Homework: &hw}); err != nil {
+		return Homework{}, err
+	}
+	return hw, nil
 }
 
 // listHomeworkByClass возвращает домашние задания указанного класса.
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	// errAssignmentExists — такое закрепление (учитель, предмет, класс, группа) уже есть.
+	errAssignmentExists = errors.New("assignment already exists")
+	// errNoAssignment — учитель урока не закреплен за его предметом в этом классе.
+	errNoAssignment = errors.New("teacher is not assigned to this subject in the class")
+)
+
+// createAssignment закрепляет за учителем предмет в классе (и, если задано, в группе класса).
+func (s *Storage) createAssignment(a Assignment) (Assignment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a.Subject = strings.TrimSpace(a.Subject)
+	a.ClassName = normalizeClassName(a.ClassName)
+	a.Group = strings.TrimSpace(a.Group)
+	if a.Subject == "" {
+		return Assignment{}, errors.New("subject is required")
+	}
+	if u, ok := s.users[a.TeacherID]; !ok || u.Role != RoleTeacher {
+		return Assignment{}, errors.New("teacher not found")
+	}
+	if err := s.requireClassLocked(a.ClassName); err != nil {
+		return Assignment{}, err
+	}
+	sub, err := s.requireSubjectLocked(a.Subject)
+	if err != nil {
+		return Assignment{}, err
+	}
+	a.SubjectID, a.Subject = sub.ID, sub.Name
+	if s.findAssignmentLocked(a) != 0 {
+		return Assignment{}, errAssignmentExists
+	}
+	a.ID = s.nextAssignmentID
+	if a.CreatedAt.IsZero() {
+		a.CreatedAt = time.Now().UTC()
+	}
+	if err := s.commitLocked(journalRecord{Op: opPutAssignment, Assignment: &a}); err != nil {
+		return Assignment{}, err
+	}
+	return a, nil
+}
+
+// deleteAssignment снимает закрепление по ID.
+func (s *Storage) deleteAssignment(id int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.assignments[id]; !ok {
+		return false, nil
+	}
+	if err := s.commitLocked(journalRecord{Op: opDeleteAssignment, ID: id}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// listAssignments возвращает закрепления, отсортированные по классу, предмету и группе.
+// Нулевой teacherID и пустой className означают «без фильтра».
+func (s *Storage) listAssignments(teacherID int64, className string) []Assignment {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	className = normalizeClassName(className)
+	res := []Assignment{}
+	for _, a := range s.assignments {
+		if teacherID != 0 && a.TeacherID != teacherID {
+			continue
+		}
+		if className != "" && a.ClassName != className {
+			continue
+		}
+		res = append(res, a)
+	}
+	sortAssignments(res)
+	return res
+}
+
+// teacherSubjects возвращает отсортированный список предметов, закрепленных за учителем.
+func (s *Storage) teacherSubjects(teacherID int64) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	seen := map[string]bool{}
+	res := []string{}
+	for _, a := range s.assignments {
+		if a.TeacherID == teacherID && !seen[a.Subject] {
+			seen[a.Subject] = true
+			res = append(res, a.Subject)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+// sortAssignments упорядочивает закрепления по классу, предмету, группе и ID.
+func sortAssignments(list []Assignment) {
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if a.ClassName != b.ClassName {
+			return a.ClassName < b.ClassName
+		}
+		if a.Subject != b.Subject {
+			return a.Subject < b.Subject
+		}
+		if a.Group != b.Group {
+			return a.Group < b.Group
+		}
+		return a.ID < b.ID
+	})
+}
+
+// findAssignmentLocked возвращает ID закрепления с теми же учителем, предметом, классом и группой.
+func (s *Storage) findAssignmentLocked(a Assignment) int64 {
+	for id, cur := range s.assignments {
+		if cur.TeacherID == a.TeacherID && cur.ClassName == a.ClassName &&
+			strings.EqualFold(cur.Subject, a.Subject) && strings.EqualFold(cur.Group, a.Group) {
+			return id
+		}
+	}
+	return 0
+}
+
+// requireScheduleTeacherLocked проверяет, что учитель урока закреплен за его предметом
+// в классе урока. Уроки без учителя допустимы.
+func (s *Storage) requireScheduleTeacherLocked(entry ScheduleEntry) error {
+	if entry.TeacherID == 0 {
+		return nil
+	}
+	for _, a := range s.assignments {
+		if a.TeacherID == entry.TeacherID && a.ClassName == entry.ClassName && strings.EqualFold(a.Subject, entry.Subject) {
+			return nil
+		}
+	}
+	return errNoAssignment
+}
+
+// putAssignmentLocked сохраняет закрепление в памяти.
+func (s *Storage) putAssignmentLocked(a Assignment) {
+	s.assignments[a.ID] = a
+	bumpCounter(&s.nextAssignmentID, a.ID)
+}
+
+// dedupeAssignmentsLocked удаляет повторные закрепления, оставляя самое раннее.
+func (s *Storage) dedupeAssignmentsLocked() {
+	ids := make([]int64, 0, len(s.assignments))
+	for id := range s.assignments {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		a := s.assignments[id]
+		delete(s.assignments, id)
+		if s.findAssignmentLocked(a) == 0 {
+			s.assignments[id] = a
+		}
+	}
+}
+
+// deleteTeacherAssignmentsLocked снимает все закрепления учителя.
+func (s *Storage) deleteTeacherAssignmentsLocked(teacherID int64) {
+	for id, a := range s.assignments {
+		if a.TeacherID == teacherID {
+			delete(s.assignments, id)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Статусы отметок посещаемости.
+const (
+	attendancePresent = "present"
+	attendanceAbsent  = "absent"
+	attendanceLate    = "late"
+	attendanceExcused = "excused"
+)
+
+// errUnknownAttendanceStatus — статус отметки не из списка present, absent, late, excused.
+var errUnknownAttendanceStatus = errors.New("status must be one of present, absent, late, excused")
+
+// attendanceSheet — отметки учителя за урок предмета в классе в дату; в Marks
+// учитываются только StudentID, Status и Comment. LessonID — запись журнала уроков
+// (0 — единственный записанный урок предмета в эту дату, если он есть).
+type attendanceSheet struct {
+	ClassName string
+	Subject   string
+	Date      string
+	LessonID  int64
+	TeacherID int64
+	Marks     []Attendance
+}
+
+// attendanceFilter — отбор отметок; пустые поля означают «без фильтра», даты включительно.
+type attendanceFilter struct {
+	StudentID int64
+	ClassName string
+	Subject   string
+	LessonID  int64
+	From      string
+	To        string
+}
+
+// markAttendance проверяет все отметки урока и сохраняет их одной операцией журнала.
+// Отметка ученика за тот же предмет, дату и урок заменяется. При ошибках в строках
+// возвращается *batchError, и ничего не сохраняется.
+func (s *Storage) markAttendance(sheet attendanceSheet) ([]Attendance, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	className := normalizeClassName(sheet.ClassName)
+	if err := s.requireClassLocked(className); err != nil {
+		return nil, err
+	}
+	sub, err := s.requireSubjectLocked(sheet.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := time.Parse("2006-01-02", sheet.Date); err != nil {
+		return nil, errors.New("date must be YYYY-MM-DD")
+	}
+	if len(sheet.Marks) == 0 {
+		return nil, errors.New("marks are required")
+	}
+	lessonID, err := s.lessonForLocked(sheet.LessonID, className, sub.ID, sheet.Date)
+	if err != nil {
+		return nil, err
+	}
+	existing := map[int64]Attendance{}
+	for _, a := range s.attendance {
+		if a.SubjectID == sub.ID && a.Date == sheet.Date && a.LessonID == lessonID {
+			existing[a.StudentID] = a
+		}
+	}
+	now := time.Now().UTC()
+	nextID := s.nextAttendanceID
+	res := make([]Attendance, 0, len(sheet.Marks))
+	batchErr := &batchError{}
+	seen := map[int64]bool{}
+	for i, m := range sheet.Marks {
+		status := strings.ToLower(strings.TrimSpace(m.Status))
+		u, ok := s.users[m.StudentID]
+		var rowErr string
+		switch {
+		case !ok || u.Role != RoleStudent:
+			rowErr = "student not found"
+		case u.ClassName != className:
+			rowErr = "student is not in class " + className
+		case seen[m.StudentID]:
+			rowErr = "student is listed twice"
+		case status != attendancePresent && status != attendanceAbsent && status != attendanceLate && status != attendanceExcused:
+			rowErr = errUnknownAttendanceStatus.Error()
+		}
+		seen[m.StudentID] = true
+		if rowErr != "" {
+			batchErr.Rows = append(batchErr.Rows, rowError{Row: i, StudentID: m.StudentID, Error: rowErr})
+			continue
+		}
+		a := Attendance{
+			StudentID: m.StudentID,
+			ClassName: className,
+			SubjectID: sub.ID,
+			Subject:   sub.Name,
+			Date:      sheet.Date,
+			LessonID:  lessonID,
+			Status:    status,
+			Comment:   strings.TrimSpace(m.Comment),
+			TeacherID: sheet.TeacherID,
+			MarkedAt:  now,
+		}
+		if prev, ok := existing[m.StudentID]; ok {
+			a.ID = prev.ID
+		} else {
+			a.ID = nextID
+			nextID++
+		}
+		res = append(res, a)
+	}
+	if len(batchErr.Rows) > 0 {
+		return nil, batchErr
+	}
+	if err := s.commitLocked(journalRecord{Op: opPutAttendance, Attendance: res}); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// listAttendance возвращает отметки по фильтру, упорядоченные по дате, предмету и ученику.
+func (s *Storage) listAttendance(f attendanceFilter) []Attendance {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	res := []Attendance{}
+	var subjectID int64
+	if strings.TrimSpace(f.Subject) != "" {
+		sub, ok := s.findSubjectLocked(f.Subject)
+		if !ok {
+			return res
+		}
+		subjectID = sub.ID
+	}
+	className := normalizeClassName(f.ClassName)
+	for _, a := range s.attendance {
+		if !f.matches(a) || (className != "" && a.ClassName != className) || (subjectID != 0 && a.SubjectID != subjectID) {
+			continue
+		}
+		res = append(res, a)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Date != res[j].Date {
+			return res[i].Date < res[j].Date
+		}
+		if res[i].Subject != res[j].Subject {
+			return res[i].Subject < res[j].Subject
+		}
+		return res[i].StudentID < res[j].StudentID
+	})
+	return res
+}
+
+// matches проверяет ученика, урок и период отметки.
+func (f attendanceFilter) matches(a Attendance) bool {
+	if (f.StudentID != 0 && a.StudentID != f.StudentID) || (f.LessonID != 0 && a.LessonID != f.LessonID) {
+		return false
+	}
+	return (f.From == "" || a.Date >= f.From) && (f.To == "" || a.Date <= f.To)
+}
+
+// putAttendanceLocked сохраняет отметку в памяти.
+func (s *Storage) putAttendanceLocked(a Attendance) {
+	s.attendance[a.ID] = a
+	bumpCounter(&s.nextAttendanceID, a.ID)
+}
@@ -0,0 +1,28 @@
+package main
+
+import "sort"
+
+// listAudit возвращает записи аудита, новые первыми. Пустой entity и нулевой
+// entityID означают «без фильтра».
+func (s *Storage) listAudit(entity string, entityID int64) []AuditEntry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	res := []AuditEntry{}
+	for _, entry := range s.audit {
+		if entity != "" && entry.Entity != entity {
+			continue
+		}
+		if entityID != 0 && entry.EntityID != entityID {
+			continue
+		}
+		res = append(res, entry)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].ID > res[j].ID })
+	return res
+}
+
+// putAuditLocked сохраняет запись аудита в памяти.
+func (s *Storage) putAuditLocked(entry AuditEntry) {
+	s.audit[entry.ID] = entry
+	bumpCounter(&s.nextAuditID, entry.ID)
+}
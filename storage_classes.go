@@ -0,0 +1,190 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// errUnknownClass — класс не зарегистрирован в реестре.
+	errUnknownClass = errors.New("unknown class")
+	// errClassInUse — класс нельзя удалить, пока на него ссылаются данные.
+	errClassInUse = errors.New("class is in use")
+)
+
+// parseClassName раскладывает каноничное имя класса (например, 7A) на параллель и литеру.
+func parseClassName(name string) (grade int, letter string) {
+	i := 0
+	for i < len(name) && name[i] >= '0' && name[i] <= '9' {
+		i++
+	}
+	grade, _ = strconv.Atoi(name[:i])
+	return grade, name[i:]
+}
+
+// currentAcademicYear возвращает учебный год вида 2026/2027; год начинается 1 сентября.
+func currentAcademicYear(now time.Time) string {
+	start := now.Year()
+	if now.Month() < time.September {
+		start--
+	}
+	return fmt.Sprintf("%d/%d", start, start+1)
+}
+
+// validAcademicYear проверяет формат учебного года: 2026/2027.
+func validAcademicYear(year string) bool {
+	first, second, ok := strings.Cut(year, "/")
+	if !ok || len(first) != 4 || len(second) != 4 {
+		return false
+	}
+	a, err1 := strconv.Atoi(first)
+	b, err2 := strconv.Atoi(second)
+	return err1 == nil && err2 == nil && b == a+1
+}
+
+// createClass регистрирует класс. Имя вычисляется из параллели и литеры.
+func (s *Storage) createClass(c Class) (Class, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c.Name = normalizeClassName(strconv.Itoa(c.Grade) + c.Letter)
+	if c.Grade <= 0 {
+		return Class{}, errors.New("grade must be positive")
+	}
+	if _, exists := s.classes[c.Name]; exists {
+		return Class{}, fmt.Errorf("class %s already exists", c.Name)
+	}
+	if err := s.validateHomeroomLocked(c.HomeroomTeacherID); err != nil {
+		return Class{}, err
+	}
+	if err := s.requireScaleLocked(c.ScaleID); err != nil {
+		return Class{}, err
+	}
+	if err := s.commitLocked(journalRecord{Op: opPutClass, Class: &c}); err != nil {
+		return Class{}, err
+	}
+	return c, nil
+}
+
+// updateClass меняет учебный год, классного руководителя и шкалу оценивания; имя,
+// параллель и литера неизменны. Выставленные оценки сохраняют свою шкалу.
+func (s *Storage) updateClass(c Class) (Class, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev, ok := s.classes[c.Name]
+	if !ok {
+		return Class{}, fmt.Errorf("%w %q", errUnknownClass, c.Name)
+	}
+	if err := s.validateHomeroomLocked(c.HomeroomTeacherID); err != nil {
+		return Class{}, err
+	}
+	if err := s.requireScaleLocked(c.ScaleID); err != nil {
+		return Class{}, err
+	}
+	prev.AcademicYear = c.AcademicYear
+	prev.HomeroomTeacherID = c.HomeroomTeacherID
+	prev.ScaleID = c.ScaleID
+	if err := s.commitLocked(journalRecord{Op: opPutClass, Class: &prev}); err != nil {
+		return Class{}, err
+	}
+	return prev, nil
+}
+
+// deleteClass удаляет класс, если на него не ссылаются ученики, расписание, ДЗ, приглашения,
+// закрепления учителей, отметки посещаемости и записи журнала уроков.
+func (s *Storage) deleteClass(name string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name = normalizeClassName(name)
+	if _, ok := s.classes[name]; !ok {
+		return false, nil
+	}
+	if s.classUsedLocked()[name] {
+		return false, fmt.Errorf("%w: %s", errClassInUse, name)
+	}
+	if err := s.commitLocked(journalRecord{Op: opDeleteClass, ClassName: name}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// getClass возвращает класс по имени в любой записи (7а, 7 A, ...).
+func (s *Storage) getClass(name string) (Class, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c, ok := s.classes[normalizeClassName(name)]
+	return c, ok
+}
+
+// listClasses возвращает классы, упорядоченные по параллели и литере.
+func (s *Storage) listClasses() []Class {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	res := make([]Class, 0, len(s.classes))
+	for _, c := range s.classes {
+		res = append(res, c)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Grade != res[j].Grade {
+			return res[i].Grade < res[j].Grade
+		}
+		return res[i].Letter < res[j].Letter
+	})
+	return res
+}
+
+// requireClassLocked проверяет, что класс с каноничным именем name зарегистрирован.
+func (s *Storage) requireClassLocked(name string) error {
+	if _, ok := s.classes[name]; !ok {
+		return fmt.Errorf("%w %q", errUnknownClass, name)
+	}
+	return nil
+}
+
+// validateHomeroomLocked проверяет, что классный руководитель — существующий учитель.
+func (s *Storage) validateHomeroomLocked(teacherID int64) error {
+	if teacherID == 0 {
+		return nil
+	}
+	if u, ok := s.users[teacherID]; !ok || u.Role != RoleTeacher {
+		return errors.New("homeroom teacher not found")
+	}
+	return nil
+}
+
+// classUsedLocked собирает имена классов, на которые ссылаются данные хранилища.
+func (s *Storage) classUsedLocked() map[string]bool {
+	used := map[string]bool{}
+	for _, u := range s.users {
+		if u.ClassName != "" && !u.Archived {
+			used[u.ClassName] = true
+		}
+	}
+	for _, entry := range s.schedule {
+		used[entry.ClassName] = true
+	}
+	for _, hw := range s.homework {
+		used[hw.ClassName] = true
+	}
+	for _, a := range s.attendance {
+		used[a.ClassName] = true
+	}
+	for _, l := range s.lessons {
+		used[l.ClassName] = true
+	}
+	for className := range s.photos {
+		used[className] = true
+	}
+	for _, a := range s.assignments {
+		used[a.ClassName] = true
+	}
+	for _, inv := range s.invites {
+		if inv.ClassName != "" && inv.UsedBy == 0 {
+			used[inv.ClassName] = true
+		}
+	}
+	return used
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"strings"
+)
+
+// errUnknownGradeType — вид работы не входит в gradeTypeCatalog.
+var errUnknownGradeType = errors.New("unknown grade type")
+
+// defaultGradeType — вид работы для оценок, у которых он не указан (в том числе
+// для оценок, выставленных до появления видов работ).
+const defaultGradeType = "oral"
+
+// maxGradeWeight — верхняя граница веса вида работы.
+const maxGradeWeight = 10
+
+// gradeTypeCatalog — виды работ с весами по умолчанию. Набор видов фиксирован,
+// администратор меняет только веса.
+var gradeTypeCatalog = []GradeType{
+	{Code: "test", Name: "Тест", Weight: 2},
+	{Code: "control", Name: "Контрольная работа", Weight: 3},
+	{Code: "homework", Name: "Домашняя работа", Weight: 1},
+	{Code: "oral", Name: "Устный ответ", Weight: 1},
+	{Code: "lab", Name: "Лабораторная работа", Weight: 2},
+}
+
+// listGradeTypes возвращает виды работ с действующими весами в порядке каталога.
+func (s *Storage) listGradeTypes() []GradeType {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	res := make([]GradeType, 0, len(gradeTypeCatalog))
+	for _, t := range gradeTypeCatalog {
+		t.Weight = s.gradeWeightLocked(t.Code)
+		res = append(res, t)
+	}
+	return res
+}
+
+// setGradeTypeWeight меняет вес вида работы; средние баллы пересчитываются при чтении.
+func (s *Storage) setGradeTypeWeight(code string, weight float64) (GradeType, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := findGradeType(code)
+	if !ok {
+		return GradeType{}, errUnknownGradeType
+	}
+	if weight <= 0 || weight > maxGradeWeight || math.IsNaN(weight) {
+		return GradeType{}, errors.New("weight must be greater than 0 and at most 10")
+	}
+	t.Weight = weight
+	if err := s.commitLocked(journalRecord{Op: opSetGradeWeight, GradeType: &t}); err != nil {
+		return GradeType{}, err
+	}
+	return t, nil
+}
+
+// gradeMathLocked возвращает веса видов работ и шкалы хранилища для расчета средних
+// баллов внутри операций под блокировкой (см. newGradeMath).
+func (s *Storage) gradeMathLocked() gradeMath {
+	m := gradeMath{weights: map[string]float64{}, scales: s.scales, fallback: s.defaultScaleLocked()}
+	for _, t := range gradeTypeCatalog {
+		m.weights[t.Code] = s.gradeWeightLocked(t.Code)
+	}
+	return m
+}
+
+// findGradeType ищет вид работы в каталоге по коду без учета регистра.
+func findGradeType(code string) (GradeType, bool) {
+	code = strings.ToLower(strings.TrimSpace(code))
+	for _, t := range gradeTypeCatalog {
+		if t.Code == code {
+			return t, true
+		}
+	}
+	return GradeType{}, false
+}
+
+// normalizeGradeType возвращает код вида работы из каталога; пустой код
+// заменяется на defaultGradeType.
+func normalizeGradeType(code string) (string, error) {
+	if strings.TrimSpace(code) == "" {
+		return defaultGradeType, nil
+	}
+	t, ok := findGradeType(code)
+	if !ok {
+		return "", errUnknownGradeType
+	}
+	return t.Code, nil
+}
+
+// gradeWeightLocked возвращает действующий вес вида работы.
+func (s *Storage) gradeWeightLocked(code string) float64 {
+	if code == "" {
+		code = defaultGradeType
+	}
+	if w, ok := s.gradeWeights[code]; ok {
+		return w
+	}
+	if t, ok := findGradeType(code); ok {
+		return t.Weight
+	}
+	return 1
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// errGradeNotFound — оценка не найдена (или уже удалена).
+var errGradeNotFound = errors.New("grade not found")
+
+// rowError — ошибка проверки строки пакетной операции (оценки за урок, отметки
+// посещаемости); Row считается от нуля.
+type rowError struct {
+	Row       int    `json:"row"`
+	StudentID int64  `json:"studentId"`
+	Error     string `json:"error"`
+}
+
+// batchError — пакет отклонен целиком; Rows перечисляет ошибочные строки.
+type batchError struct {
+	Rows []rowError
+}
+
+func (e *batchError) Error() string {
+	return fmt.Sprintf("%d rows are invalid", len(e.Rows))
+}
+
+// addGrades проверяет все оценки пакета и сохраняет их одной операцией журнала:
+// либо все, либо ни одной. При ошибках возвращается *batchError.
+func (s *Storage) addGrades(grades []Grade) ([]Grade, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(grades) == 0 {
+		return nil, errors.New("grades are required")
+	}
+	res := make([]Grade, len(grades))
+	batchErr := &batchError{}
+	for i, g := range grades {
+		if err := s.prepareGradeLocked(&g); err != nil {
+			batchErr.Rows = append(batchErr.Rows, rowError{Row: i, StudentID: g.StudentID, Error: err.Error()})
+			continue
+		}
+		g.ID = s.nextGradeID + int64(i)
+		res[i] = g
+	}
+	if len(batchErr.Rows) > 0 {
+		return nil, batchErr
+	}
+	if err := s.commitLocked(journalRecord{Op: opPutGrades, Grades: res}); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// prepareGradeLocked проверяет новую оценку и проставляет ссылки на справочники:
+// предмет, вид работы, шкалу, по которой она выставлена, и урок из журнала уроков.
+func (s *Storage) prepareGradeLocked(g *Grade) error {
+	sub, err := s.requireSubjectLocked(g.Subject)
+	if err != nil {
+		return err
+	}
+	g.SubjectID, g.Subject = sub.ID, sub.Name
+	if g.LessonID, err = s.lessonForLocked(g.LessonID, s.users[g.StudentID].ClassName, sub.ID, g.Date); err != nil {
+		return err
+	}
+	if g.Type, err = normalizeGradeType(g.Type); err != nil {
+		return err
+	}
+	if s.termLockedLocked(g.StudentID, g.SubjectID, g.Date) {
+		return errTermLocked
+	}
+	scale := s.scaleForLocked(sub.ID, s.users[g.StudentID].ClassName)
+	if !scale.allows(g.Value) {
+		return scale.invalidValueError()
+	}
+	g.ScaleID = scale.ID
+	return nil
+}
+
+// gradeFilter — отбор оценок; пустые поля означают «без фильтра», даты включительно.
+// ClassName — нынешний класс ученика.
+type gradeFilter struct {
+	StudentID int64
+	ClassName string
+	Subject   string
+	From      string
+	To        string
+}
+
+// listGrades возвращает оценки по фильтру в порядке выставления.
+func (s *Storage) listGrades(f gradeFilter) []Grade {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	res := []Grade{}
+	var subjectID int64
+	if strings.TrimSpace(f.Subject) != "" {
+		sub, ok := s.findSubjectLocked(f.Subject)
+		if !ok {
+			return res
+		}
+		subjectID = sub.ID
+	}
+	className := normalizeClassName(f.ClassName)
+	for _, g := range s.grades {
+		switch {
+		case f.StudentID != 0 && g.StudentID != f.StudentID,
+			className != "" && s.users[g.StudentID].ClassName != className,
+			subjectID != 0 && g.SubjectID != subjectID,
+			f.From != "" && g.Date < f.From,
+			f.To != "" && g.Date > f.To:
+			continue
+		}
+		res = append(res, g)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].ID < res[j].ID })
+	return res
+}
+
+// putGradeLocked сохраняет оценку в памяти.
+func (s *Storage) putGradeLocked(g Grade) {
+	if g.Type == "" {
+		// оценки, выставленные до появления видов работ
+		g.Type = defaultGradeType
+	}
+	s.grades[g.ID] = g
+	bumpCounter(&s.nextGradeID, g.ID)
+}
+
+// getGrade возвращает оценку по ID.
+func (s *Storage) getGrade(id int64) (Grade, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	g, ok := s.grades[id]
+	return g, ok
+}
+
+// updateGrade исправляет значение, вид работы, комментарий и дату оценки и одной
+// операцией журнала сохраняет ревизию с прежними и новыми значениями. Предмет, ученик
+// и автор не меняются. Если поля не изменились, ревизия не создается.
+func (s *Storage) updateGrade(g Grade, actorID int64, reason string) (Grade, *GradeRevision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev, ok := s.grades[g.ID]
+	if !ok {
+		return Grade{}, nil, errGradeNotFound
+	}
+	next := prev
+	next.Value, next.Comment, next.Date = g.Value, g.Comment, g.Date
+	var err error
+	if next.Type, err = normalizeGradeType(g.Type); err != nil {
+		return Grade{}, nil, err
+	}
+	if scale := s.gradeScaleLocked(prev); !scale.allows(next.Value) {
+		return Grade{}, nil, scale.invalidValueError()
+	}
+	changes := gradeChanges(prev, next)
+	if len(changes) == 0 {
+		return prev, nil, nil
+	}
+	if next.Date != prev.Date {
+		// оценка переносится на урок новой даты, если он записан
+		next.LessonID, _ = s.lessonForLocked(0, s.users[prev.StudentID].ClassName, prev.SubjectID, next.Date)
+	}
+	if s.termLockedLocked(prev.StudentID, prev.SubjectID, prev.Date) || s.termLockedLocked(next.StudentID, next.SubjectID, next.Date) {
+		return Grade{}, nil, errTermLocked
+	}
+	rev := s.newGradeRevisionLocked(prev, "update", changes, actorID, reason)
+	if err := s.commitLocked(journalRecord{Op: opUpdateGrade, Grade: &next, GradeRevision: &rev}); err != nil {
+		return Grade{}, nil, err
+	}
+	return next, &rev, nil
+}
+
+// deleteGrade удаляет оценку; ревизия сохраняет ее последние значения.
+func (s *Storage) deleteGrade(id, actorID int64, reason string) (GradeRevision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev, ok := s.grades[id]
+	if !ok {
+		return GradeRevision{}, errGradeNotFound
+	}
+	if s.termLockedLocked(prev.StudentID, prev.SubjectID, prev.Date) {
+		return GradeRevision{}, errTermLocked
+	}
+	rev := s.newGradeRevisionLocked(prev, "delete", gradeChanges(prev, Grade{}), actorID, reason)
+	if err := s.commitLocked(journalRecord{Op: opDeleteGrade, ID: id, GradeRevision: &rev}); err != nil {
+		return GradeRevision{}, err
+	}
+	return rev, nil
+}
+
+// listGradeRevisions возвращает ревизии оценок, старые первыми. Нулевые
+// gradeID и studentID означают «без фильтра».
+func (s *Storage) listGradeRevisions(gradeID, studentID int64) []GradeRevision {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	res := []GradeRevision{}
+	for _, rev := range s.gradeRevisions {
+		if gradeID != 0 && rev.GradeID != gradeID {
+			continue
+		}
+		if studentID != 0 && rev.StudentID != studentID {
+			continue
+		}
+		res = append(res, rev)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].ID < res[j].ID })
+	return res
+}
+
+// gradeChanges сравнивает изменяемые поля оценки.
+func gradeChanges(prev, next Grade) []FieldChange {
+	value := func(v int) string {
+		if v == 0 {
+			return ""
+		}
+		return strconv.Itoa(v)
+	}
+	changes := []FieldChange{}
+	for _, f := range []FieldChange{
+		{Field: "value", From: value(prev.Value), To: value(next.Value)},
+		{Field: "type", From: prev.Type, To: next.Type},
+		{Field: "comment", From: prev.Comment, To: next.Comment},
+		{Field: "date", From: prev.Date, To: next.Date},
+	} {
+		if f.From != f.To {
+			changes = append(changes, f)
+		}
+	}
+	return changes
+}
+
+// newGradeRevisionLocked готовит ревизию оценки со следующим ID.
+func (s *Storage) newGradeRevisionLocked(g Grade, action string, changes []FieldChange, actorID int64, reason string) GradeRevision {
+	return GradeRevision{
+		ID:        s.nextGradeRevisionID,
+		GradeID:   g.ID,
+		StudentID: g.StudentID,
+		SubjectID: g.SubjectID,
+		Subject:   g.Subject,
+		Action:    action,
+		Changes:   changes,
+		ActorID:   actorID,
+		Reason:    reason,
+		At:        time.Now().UTC(),
+	}
+}
+
+// putGradeRevisionLocked сохраняет ревизию оценки в памяти.
+func (s *Storage) putGradeRevisionLocked(rev GradeRevision) {
+	s.gradeRevisions[rev.ID] = rev
+	bumpCounter(&s.nextGradeRevisionID, rev.ID)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// errInviteInvalid — код приглашения не найден, уже использован или истек.
+var errInviteInvalid = errors.New("invite code is invalid or already used")
+
+// errInviteRole — приглашения выдаются только ученикам; учителей создает администратор.
+var errInviteRole = errors.New("invites can only be issued for students")
+
+// createInvite сохраняет приглашение и возвращает его вместе с одноразовым кодом.
+func (s *Storage) createInvite(inv Invite) (Invite, string, error) {
+	if inv.Role != RoleStudent {
+		return Invite{}, "", errInviteRole
+	}
+	code, err := randomHex(8)
+	if err != nil {
+		return Invite{}, "", err
+	}
+	code = strings.ToUpper(code)
+	inv.CodeHash = hashToken(code)
+	inv.ClassName = normalizeClassName(inv.ClassName)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.requireClassLocked(inv.ClassName); err != nil {
+		return Invite{}, "", err
+	}
+	inv.ID = s.nextInviteID
+	if err := s.commitLocked(journalRecord{Op: opPutInvite, Invite: persistInvite(inv)}); err != nil {
+		return Invite{}, "", err
+	}
+	return inv, code, nil
+}
+
+// listInvites возвращает все приглашения, новые первыми.
+func (s *Storage) listInvites() []Invite {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	res := make([]Invite, 0, len(s.invites))
+	for _, inv := range s.invites {
+		res = append(res, inv)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].ID > res[j].ID })
+	return res
+}
+
+// deleteInvite отзывает приглашение.
+func (s *Storage) deleteInvite(id int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.invites[id]; !ok {
+		return false, nil
+	}
+	if err := s.commitLocked(journalRecord{Op: opDeleteInvite, ID: id}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// redeemInvite создает пользователя по коду приглашения: роль и класс берутся
+// из приглашения, а само приглашение помечается использованным в той же операции.
+func (s *Storage) redeemInvite(code string, u User) (User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id, ok := s.inviteCodes[hashToken(strings.ToUpper(strings.TrimSpace(code)))]
+	if !ok {
+		return User{}, errInviteInvalid
+	}
+	inv := s.invites[id]
+	now := time.Now().UTC()
+	if inv.UsedBy != 0 || !now.Before(inv.ExpiresAt) || inv.Role != RoleStudent {
+		return User{}, errInviteInvalid
+	}
+
+	key := emailKey(u.Email)
+	if key == "" {
+		return User{}, errors.New("email is required")
+	}
+	if _, exists := s.emailIdx[key]; exists {
+		return User{}, errors.New("email already exists")
+	}
+	u.ID = s.nextUserID
+	u.Role = inv.Role
+	u.ClassName = inv.ClassName
+	inv.UsedBy = u.ID
+	inv.UsedAt = &now
+	if err := s.commitLocked(journalRecord{
+		Op:     opRedeemInvite,
+		User:   &persistedUser{User: u, PasswordHash: u.PasswordHash},
+		Invite: persistInvite(inv),
+	}); err != nil {
+		return User{}, err
+	}
+	return u, nil
+}
+
+// putInviteLocked сохраняет приглашение в памяти и индексирует хеш кода.
+func (s *Storage) putInviteLocked(inv Invite) {
+	s.invites[inv.ID] = inv
+	s.inviteCodes[inv.CodeHash] = inv.ID
+	bumpCounter(&s.nextInviteID, inv.ID)
+}
+
+// deleteInviteLocked удаляет приглашение из памяти.
+func (s *Storage) deleteInviteLocked(id int64) {
+	inv, ok := s.invites[id]
+	if !ok {
+		return
+	}
+	delete(s.inviteCodes, inv.CodeHash)
+	delete(s.invites, id)
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxLessonPeriod — наибольший номер урока в учебном дне.
+const maxLessonPeriod = 12
+
+var (
+	// errLessonNotFound — запись журнала уроков не найдена.
+	errLessonNotFound = errors.New("lesson not found")
+	// errLessonExists — урок предмета в классе с тем же номером в эту дату уже записан.
+	errLessonExists = errors.New("lesson already exists")
+	// errLessonMismatch — оценка или отметка относится к другому классу, предмету или дате,
+	// чем указанный урок.
+	errLessonMismatch = errors.New("lesson is for another class, subject or date")
+)
+
+// lessonFilter — отбор записей журнала уроков; пустые поля означают «без фильтра»,
+// даты включительно.
+type lessonFilter struct {
+	ClassName string
+	Subject   string
+	TeacherID int64
+	From      string
+	To        string
+}
+
+// createLesson записывает проведенный урок. Класс, предмет и дату урока потом изменить
+// нельзя: на них ссылаются оценки и отметки посещаемости.
+func (s *Storage) createLesson(l Lesson) (Lesson, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l.ClassName = normalizeClassName(l.ClassName)
+	if err := s.requireClassLocked(l.ClassName); err != nil {
+		return Lesson{}, err
+	}
+	sub, err := s.requireSubjectLocked(l.Subject)
+	if err != nil {
+		return Lesson{}, err
+	}
+	l.SubjectID, l.Subject = sub.ID, sub.Name
+	if _, err := time.Parse("2006-01-02", l.Date); err != nil {
+		return Lesson{}, errors.New("date must be YYYY-MM-DD")
+	}
+	l.ID = s.nextLessonID
+	l.CreatedAt = time.Now().UTC()
+	if err := s.validateLessonLocked(&l); err != nil {
+		return Lesson{}, err
+	}
+	if err := s.commitLocked(journalRecord{Op: opPutLesson, Lesson: &l}); err != nil {
+		return Lesson{}, err
+	}
+	return l, nil
+}
+
+// updateLesson меняет номер урока, тему и ссылку на домашнее задание.
+func (s *Storage) updateLesson(l Lesson) (Lesson, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev, ok := s.lessons[l.ID]
+	if !ok {
+		return Lesson{}, errLessonNotFound
+	}
+	next := prev
+	next.Period, next.Topic, next.HomeworkID = l.Period, l.Topic, l.HomeworkID
+	if err := s.validateLessonLocked(&next); err != nil {
+		return Lesson{}, err
+	}
+	if err := s.commitLocked(journalRecord{Op: opPutLesson, Lesson: &next}); err != nil {
+		return Lesson{}, err
+	}
+	return next, nil
+}
+
+// deleteLesson удаляет запись урока; оценки и отметки посещаемости остаются, но
+// теряют ссылку на урок. Для каждой отвязанной оценки сохраняется ревизия от имени
+// actorID. Урок с оценками в периоде, закрытом итоговой оценкой, удалить нельзя.
+func (s *Storage) deleteLesson(id, actorID int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.lessons[id]; !ok {
+		return false, nil
+	}
+	var grades []Grade
+	for _, g := range s.grades {
+		if g.LessonID != id {
+			continue
+		}
+		if s.termLockedLocked(g.StudentID, g.SubjectID, g.Date) {
+			return false, errTermLocked
+		}
+		grades = append(grades, g)
+	}
+	sort.Slice(grades, func(i, j int) bool { return grades[i].ID < grades[j].ID })
+	revs := make([]GradeRevision, 0, len(grades))
+	for i, g := range grades {
+		changes := []FieldChange{{Field: "lesson", From: strconv.FormatInt(id, 10), To: ""}}
+		rev := s.newGradeRevisionLocked(g, "update", changes, actorID, "lesson deleted")
+		rev.ID += int64(i)
+		revs = append(revs, rev)
+	}
+	if err := s.commitLocked(journalRecord{Op: opDeleteLesson, ID: id, GradeRevisions: revs}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// getLesson возвращает запись урока по ID.
+func (s *Storage) getLesson(id int64) (Lesson, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	l, ok := s.lessons[id]
+	return l, ok
+}
+
+// listLessons возвращает записи журнала уроков по фильтру, упорядоченные по дате,
+// номеру урока и классу.
+func (s *Storage) listLessons(f lessonFilter) []Lesson {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	res := []Lesson{}
+	var subjectID int64
+	if strings.TrimSpace(f.Subject) != "" {
+		sub, ok := s.findSubjectLocked(f.Subject)
+		if !ok {
+			return res
+		}
+		subjectID = sub.ID
+	}
+	className := normalizeClassName(f.ClassName)
+	for _, l := range s.lessons {
+		switch {
+		case className != "" && l.ClassName != className,
+			subjectID != 0 && l.SubjectID != subjectID,
+			f.TeacherID != 0 && l.TeacherID != f.TeacherID,
+			f.From != "" && l.Date < f.From,
+			f.To != "" && l.Date > f.To:
+			continue
+		}
+		res = append(res, l)
+	}
+	sortLessons(res)
+	return res
+}
+
+// validateLessonLocked проверяет номер и тему урока, ссылку на домашнее задание того же
+// класса и предмета и то, что урок с таким номером в эту дату еще не записан.
+func (s *Storage) validateLessonLocked(l *Lesson) error {
+	l.Topic = strings.TrimSpace(l.Topic)
+	if l.Topic == "" {
+		return errors.New("topic is required")
+	}
+	if l.Period < 1 || l.Period > maxLessonPeriod {
+		return fmt.Errorf("period must be between 1 and %d", maxLessonPeriod)
+	}
+	if l.HomeworkID != 0 {
+		hw, ok := s.homework[l.HomeworkID]
+		if !ok {
+			return errors.New("homework not found")
+		}
+		if normalizeClassName(hw.ClassName) != l.ClassName || hw.SubjectID != l.SubjectID {
+			return errors.New("homework is for another class or subject")
+		}
+	}
+	for _, other := range s.lessons {
+		if other.ID != l.ID && other.ClassName == l.ClassName && other.SubjectID == l.SubjectID &&
+			other.Date == l.Date && other.Period == l.Period {
+			return fmt.Errorf("%w: %s, %s, %s, period %d", errLessonExists, l.ClassName, l.Subject, l.Date, l.Period)
+		}
+	}
+	return nil
+}
+
+// lessonForLocked подбирает урок для оценки или отметки: проверяет явно указанный
+// lessonID или, если он нулевой, находит единственный записанный урок предмета в классе
+// в эту дату (0, если уроков нет или их несколько).
+func (s *Storage) lessonForLocked(lessonID int64, className string, subjectID int64, date string) (int64, error) {
+	if lessonID != 0 {
+		l, ok := s.lessons[lessonID]
+		if !ok {
+			return 0, errLessonNotFound
+		}
+		if l.ClassName != className || l.SubjectID != subjectID || l.Date != date {
+			return 0, errLessonMismatch
+		}
+		return lessonID, nil
+	}
+	var found int64
+	for _, l := range s.lessons {
+		if l.ClassName == className && l.SubjectID == subjectID && l.Date == date {
+			if found != 0 {
+				return 0, nil
+			}
+			found = l.ID
+		}
+	}
+	return found, nil
+}
+
+// putLessonLocked сохраняет запись урока в памяти.
+func (s *Storage) putLessonLocked(l Lesson) {
+	s.lessons[l.ID] = l
+	bumpCounter(&s.nextLessonID, l.ID)
+}
+
+// deleteLessonLocked удаляет запись урока и ссылки на нее из оценок и отметок. Если
+// у ученика уже есть отметка без урока за тот же предмет и дату, остается более поздняя
+// из двух.
+func (s *Storage) deleteLessonLocked(id int64) {
+	delete(s.lessons, id)
+	for gid, g := range s.grades {
+		if g.LessonID == id {
+			g.LessonID = 0
+			s.grades[gid] = g
+		}
+	}
+	for aid, a := range s.attendance {
+		if a.LessonID != id {
+			continue
+		}
+		a.LessonID = 0
+		s.attendance[aid] = a
+		for oid, other := range s.attendance {
+			if oid == aid || other.LessonID != 0 || other.StudentID != a.StudentID ||
+				other.SubjectID != a.SubjectID || other.Date != a.Date {
+				continue
+			}
+			if attendanceNewer(other, a) {
+				delete(s.attendance, aid)
+			} else {
+				delete(s.attendance, oid)
+			}
+			break
+		}
+	}
+}
+
+// attendanceNewer сообщает, отмечена ли a позже b (при равном времени — позже создана).
+func attendanceNewer(a, b Attendance) bool {
+	if !a.MarkedAt.Equal(b.MarkedAt) {
+		return a.MarkedAt.After(b.MarkedAt)
+	}
+	return a.ID > b.ID
+}
+
+// sortLessons упорядочивает уроки по дате, номеру урока и классу.
+func sortLessons(lessons []Lesson) {
+	sort.Slice(lessons, func(i, j int) bool {
+		a, b := lessons[i], lessons[j]
+		if a.Date != b.Date {
+			return a.Date < b.Date
+		}
+		if a.Period != b.Period {
+			return a.Period < b.Period
+		}
+		return a.ClassName < b.ClassName
+	})
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"strings"
+	"time"
+)
+
+// errResetInvalid — код сброса не найден, не подходит или истек.
+var errResetInvalid = errors.New("reset code is invalid or expired")
+
+// changePassword заменяет хеш пароля и завершает все сессии пользователя,
+// кроме keepSessionID (пустая строка — завершить все). Возвращает число завершенных сессий.
+func (s *Storage) changePassword(userID int64, hash, keepSessionID string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[userID]
+	if !ok {
+		return 0, errors.New("user not found")
+	}
+	return s.changePasswordLocked(u, hash, keepSessionID)
+}
+
+// changePasswordLocked записывает смену пароля одной операцией журнала.
+func (s *Storage) changePasswordLocked(u User, hash, keepSessionID string) (int, error) {
+	n := 0
+	for id, session := range s.sessions {
+		if session.UserID == u.ID && id != keepSessionID {
+			n++
+		}
+	}
+	u.PasswordHash = hash
+	if err := s.commitLocked(journalRecord{
+		Op:        opChangePassword,
+		SessionID: keepSessionID,
+		User:      &persistedUser{User: u, PasswordHash: hash},
+	}); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// createPasswordReset выдает пользователю новый код сброса пароля; прежний код перестает действовать.
+func (s *Storage) createPasswordReset(reset PasswordReset) (PasswordReset, string, error) {
+	code, err := randomHex(6)
+	if err != nil {
+		return PasswordReset{}, "", err
+	}
+	code = strings.ToUpper(code)
+	reset.CodeHash = hashToken(code)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[reset.UserID]; !ok {
+		return PasswordReset{}, "", errors.New("user not found")
+	}
+	if err := s.commitLocked(journalRecord{Op: opPutPasswordReset, Reset: persistPasswordReset(reset)}); err != nil {
+		return PasswordReset{}, "", err
+	}
+	return reset, code, nil
+}
+
+// redeemPasswordReset устанавливает новый пароль по коду сброса. Код одноразовый,
+// все сессии пользователя завершаются.
+func (s *Storage) redeemPasswordReset(email, code, hash string) (User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id, ok := s.emailIdx[emailKey(email)]
+	if !ok {
+		return User{}, errResetInvalid
+	}
+	reset, ok := s.resets[id]
+	if !ok || !time.Now().UTC().Before(reset.ExpiresAt) {
+		return User{}, errResetInvalid
+	}
+	got := hashToken(strings.ToUpper(strings.TrimSpace(code)))
+	if subtle.ConstantTimeCompare([]byte(got), []byte(reset.CodeHash)) != 1 {
+		return User{}, errResetInvalid
+	}
+	u := s.users[id]
+	if _, err := s.changePasswordLocked(u, hash, ""); err != nil {
+		return User{}, err
+	}
+	u.PasswordHash = hash
+	return u, nil
+}
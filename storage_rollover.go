@@ -0,0 +1,468 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// errYearArchived — учебный год уже переведен, повторный перевод запрещен.
+var errYearArchived = errors.New("academic year is already archived")
+
+// rolloverParams — параметры перевода на новый учебный год, записываемые в журнал.
+// Само изменение данных вычисляется из состояния хранилища (см. rolloverLocked).
+type rolloverParams struct {
+	FromYear   string    `json:"fromYear"`
+	ToYear     string    `json:"toYear"`
+	FinalGrade int       `json:"finalGrade"`
+	ActorID    int64     `json:"actorId"`
+	At         time.Time `json:"at"`
+}
+
+// classPromotion — перевод одного класса: To пуст для выпускного класса.
+type classPromotion struct {
+	From     string `json:"from"`
+	To       string `json:"to,omitempty"`
+	Students int    `json:"students"`
+}
+
+// rolloverPlan — что произойдет при переводе на новый учебный год.
+type rolloverPlan struct {
+	FromYear   string           `json:"fromYear"`
+	ToYear     string           `json:"toYear"`
+	FinalGrade int              `json:"finalGrade"`
+	Promotions []classPromotion `json:"promotions"`
+	Graduates  []classPromotion `json:"graduates"`
+	// Kept — классы, уже заведенные на новый учебный год; они не переводятся.
+	Kept    []string       `json:"kept"`
+	Archive map[string]int `json:"archive"`
+}
+
+// nextAcademicYear возвращает учебный год, следующий за year (2026/2027 → 2027/2028).
+func nextAcademicYear(year string) string {
+	first, _, _ := strings.Cut(year, "/")
+	start, _ := strconv.Atoi(first)
+	return fmt.Sprintf("%d/%d", start+1, start+2)
+}
+
+// archiveKey превращает учебный год в ключ архива для URL: 2026/2027 → 2026-2027.
+func archiveKey(year string) string {
+	return strings.ReplaceAll(strings.TrimSpace(year), "/", "-")
+}
+
+// currentYearLocked определяет текущий учебный год по реестру классов:
+// берется самый ранний год среди классов, без классов — год по календарю.
+func (s *Storage) currentYearLocked() string {
+	year := ""
+	for _, c := range s.classes {
+		if year == "" || c.AcademicYear < year {
+			year = c.AcademicYear
+		}
+	}
+	if year == "" {
+		year = currentAcademicYear(time.Now())
+	}
+	return year
+}
+
+// planRolloverLocked строит план перевода; классы с параллелью finalGrade и выше выпускаются.
+func (s *Storage) planRolloverLocked(finalGrade int) (rolloverPlan, error) {
+	if finalGrade <= 0 {
+		return rolloverPlan{}, errors.New("finalGrade must be positive")
+	}
+	from := s.currentYearLocked()
+	if _, ok := s.archives[archiveKey(from)]; ok {
+		return rolloverPlan{}, fmt.Errorf("%w: %s", errYearArchived, from)
+	}
+	plan := rolloverPlan{
+		FromYear:   from,
+		ToYear:     nextAcademicYear(from),
+		FinalGrade: finalGrade,
+		Promotions: []classPromotion{},
+		Graduates:  []classPromotion{},
+		Kept:       []string{},
+	}
+	archive := s.yearArchiveLocked(rolloverParams{FromYear: from, ToYear: plan.ToYear, FinalGrade: finalGrade})
+	plan.Archive = map[string]int{
+		"grades":         len(archive.Grades),
+		"gradeRevisions": len(archive.GradeRevisions),
+		"terms":          len(archive.Terms),
+		"termGrades":     len(archive.TermGrades),
+		"homework":       len(archive.Homework),
+		"schedule":       len(archive.Schedule),
+		"attendance":     len(archive.Attendance),
+		"lessons":        len(archive.Lessons),
+	}
+	students := map[string]int{}
+	for _, u := range s.users {
+		if u.Role == RoleStudent && !u.Archived {
+			students[u.ClassName]++
+		}
+	}
+	for _, c := range s.classes {
+		if c.AcademicYear != from {
+			plan.Kept = append(plan.Kept, c.Name)
+			continue
+		}
+		if c.Grade <= 0 {
+			return rolloverPlan{}, fmt.Errorf("class %s has no grade level, fix it before rollover", c.Name)
+		}
+		p := classPromotion{From: c.Name, Students: students[c.Name]}
+		if c.Grade >= finalGrade {
+			plan.Graduates = append(plan.Graduates, p)
+			continue
+		}
+		p.To = normalizeClassName(strconv.Itoa(c.Grade+1) + c.Letter)
+		plan.Promotions = append(plan.Promotions, p)
+	}
+	byName := func(list []classPromotion) func(i, j int) bool {
+		return func(i, j int) bool {
+			a, b := s.classes[list[i].From], s.classes[list[j].From]
+			if a.Grade != b.Grade {
+				return a.Grade < b.Grade
+			}
+			return a.Letter < b.Letter
+		}
+	}
+	sort.Slice(plan.Promotions, byName(plan.Promotions))
+	sort.Slice(plan.Graduates, byName(plan.Graduates))
+	sort.Strings(plan.Kept)
+	for _, p := range plan.Promotions {
+		if c, ok := s.classes[p.To]; ok && c.AcademicYear != from {
+			return rolloverPlan{}, fmt.Errorf("class %s is already registered for %s, %s cannot be promoted into it", p.To, c.AcademicYear, p.From)
+		}
+	}
+	return plan, nil
+}
+
+// previewRollover показывает план перевода, ничего не меняя.
+func (s *Storage) previewRollover(finalGrade int) (rolloverPlan, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.planRolloverLocked(finalGrade)
+}
+
+// applyRollover переводит школу на новый учебный год одной операцией журнала:
+// архивирует оценки, ДЗ и расписание, переводит классы и учеников, выпускников
+// помечает архивными и завершает их сессии.
+func (s *Storage) applyRollover(finalGrade int, actorID int64) (rolloverPlan, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	plan, err := s.planRolloverLocked(finalGrade)
+	if err != nil {
+		return rolloverPlan{}, err
+	}
+	now := time.Now().UTC()
+	if err := s.commitLocked(journalRecord{
+		Op: opRollover,
+		Rollover: &rolloverParams{
+			FromYear:   plan.FromYear,
+			ToYear:     plan.ToYear,
+			FinalGrade: finalGrade,
+			ActorID:    actorID,
+			At:         now,
+		},
+		Audit: &AuditEntry{
+			ID:      s.nextAuditID,
+			ActorID: actorID,
+			Action:  "rollover",
+			Entity:  "academicYear",
+			Changes: []FieldChange{{Field: "academicYear", From: plan.FromYear, To: plan.ToYear}},
+			At:      now,
+		},
+	}); err != nil {
+		return rolloverPlan{}, err
+	}
+	return plan, nil
+}
+
+// listArchives возвращает сводку по архивам учебных лет, новые первыми.
+func (s *Storage) listArchives() []YearArchiveSummary {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	res := make([]YearArchiveSummary, 0, len(s.archives))
+	for _, a := range s.archives {
+		res = append(res, a.summary())
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].AcademicYear > res[j].AcademicYear })
+	return res
+}
+
+// getArchive возвращает архив учебного года (2026/2027 или 2026-2027).
+func (s *Storage) getArchive(year string) (YearArchive, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	a, ok := s.archives[archiveKey(year)]
+	return a, ok
+}
+
+// summary возвращает сводку архива.
+func (a YearArchive) summary() YearArchiveSummary {
+	return YearArchiveSummary{
+		AcademicYear: a.AcademicYear,
+		ArchivedAt:   a.ArchivedAt,
+		ArchivedBy:   a.ArchivedBy,
+		Counts: map[string]int{
+			"classes":        len(a.Classes),
+			"students":       len(a.Students),
+			"grades":         len(a.Grades),
+			"gradeRevisions": len(a.GradeRevisions),
+			"terms":          len(a.Terms),
+			"termGrades":     len(a.TermGrades),
+			"homework":       len(a.Homework),
+			"schedule":       len(a.Schedule),
+			"attendance":     len(a.Attendance),
+			"lessons":        len(a.Lessons),
+		},
+	}
+}
+
+// sort упорядочивает содержимое архива, чтобы оно не зависело от порядка обхода map.
+func (a *YearArchive) sort() {
+	sort.Slice(a.Classes, func(i, j int) bool { return a.Classes[i].Name < a.Classes[j].Name })
+	sort.Slice(a.Students, func(i, j int) bool { return a.Students[i].ID < a.Students[j].ID })
+	sort.Slice(a.Grades, func(i, j int) bool { return a.Grades[i].ID < a.Grades[j].ID })
+	sort.Slice(a.GradeRevisions, func(i, j int) bool { return a.GradeRevisions[i].ID < a.GradeRevisions[j].ID })
+	sort.Slice(a.Terms, func(i, j int) bool { return a.Terms[i].StartDate < a.Terms[j].StartDate })
+	sort.Slice(a.TermGrades, func(i, j int) bool { return a.TermGrades[i].ID < a.TermGrades[j].ID })
+	sort.Slice(a.Homework, func(i, j int) bool { return a.Homework[i].ID < a.Homework[j].ID })
+	sort.Slice(a.Schedule, func(i, j int) bool { return a.Schedule[i].ID < a.Schedule[j].ID })
+	sort.Slice(a.Attendance, func(i, j int) bool { return a.Attendance[i].ID < a.Attendance[j].ID })
+	sort.Slice(a.Lessons, func(i, j int) bool { return a.Lessons[i].ID < a.Lessons[j].ID })
+}
+
+// rolloverScope — строки, которые уходят в архив переводимого учебного года: относящиеся к его
+// классам и датированные до начала следующего года. У выпускных классов архивируются все строки.
+type rolloverScope struct {
+	// graduating — классы переводимого года; true — класс выпускается.
+	graduating map[string]bool
+	// studentClass — класс каждого ученика переводимого года.
+	studentClass map[int64]string
+	// end — первый день следующего учебного года.
+	end string
+}
+
+// academicYearEnd возвращает первый день учебного года, следующего за year (2026/2027 → 2027-09-01).
+func academicYearEnd(year string) string {
+	_, second, _ := strings.Cut(year, "/")
+	return second + "-09-01"
+}
+
+// rolloverScopeLocked собирает классы и учеников переводимого года.
+func (s *Storage) rolloverScopeLocked(p rolloverParams) rolloverScope {
+	sc := rolloverScope{
+		graduating:   map[string]bool{},
+		studentClass: map[int64]string{},
+		end:          academicYearEnd(p.FromYear),
+	}
+	for _, c := range s.classes {
+		if c.AcademicYear == p.FromYear {
+			sc.graduating[c.Name] = c.Grade >= p.FinalGrade
+		}
+	}
+	for _, u := range s.users {
+		if _, ok := sc.graduating[u.ClassName]; ok && u.Role == RoleStudent && !u.Archived {
+			sc.studentClass[u.ID] = u.ClassName
+		}
+	}
+	return sc
+}
+
+// contains проверяет, что строка класса className с датой date (YYYY-MM-DD, может быть пустой)
+// относится к переводимому году.
+func (sc rolloverScope) contains(className, date string) bool {
+	graduating, ok := sc.graduating[className]
+	if !ok {
+		return false
+	}
+	return graduating || date == "" || date < sc.end
+}
+
+// containsStudent проверяет то же для строки ученика: берется его текущий класс.
+func (sc rolloverScope) containsStudent(studentID int64, date string) bool {
+	className, ok := sc.studentClass[studentID]
+	return ok && sc.contains(className, date)
+}
+
+// yearArchiveLocked собирает архив переводимого года, ничего не меняя: классы и учеников
+// года, учебные периоды с итоговыми оценками и строки из rolloverScope. Исправления оценок
+// архивируются вместе с оценкой, исправления удаленных оценок — по ученику и дате.
+func (s *Storage) yearArchiveLocked(p rolloverParams) YearArchive {
+	sc := s.rolloverScopeLocked(p)
+	archive := YearArchive{
+		AcademicYear:   p.FromYear,
+		ArchivedAt:     p.At,
+		ArchivedBy:     p.ActorID,
+		Classes:        []Class{},
+		Students:       []User{},
+		Grades:         []Grade{},
+		GradeRevisions: []GradeRevision{},
+		Terms:          []Term{},
+		TermGrades:     []TermGrade{},
+		Homework:       []Homework{},
+		Schedule:       []ScheduleEntry{},
+		Attendance:     []Attendance{},
+		Lessons:        []Lesson{},
+	}
+	for _, c := range s.classes {
+		if c.AcademicYear == p.FromYear {
+			archive.Classes = append(archive.Classes, c)
+		}
+	}
+	for id := range sc.studentClass {
+		archive.Students = append(archive.Students, s.users[id])
+	}
+	grades := map[int64]bool{}
+	for _, g := range s.grades {
+		if sc.containsStudent(g.StudentID, g.Date) {
+			archive.Grades = append(archive.Grades, g)
+			grades[g.ID] = true
+		}
+	}
+	for _, rev := range s.gradeRevisions {
+		_, exists := s.grades[rev.GradeID]
+		if grades[rev.GradeID] || !exists && sc.containsStudent(rev.StudentID, rev.At.Format("2006-01-02")) {
+			archive.GradeRevisions = append(archive.GradeRevisions, rev)
+		}
+	}
+	for _, t := range s.terms {
+		if t.AcademicYear == p.FromYear {
+			archive.Terms = append(archive.Terms, t)
+		}
+	}
+	for _, tg := range s.termGrades {
+		if s.terms[tg.TermID].AcademicYear == p.FromYear {
+			archive.TermGrades = append(archive.TermGrades, tg)
+		}
+	}
+	for _, hw := range s.homework {
+		if sc.contains(hw.ClassName, hw.DueDate) {
+			archive.Homework = append(archive.Homework, hw)
+		}
+	}
+	for _, entry := range s.schedule {
+		if sc.contains(entry.ClassName, "") {
+			archive.Schedule = append(archive.Schedule, entry)
+		}
+	}
+	for _, a := range s.attendance {
+		if sc.contains(a.ClassName, a.Date) {
+			archive.Attendance = append(archive.Attendance, a)
+		}
+	}
+	for _, l := range s.lessons {
+		if sc.contains(l.ClassName, l.Date) {
+			archive.Lessons = append(archive.Lessons, l)
+		}
+	}
+	archive.sort()
+	return archive
+}
+
+// rolloverLocked применяет перевод на новый учебный год к данным в памяти: убирает в архив
+// строки переводимого года (см. yearArchiveLocked), остальные данные не трогает. Оставшиеся
+// строки переведенных классов, датированные следующим годом, переходят к новому имени класса.
+func (s *Storage) rolloverLocked(p rolloverParams) {
+	archive := s.yearArchiveLocked(p)
+	s.archives[archiveKey(p.FromYear)] = archive
+
+	for _, g := range archive.Grades {
+		delete(s.grades, g.ID)
+	}
+	for _, rev := range archive.GradeRevisions {
+		delete(s.gradeRevisions, rev.ID)
+	}
+	for _, tg := range archive.TermGrades {
+		delete(s.termGrades, tg.ID)
+	}
+	for _, t := range archive.Terms {
+		delete(s.terms, t.ID)
+	}
+	for _, hw := range archive.Homework {
+		delete(s.homework, hw.ID)
+	}
+	for _, entry := range archive.Schedule {
+		delete(s.schedule, entry.ID)
+	}
+	for _, a := range archive.Attendance {
+		delete(s.attendance, a.ID)
+	}
+	for _, l := range archive.Lessons {
+		delete(s.lessons, l.ID)
+	}
+	for _, c := range archive.Classes {
+		delete(s.photos, c.Name)
+	}
+
+	renamed := map[string]string{}
+	classes := make(map[string]Class, len(s.classes))
+	for name, c := range s.classes {
+		if c.AcademicYear != p.FromYear {
+			classes[name] = c
+			renamed[name] = name
+			continue
+		}
+		if c.Grade >= p.FinalGrade {
+			continue
+		}
+		c.Grade++
+		c.Name = normalizeClassName(strconv.Itoa(c.Grade) + c.Letter)
+		c.AcademicYear = p.ToYear
+		classes[c.Name] = c
+		renamed[name] = c.Name
+	}
+	s.classes = classes
+
+	for id, u := range s.users {
+		if u.Role != RoleStudent || u.Archived || u.ClassName == "" {
+			continue
+		}
+		if to, ok := renamed[u.ClassName]; ok {
+			u.ClassName = to
+		} else {
+			u.Archived = true
+			s.revokeUserSessionsLocked(id)
+		}
+		s.users[id] = u
+	}
+	for id, a := range s.assignments {
+		if to, ok := renamed[a.ClassName]; ok {
+			a.ClassName = to
+			s.assignments[id] = a
+		} else {
+			delete(s.assignments, id)
+		}
+	}
+	for id, inv := range s.invites {
+		if inv.ClassName == "" || inv.UsedBy != 0 {
+			continue
+		}
+		if to, ok := renamed[inv.ClassName]; ok {
+			inv.ClassName = to
+			s.invites[id] = inv
+		} else {
+			s.deleteInviteLocked(id)
+		}
+	}
+	for id, hw := range s.homework {
+		if to, ok := renamed[hw.ClassName]; ok {
+			hw.ClassName = to
+			s.homework[id] = hw
+		}
+	}
+	for id, a := range s.attendance {
+		if to, ok := renamed[a.ClassName]; ok {
+			a.ClassName = to
+			s.attendance[id] = a
+		}
+	}
+	for id, l := range s.lessons {
+		if to, ok := renamed[l.ClassName]; ok {
+			l.ClassName = to
+			s.lessons[id] = l
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	// errUnknownScale — шкала оценивания не найдена.
+	errUnknownScale = errors.New("unknown grading scale")
+	// errScaleInUse — шкалу нельзя удалить, пока она задана предмету или классу,
+	// по ней выставлены оценки или она используется по умолчанию.
+	errScaleInUse = errors.New("grading scale is in use")
+	// errInvalidGradeValue — значение не входит в шкалу оценивания.
+	errInvalidGradeValue = errors.New("grade value is not allowed by the grading scale")
+)
+
+// defaultGradingScales — шкалы, которые заводятся при первом запуске; первая
+// используется по умолчанию.
+func defaultGradingScales() []GradingScale {
+	numeric := func(v float64) *float64 { return &v }
+	points := func(n int) []ScaleValue {
+		res := make([]ScaleValue, 0, n)
+		for v := 1; v <= n; v++ {
+			res = append(res, ScaleValue{Value: v, Label: strconv.Itoa(v), Numeric: numeric(float64(v))})
+		}
+		return res
+	}
+	return []GradingScale{
+		{Name: "Пятибалльная", Values: points(5), Default: true},
+		{Name: "Зачет/незачет", Values: []ScaleValue{{Value: 1, Label: "незачет"}, {Value: 2, Label: "зачет"}}},
+		{Name: "Десятибалльная", Values: points(10)},
+	}
+}
+
+// seedGradingScales заводит стандартные шкалы, если шкал еще нет (новое хранилище
+// или данные, сохраненные до появления шкал).
+func (s *Storage) seedGradingScales() error {
+	s.mu.RLock()
+	empty := len(s.scales) == 0
+	s.mu.RUnlock()
+	if !empty {
+		return nil
+	}
+	for _, sc := range defaultGradingScales() {
+		if _, err := s.createScale(sc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createScale добавляет шкалу оценивания.
+func (s *Storage) createScale(sc GradingScale) (GradingScale, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sc.ID = s.nextScaleID
+	if err := validateScale(&sc); err != nil {
+		return GradingScale{}, err
+	}
+	if err := s.commitLocked(journalRecord{Op: opPutScale, Scale: &sc}); err != nil {
+		return GradingScale{}, err
+	}
+	return sc, nil
+}
+
+// updateScale меняет название, значения и признак «по умолчанию». Значения, по которым
+// уже выставлены оценки, убрать нельзя; подписи и числовые эквиваленты менять можно.
+func (s *Storage) updateScale(sc GradingScale) (GradingScale, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev, ok := s.scales[sc.ID]
+	if !ok {
+		return GradingScale{}, errUnknownScale
+	}
+	if err := validateScale(&sc); err != nil {
+		return GradingScale{}, err
+	}
+	if prev.Default && !sc.Default {
+		return GradingScale{}, errors.New("choose another default scale instead")
+	}
+	for _, g := range s.grades {
+		if s.gradeScaleLocked(g).ID == sc.ID && !sc.allows(g.Value) {
+			return GradingScale{}, fmt.Errorf("%w: value %d is used by grades", errScaleInUse, g.Value)
+		}
+	}
+	if err := s.commitLocked(journalRecord{Op: opPutScale, Scale: &sc}); err != nil {
+		return GradingScale{}, err
+	}
+	return sc, nil
+}
+
+// deleteScale удаляет неиспользуемую шкалу.
+func (s *Storage) deleteScale(id int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sc, ok := s.scales[id]
+	if !ok {
+		return false, nil
+	}
+	if sc.Default || s.scaleUsedLocked(id) {
+		return false, fmt.Errorf("%w: %s", errScaleInUse, sc.Name)
+	}
+	if err := s.commitLocked(journalRecord{Op: opDeleteScale, ID: id}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// getScale возвращает шкалу по ID.
+func (s *Storage) getScale(id int64) (GradingScale, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sc, ok := s.scales[id]
+	return sc, ok
+}
+
+// listScales возвращает шкалы, упорядоченные по ID.
+func (s *Storage) listScales() []GradingScale {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	res := make([]GradingScale, 0, len(s.scales))
+	for _, sc := range s.scales {
+		res = append(res, sc)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].ID < res[j].ID })
+	return res
+}
+
+// scaleFor возвращает шкалу, по которой оценивается предмет в классе: шкала
+// предмета, иначе шкала класса, иначе шкала по умолчанию.
+func (s *Storage) scaleFor(subject, className string) (GradingScale, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sub, err := s.requireSubjectLocked(subject)
+	if err != nil {
+		return GradingScale{}, err
+	}
+	return s.scaleForLocked(sub.ID, normalizeClassName(className)), nil
+}
+
+// scaleForLocked выбирает шкалу по предмету и классу (см. scaleFor).
+func (s *Storage) scaleForLocked(subjectID int64, className string) GradingScale {
+	if sc, ok := s.scales[s.subjects[subjectID].ScaleID]; ok {
+		return sc
+	}
+	if c, ok := s.classes[className]; ok {
+		if sc, ok := s.scales[c.ScaleID]; ok {
+			return sc
+		}
+	}
+	return s.defaultScaleLocked()
+}
+
+// defaultScaleLocked возвращает шкалу по умолчанию. Пока шкалы не заведены,
+// используется пятибалльная.
+func (s *Storage) defaultScaleLocked() GradingScale {
+	for _, sc := range s.scales {
+		if sc.Default {
+			return sc
+		}
+	}
+	return defaultGradingScales()[0]
+}
+
+// gradeScaleLocked возвращает шкалу, по которой выставлена оценка; для оценок,
+// выставленных до появления шкал, — шкалу по умолчанию.
+func (s *Storage) gradeScaleLocked(g Grade) GradingScale {
+	if sc, ok := s.scales[g.ScaleID]; ok {
+		return sc
+	}
+	return s.defaultScaleLocked()
+}
+
+// requireScaleLocked проверяет ссылку на шкалу; ноль означает «не задана».
+func (s *Storage) requireScaleLocked(id int64) error {
+	if id == 0 {
+		return nil
+	}
+	if _, ok := s.scales[id]; !ok {
+		return errUnknownScale
+	}
+	return nil
+}
+
+// scaleUsedLocked проверяет, ссылаются ли на шкалу предметы, классы, оценки и итоговые оценки.
+func (s *Storage) scaleUsedLocked(id int64) bool {
+	for _, sub := range s.subjects {
+		if sub.ScaleID == id {
+			return true
+		}
+	}
+	for _, c := range s.classes {
+		if c.ScaleID == id {
+			return true
+		}
+	}
+	for _, g := range s.grades {
+		if g.ScaleID == id {
+			return true
+		}
+	}
+	for _, tg := range s.termGrades {
+		if tg.ScaleID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// putScaleLocked сохраняет шкалу; новая шкала по умолчанию снимает этот признак с прежней.
+func (s *Storage) putScaleLocked(sc GradingScale) {
+	if sc.Default {
+		for id, other := range s.scales {
+			if other.Default && id != sc.ID {
+				other.Default = false
+				s.scales[id] = other
+			}
+		}
+	}
+	s.scales[sc.ID] = sc
+	bumpCounter(&s.nextScaleID, sc.ID)
+}
+
+// validateScale нормализует шкалу и проверяет значения: целые от 1, без повторов, с подписями.
+func validateScale(sc *GradingScale) error {
+	sc.Name = strings.Join(strings.Fields(sc.Name), " ")
+	if sc.Name == "" {
+		return errors.New("name is required")
+	}
+	if len(sc.Values) < 2 {
+		return errors.New("a scale needs at least two values")
+	}
+	seen := map[int]bool{}
+	for i := range sc.Values {
+		v := &sc.Values[i]
+		v.Label = strings.TrimSpace(v.Label)
+		if v.Value < 1 {
+			return errors.New("scale values must be positive integers")
+		}
+		if seen[v.Value] {
+			return fmt.Errorf("value %d is listed twice", v.Value)
+		}
+		seen[v.Value] = true
+		if v.Label == "" {
+			v.Label = strconv.Itoa(v.Value)
+		}
+		if v.Numeric != nil && (math.IsNaN(*v.Numeric) || math.IsInf(*v.Numeric, 0)) {
+			return fmt.Errorf("numeric value of %d must be a number", v.Value)
+		}
+	}
+	sort.Slice(sc.Values, func(i, j int) bool { return sc.Values[i].Value < sc.Values[j].Value })
+	return nil
+}
+
+// allows проверяет, что значение входит в шкалу.
+func (sc GradingScale) allows(value int) bool {
+	_, ok := sc.find(value)
+	return ok
+}
+
+// find возвращает описание значения шкалы.
+func (sc GradingScale) find(value int) (ScaleValue, bool) {
+	for _, v := range sc.Values {
+		if v.Value == value {
+			return v, true
+		}
+	}
+	return ScaleValue{}, false
+}
+
+// numeric возвращает числовой эквивалент значения для средних; false — значение
+// в среднем не учитывается (например, «зачет»).
+func (sc GradingScale) numeric(value int) (float64, bool) {
+	v, ok := sc.find(value)
+	if !ok || v.Numeric == nil {
+		return 0, false
+	}
+	return *v.Numeric, true
+}
+
+// nearest возвращает значение шкалы с ближайшим к n числовым эквивалентом
+// (при равенстве — большее); false, если числовых эквивалентов в шкале нет.
+func (sc GradingScale) nearest(n float64) (int, bool) {
+	best, found := 0, false
+	bestDiff := math.Inf(1)
+	for _, v := range sc.Values {
+		if v.Numeric == nil {
+			continue
+		}
+		if d := math.Abs(*v.Numeric - n); d <= bestDiff {
+			best, bestDiff, found = v.Value, d, true
+		}
+	}
+	return best, found
+}
+
+// invalidValueError перечисляет допустимые значения шкалы в сообщении об ошибке.
+func (sc GradingScale) invalidValueError() error {
+	values := make([]string, 0, len(sc.Values))
+	for _, v := range sc.Values {
+		values = append(values, fmt.Sprintf("%d (%s)", v.Value, v.Label))
+	}
+	return fmt.Errorf("%w %q, allowed: %s", errInvalidGradeValue, sc.Name, strings.Join(values, ", "))
+}
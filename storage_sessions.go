@@ -0,0 +1,273 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// errSessionNotFound — сессия не найдена, отозвана или истекла.
+var errSessionNotFound = errors.New("session not found or expired")
+
+// sessionClient — сведения о клиенте, открывшем сессию.
+type sessionClient struct {
+	UserAgent string
+	IP        string
+}
+
+// sessionTTL задает сроки жизни токенов сессии.
+type sessionTTL struct {
+	// Access — срок жизни токена доступа.
+	Access time.Duration
+	// Refresh — сколько сессию можно продлевать refresh-токеном без повторного входа.
+	Refresh time.Duration
+}
+
+// newSessionTokens генерирует новую пару токенов и записывает их хеши в сессию.
+// Срок продления задается один раз при открытии сессии и при обновлении токенов
+// не сдвигается; токен доступа не переживает его.
+func newSessionTokens(session *Session, ttl sessionTTL, now time.Time) (SessionTokens, error) {
+	token, err := randomHex(24)
+	if err != nil {
+		return SessionTokens{}, err
+	}
+	refresh, err := randomHex(32)
+	if err != nil {
+		return SessionTokens{}, err
+	}
+	session.TokenHash = hashToken(token)
+	session.RefreshHash = hashToken(refresh)
+	if session.RefreshExpiresAt.IsZero() {
+		session.RefreshExpiresAt = now.Add(ttl.Refresh)
+	}
+	session.ExpiresAt = now.Add(ttl.Access)
+	if session.ExpiresAt.After(session.RefreshExpiresAt) {
+		session.ExpiresAt = session.RefreshExpiresAt
+	}
+	return SessionTokens{
+		Token:            token,
+		RefreshToken:     refresh,
+		ExpiresAt:        session.ExpiresAt,
+		RefreshExpiresAt: session.RefreshExpiresAt,
+	}, nil
+}
+
+// createSession открывает новую сессию пользователя с указанного клиента.
+func (s *Storage) createSession(userID int64, client sessionClient, ttl sessionTTL) (Session, SessionTokens, error) {
+	id, err := randomHex(8)
+	if err != nil {
+		return Session{}, SessionTokens{}, err
+	}
+	now := time.Now().UTC()
+	session := Session{
+		ID:         id,
+		UserID:     userID,
+		UserAgent:  client.UserAgent,
+		IP:         client.IP,
+		CreatedAt:  now,
+		LastUsedAt: now,
+	}
+	tokens, err := newSessionTokens(&session, ttl, now)
+	if err != nil {
+		return Session{}, SessionTokens{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[userID]; !ok {
+		return Session{}, SessionTokens{}, errors.New("user not found")
+	}
+	if err := s.commitLocked(journalRecord{Op: opPutSession, Session: persistSession(session)}); err != nil {
+		return Session{}, SessionTokens{}, err
+	}
+	return session, tokens, nil
+}
+
+// sessionTouchInterval — как часто обновляется время последнего использования сессии.
+// Более частые запросы с тем же токеном обходятся блокировкой на чтение.
+const sessionTouchInterval = time.Minute
+
+// sessionByToken возвращает действующую сессию и ее пользователя по токену доступа.
+// Сессии архивных пользователей (выпускников) не действуют.
+// Время последнего использования обновляется не чаще sessionTouchInterval, только в памяти,
+// и попадает на диск со снимком.
+func (s *Storage) sessionByToken(token string) (Session, User, bool) {
+	hash := hashToken(token)
+	now := time.Now().UTC()
+	s.mu.RLock()
+	session, u, ok := s.sessionByTokenLocked(hash, now)
+	s.mu.RUnlock()
+	if !ok || now.Sub(session.LastUsedAt) < sessionTouchInterval {
+		return session, u, ok
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, u, ok = s.sessionByTokenLocked(hash, now)
+	if !ok {
+		return Session{}, User{}, false
+	}
+	if now.After(session.LastUsedAt) {
+		session.LastUsedAt = now
+		s.sessions[session.ID] = session
+	}
+	return session, u, true
+}
+
+// sessionByTokenLocked находит действующую сессию по хешу токена доступа на момент now.
+func (s *Storage) sessionByTokenLocked(hash string, now time.Time) (Session, User, bool) {
+	id, ok := s.tokens[hash]
+	if !ok {
+		return Session{}, User{}, false
+	}
+	session := s.sessions[id]
+	if !now.Before(session.ExpiresAt) {
+		return Session{}, User{}, false
+	}
+	u, ok := s.users[session.UserID]
+	if !ok || u.Archived {
+		return Session{}, User{}, false
+	}
+	return session, u, true
+}
+
+// refreshSession выдает новую пару токенов по refresh-токену; старые токены перестают действовать.
+// Срок продления сессии не сдвигается: по его истечении нужен повторный вход. Архивным
+// пользователям токены не выдаются. Адрес и user agent сессии обновляются на данные
+// клиента, выполнившего обновление.
+func (s *Storage) refreshSession(refreshToken string, client sessionClient, ttl sessionTTL) (Session, SessionTokens, User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id, ok := s.refreshTokens[hashToken(refreshToken)]
+	if !ok {
+		return Session{}, SessionTokens{}, User{}, errSessionNotFound
+	}
+	session := s.sessions[id]
+	now := time.Now().UTC()
+	if !now.Before(session.RefreshExpiresAt) {
+		return Session{}, SessionTokens{}, User{}, errSessionNotFound
+	}
+	u, ok := s.users[session.UserID]
+	if !ok || u.Archived {
+		return Session{}, SessionTokens{}, User{}, errSessionNotFound
+	}
+	tokens, err := newSessionTokens(&session, ttl, now)
+	if err != nil {
+		return Session{}, SessionTokens{}, User{}, err
+	}
+	session.LastUsedAt = now
+	session.UserAgent = client.UserAgent
+	session.IP = client.IP
+	if err := s.commitLocked(journalRecord{Op: opPutSession, Session: persistSession(session)}); err != nil {
+		return Session{}, SessionTokens{}, User{}, err
+	}
+	return session, tokens, u, nil
+}
+
+// listSessionsByUser возвращает сессии пользователя, начиная с последней использованной.
+func (s *Storage) listSessionsByUser(userID int64) []Session {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	res := []Session{}
+	for _, session := range s.sessions {
+		if session.UserID == userID {
+			res = append(res, session)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].LastUsedAt.After(res[j].LastUsedAt)
+	})
+	return res
+}
+
+// revokeUserSessions завершает все сессии пользователя и возвращает их количество.
+func (s *Storage) revokeUserSessions(userID int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, session := range s.sessions {
+		if session.UserID == userID {
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	if err := s.commitLocked(journalRecord{Op: opRevokeUserSessions, ID: userID}); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// revokeSession удаляет сессию по ID.
+func (s *Storage) revokeSession(id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.sessions[id]; !ok {
+		return false, nil
+	}
+	if err := s.commitLocked(journalRecord{Op: opDeleteSession, SessionID: id}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// pruneSessions удаляет сессии, которые уже нельзя продлить, и возвращает их количество.
+func (s *Storage) pruneSessions() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UTC()
+	n := 0
+	for _, session := range s.sessions {
+		if !now.Before(session.RefreshExpiresAt) {
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	if err := s.commitLocked(journalRecord{Op: opPruneSessions, Time: &now}); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// putSessionLocked сохраняет сессию в памяти и обновляет индексы токенов.
+func (s *Storage) putSessionLocked(session Session) {
+	if prev, ok := s.sessions[session.ID]; ok {
+		delete(s.tokens, prev.TokenHash)
+		delete(s.refreshTokens, prev.RefreshHash)
+	}
+	s.sessions[session.ID] = session
+	s.tokens[session.TokenHash] = session.ID
+	s.refreshTokens[session.RefreshHash] = session.ID
+}
+
+// deleteSessionLocked удаляет сессию и ее токены из памяти.
+func (s *Storage) deleteSessionLocked(id string) {
+	session, ok := s.sessions[id]
+	if !ok {
+		return
+	}
+	delete(s.tokens, session.TokenHash)
+	delete(s.refreshTokens, session.RefreshHash)
+	delete(s.sessions, id)
+}
+
+// revokeUserSessionsLocked удаляет из памяти все сессии пользователя.
+func (s *Storage) revokeUserSessionsLocked(userID int64) {
+	for id, session := range s.sessions {
+		if session.UserID == userID {
+			s.deleteSessionLocked(id)
+		}
+	}
+}
+
+// pruneSessionsLocked удаляет сессии с истекшим refresh-токеном на момент now.
+func (s *Storage) pruneSessionsLocked(now time.Time) {
+	for id, session := range s.sessions {
+		if !now.Before(session.RefreshExpiresAt) {
+			s.deleteSessionLocked(id)
+		}
+	}
+}
@@ -0,0 +1,399 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	// errUnknownSubject — предмет не найден в справочнике ни по названию, ни по синониму.
+	errUnknownSubject = errors.New("unknown subject")
+	// errSubjectInUse — предмет нельзя удалить, пока на него ссылаются записи.
+	errSubjectInUse = errors.New("subject is in use")
+)
+
+// subjectKey приводит название предмета к ключу сравнения: без регистра,
+// лишних пробелов и с «ё», замененной на «е».
+func subjectKey(name string) string {
+	name = strings.ToLower(strings.Join(strings.Fields(name), " "))
+	return strings.ReplaceAll(name, "ё", "е")
+}
+
+// subjectMigrationParams — как сопоставлять свободные названия предметов со справочником.
+type subjectMigrationParams struct {
+	// Mapping — явные сопоставления «название → ID предмета»; названия сравниваются по subjectKey.
+	Mapping map[string]int64 `json:"mapping,omitempty"`
+	// CreateMissing — завести в справочнике предметы для названий, которые не удалось сопоставить.
+	CreateMissing bool `json:"createMissing,omitempty"`
+	// Created — предметы, заведенные при миграции (заполняется хранилищем для журнала).
+	Created []Subject `json:"created,omitempty"`
+	// Resolved — итоговое сопоставление ключей названий с ID (заполняется хранилищем).
+	Resolved map[string]int64 `json:"resolved,omitempty"`
+}
+
+// subjectMapping — одно свободное название предмета и то, во что оно превратится.
+type subjectMapping struct {
+	Text      string `json:"text"`
+	Records   int    `json:"records"`
+	SubjectID int64  `json:"subjectId,omitempty"`
+	Subject   string `json:"subject,omitempty"`
+	// Source: catalog — совпало со справочником, mapping — явное сопоставление,
+	// created — предмет будет заведен, unmapped — остается без предмета.
+	Source string `json:"source"`
+}
+
+// subjectMigrationReport — результат (или план) миграции свободных названий предметов.
+type subjectMigrationReport struct {
+	Mappings []subjectMapping `json:"mappings"`
+	Created  []Subject        `json:"created"`
+	Unmapped int              `json:"unmapped"`
+}
+
+// createSubject добавляет предмет в справочник.
+func (s *Storage) createSubject(sub Subject) (Subject, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub.ID = s.nextSubjectID
+	if err := s.validateSubjectLocked(&sub); err != nil {
+		return Subject{}, err
+	}
+	if sub.CreatedAt.IsZero() {
+		sub.CreatedAt = time.Now().UTC()
+	}
+	if err := s.commitLocked(journalRecord{Op: opPutSubject, SubjectEntry: &sub}); err != nil {
+		return Subject{}, err
+	}
+	return sub, nil
+}
+
+// updateSubject меняет название, сокращение и синонимы предмета.
+// Новое название проставляется во все записи, ссылающиеся на предмет.
+func (s *Storage) updateSubject(sub Subject) (Subject, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev, ok := s.subjects[sub.ID]
+	if !ok {
+		return Subject{}, errors.New("subject not found")
+	}
+	sub.CreatedAt = prev.CreatedAt
+	if err := s.validateSubjectLocked(&sub); err != nil {
+		return Subject{}, err
+	}
+	if err := s.commitLocked(journalRecord{Op: opPutSubject, SubjectEntry: &sub}); err != nil {
+		return Subject{}, err
+	}
+	return sub, nil
+}
+
+// deleteSubject удаляет предмет, если на него не ссылаются оценки, ДЗ, расписание и закрепления.
+func (s *Storage) deleteSubject(id int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub, ok := s.subjects[id]
+	if !ok {
+		return false, nil
+	}
+	if s.subjectUsedLocked()[id] {
+		return false, fmt.Errorf("%w: %s", errSubjectInUse, sub.Name)
+	}
+	if err := s.commitLocked(journalRecord{Op: opDeleteSubject, ID: id}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// getSubject возвращает предмет по ID.
+func (s *Storage) getSubject(id int64) (Subject, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sub, ok := s.subjects[id]
+	return sub, ok
+}
+
+// listSubjects возвращает справочник предметов, упорядоченный по названию.
+func (s *Storage) listSubjects() []Subject {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	res := make([]Subject, 0, len(s.subjects))
+	for _, sub := range s.subjects {
+		res = append(res, sub)
+	}
+	sort.Slice(res, func(i, j int) bool { return subjectKey(res[i].Name) < subjectKey(res[j].Name) })
+	return res
+}
+
+// resolveSubject ищет предмет по названию, сокращению или синониму.
+func (s *Storage) resolveSubject(name string) (Subject, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.findSubjectLocked(name)
+}
+
+// previewSubjectMigration показывает, как свободные названия предметов будут сопоставлены
+// со справочником, ничего не меняя.
+func (s *Storage) previewSubjectMigration(p subjectMigrationParams) (subjectMigrationReport, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	report, _, err := s.planSubjectMigrationLocked(p)
+	return report, err
+}
+
+// applySubjectMigration проставляет предметы из справочника записям со свободными
+// названиями одной операцией журнала; при CreateMissing заводит недостающие предметы.
+func (s *Storage) applySubjectMigration(p subjectMigrationParams) (subjectMigrationReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	report, rec, err := s.planSubjectMigrationLocked(p)
+	if err != nil {
+		return subjectMigrationReport{}, err
+	}
+	if len(rec.Resolved) == 0 {
+		return report, nil
+	}
+	if err := s.commitLocked(journalRecord{Op: opMigrateSubjects, SubjectMigration: &rec}); err != nil {
+		return subjectMigrationReport{}, err
+	}
+	return report, nil
+}
+
+// planSubjectMigrationLocked собирает свободные названия предметов из записей без subjectId
+// и сопоставляет их: явным Mapping, затем по справочнику, затем (CreateMissing) новым предметом.
+func (s *Storage) planSubjectMigrationLocked(p subjectMigrationParams) (subjectMigrationReport, subjectMigrationParams, error) {
+	mapping := map[string]int64{}
+	for text, id := range p.Mapping {
+		if _, ok := s.subjects[id]; !ok {
+			return subjectMigrationReport{}, subjectMigrationParams{}, fmt.Errorf("mapping for %q: subject %d not found", text, id)
+		}
+		mapping[subjectKey(text)] = id
+	}
+
+	counts := map[string]int{}
+	spellings := map[string]map[string]int{}
+	s.forEachSubjectRefLocked(func(subjectID int64, text string) {
+		key := subjectKey(text)
+		if subjectID != 0 || key == "" {
+			return
+		}
+		counts[key]++
+		if spellings[key] == nil {
+			spellings[key] = map[string]int{}
+		}
+		spellings[key][strings.TrimSpace(text)]++
+	})
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	report := subjectMigrationReport{Mappings: []subjectMapping{}, Created: []Subject{}}
+	rec := subjectMigrationParams{Resolved: map[string]int64{}}
+	nextID := s.nextSubjectID
+	for _, key := range keys {
+		m := subjectMapping{Text: commonSpelling(spellings[key]), Records: counts[key]}
+		if id, ok := mapping[key]; ok {
+			m.SubjectID, m.Subject, m.Source = id, s.subjects[id].Name, "mapping"
+		} else if sub, ok := s.findSubjectLocked(key); ok {
+			m.SubjectID, m.Subject, m.Source = sub.ID, sub.Name, "catalog"
+		} else if p.CreateMissing {
+			sub := Subject{ID: nextID, Name: m.Text, Aliases: []string{}, CreatedAt: time.Now().UTC()}
+			nextID++
+			rec.Created = append(rec.Created, sub)
+			report.Created = append(report.Created, sub)
+			m.SubjectID, m.Subject, m.Source = sub.ID, sub.Name, "created"
+		} else {
+			m.Source = "unmapped"
+			report.Unmapped += m.Records
+		}
+		if m.SubjectID != 0 {
+			rec.Resolved[key] = m.SubjectID
+		}
+		report.Mappings = append(report.Mappings, m)
+	}
+	return report, rec, nil
+}
+
+// commonSpelling возвращает самое частое написание (при равенстве — меньшее по алфавиту).
+func commonSpelling(spellings map[string]int) string {
+	best, bestN := "", 0
+	for text, n := range spellings {
+		if n > bestN || (n == bestN && text < best) {
+			best, bestN = text, n
+		}
+	}
+	return best
+}
+
+// migrateSubjectsLocked применяет миграцию: заводит созданные предметы и проставляет
+// subjectId и каноничное название записям без предмета. Закрепления, совпавшие после
+// сопоставления (например, «Алгебра» и «Математика» в одном классе), схлопываются.
+func (s *Storage) migrateSubjectsLocked(p subjectMigrationParams) {
+	for _, sub := range p.Created {
+		s.putSubjectLocked(sub)
+	}
+	resolve := func(subjectID *int64, text *string) {
+		if *subjectID != 0 {
+			return
+		}
+		if id, ok := p.Resolved[subjectKey(*text)]; ok {
+			if sub, ok := s.subjects[id]; ok {
+				*subjectID, *text = sub.ID, sub.Name
+			}
+		}
+	}
+	s.updateSubjectRefsLocked(resolve)
+	s.dedupeAssignmentsLocked()
+}
+
+// requireSubjectLocked находит предмет справочника по названию или синониму.
+func (s *Storage) requireSubjectLocked(name string) (Subject, error) {
+	sub, ok := s.findSubjectLocked(name)
+	if !ok {
+		return Subject{}, fmt.Errorf("%w %q", errUnknownSubject, strings.TrimSpace(name))
+	}
+	return sub, nil
+}
+
+// findSubjectLocked ищет предмет по названию, сокращению или синониму (см. subjectKey).
+func (s *Storage) findSubjectLocked(name string) (Subject, bool) {
+	key := subjectKey(name)
+	if key == "" {
+		return Subject{}, false
+	}
+	for _, sub := range s.subjects {
+		for _, k := range sub.keys() {
+			if k == key {
+				return sub, true
+			}
+		}
+	}
+	return Subject{}, false
+}
+
+// keys возвращает ключи, по которым предмет находится: название, сокращение, синонимы.
+func (sub Subject) keys() []string {
+	res := []string{subjectKey(sub.Name)}
+	if sub.ShortName != "" {
+		res = append(res, subjectKey(sub.ShortName))
+	}
+	for _, alias := range sub.Aliases {
+		res = append(res, subjectKey(alias))
+	}
+	return res
+}
+
+// validateSubjectLocked нормализует предмет и проверяет, что его названия
+// не совпадают с названиями других предметов, а шкала оценивания существует.
+func (s *Storage) validateSubjectLocked(sub *Subject) error {
+	sub.Name = strings.Join(strings.Fields(sub.Name), " ")
+	sub.ShortName = strings.Join(strings.Fields(sub.ShortName), " ")
+	if sub.Name == "" {
+		return errors.New("name is required")
+	}
+	aliases := []string{}
+	seen := map[string]bool{subjectKey(sub.Name): true, subjectKey(sub.ShortName): true}
+	for _, alias := range sub.Aliases {
+		alias = strings.Join(strings.Fields(alias), " ")
+		if alias == "" || seen[subjectKey(alias)] {
+			continue
+		}
+		seen[subjectKey(alias)] = true
+		aliases = append(aliases, alias)
+	}
+	sub.Aliases = aliases
+	if err := s.requireScaleLocked(sub.ScaleID); err != nil {
+		return err
+	}
+	for _, key := range sub.keys() {
+		if other, ok := s.findSubjectLocked(key); ok && other.ID != sub.ID {
+			return fmt.Errorf("%q is already used by subject %s", key, other.Name)
+		}
+	}
+	return nil
+}
+
+// putSubjectLocked сохраняет предмет и проставляет его название в ссылающиеся записи.
+func (s *Storage) putSubjectLocked(sub Subject) {
+	prev, existed := s.subjects[sub.ID]
+	s.subjects[sub.ID] = sub
+	bumpCounter(&s.nextSubjectID, sub.ID)
+	if existed && prev.Name != sub.Name {
+		s.updateSubjectRefsLocked(func(subjectID *int64, text *string) {
+			if *subjectID == sub.ID {
+				*text = sub.Name
+			}
+		})
+	}
+}
+
+// subjectUsedLocked собирает ID предметов, на которые ссылаются записи.
+func (s *Storage) subjectUsedLocked() map[int64]bool {
+	used := map[int64]bool{}
+	s.forEachSubjectRefLocked(func(subjectID int64, _ string) {
+		if subjectID != 0 {
+			used[subjectID] = true
+		}
+	})
+	return used
+}
+
+// forEachSubjectRefLocked обходит ссылки на предметы в оценках, итоговых оценках, ДЗ,
+// расписании, закреплениях, отметках посещаемости и журнале уроков.
+func (s *Storage) forEachSubjectRefLocked(fn func(subjectID int64, text string)) {
+	for _, g := range s.grades {
+		fn(g.SubjectID, g.Subject)
+	}
+	for _, tg := range s.termGrades {
+		fn(tg.SubjectID, tg.Subject)
+	}
+	for _, hw := range s.homework {
+		fn(hw.SubjectID, hw.Subject)
+	}
+	for _, entry := range s.schedule {
+		fn(entry.SubjectID, entry.Subject)
+	}
+	for _, a := range s.assignments {
+		fn(a.SubjectID, a.Subject)
+	}
+	for _, a := range s.attendance {
+		fn(a.SubjectID, a.Subject)
+	}
+	for _, l := range s.lessons {
+		fn(l.SubjectID, l.Subject)
+	}
+}
+
+// updateSubjectRefsLocked позволяет fn изменить ссылку на предмет в каждой записи.
+func (s *Storage) updateSubjectRefsLocked(fn func(subjectID *int64, text *string)) {
+	for id, g := range s.grades {
+		fn(&g.SubjectID, &g.Subject)
+		s.grades[id] = g
+	}
+	for id, tg := range s.termGrades {
+		fn(&tg.SubjectID, &tg.Subject)
+		s.termGrades[id] = tg
+	}
+	for id, hw := range s.homework {
+		fn(&hw.SubjectID, &hw.Subject)
+		s.homework[id] = hw
+	}
+	for id, entry := range s.schedule {
+		fn(&entry.SubjectID, &entry.Subject)
+		s.schedule[id] = entry
+	}
+	for id, a := range s.assignments {
+		fn(&a.SubjectID, &a.Subject)
+		s.assignments[id] = a
+	}
+	for id, a := range s.attendance {
+		fn(&a.SubjectID, &a.Subject)
+		s.attendance[id] = a
+	}
+	for id, l := range s.lessons {
+		fn(&l.SubjectID, &l.Subject)
+		s.lessons[id] = l
+	}
+}
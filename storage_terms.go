@@ -0,0 +1,325 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	// errUnknownTerm — учебный период не найден.
+	errUnknownTerm = errors.New("unknown term")
+	// errTermInUse — у периода есть утвержденные итоговые оценки.
+	errTermInUse = errors.New("term has approved grades")
+	// errTermLocked — оценки периода заблокированы утвержденной итоговой оценкой.
+	errTermLocked = errors.New("term grade is approved, grades of the term are locked")
+	// errTermGradeExists — итоговая оценка уже утверждена.
+	errTermGradeExists = errors.New("term grade is already approved")
+	// errNoTermProposal — оценок за период недостаточно, итоговую нужно указать явно.
+	errNoTermProposal = errors.New("not enough grades to propose a term grade, value is required")
+)
+
+// defaultTermRounding — правила округления до того, как администратор их изменил.
+var defaultTermRounding = TermRounding{Threshold: 0.5, MinGrades: 3}
+
+// termGradeApproval — запрос учителя на утверждение итоговой оценки. Нулевое Value
+// означает согласие с предложенной оценкой.
+type termGradeApproval struct {
+	TermID    int64
+	StudentID int64
+	Subject   string
+	Value     int
+	Reason    string
+	TeacherID int64
+}
+
+// createTerm добавляет учебный период; пустой учебный год заменяется текущим.
+func (s *Storage) createTerm(t Term) (Term, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t.ID = s.nextTermID
+	if err := s.validateTermLocked(&t); err != nil {
+		return Term{}, err
+	}
+	if err := s.commitLocked(journalRecord{Op: opPutTerm, Term: &t}); err != nil {
+		return Term{}, err
+	}
+	return t, nil
+}
+
+// updateTerm меняет название и даты периода. Даты периода с утвержденными
+// итоговыми оценками менять нельзя: от них зависит, какие оценки заблокированы.
+func (s *Storage) updateTerm(t Term) (Term, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev, ok := s.terms[t.ID]
+	if !ok {
+		return Term{}, errUnknownTerm
+	}
+	if err := s.validateTermLocked(&t); err != nil {
+		return Term{}, err
+	}
+	if (t.StartDate != prev.StartDate || t.EndDate != prev.EndDate || t.AcademicYear != prev.AcademicYear) && s.termUsedLocked(t.ID) {
+		return Term{}, errTermInUse
+	}
+	if err := s.commitLocked(journalRecord{Op: opPutTerm, Term: &t}); err != nil {
+		return Term{}, err
+	}
+	return t, nil
+}
+
+// deleteTerm удаляет период без утвержденных итоговых оценок.
+func (s *Storage) deleteTerm(id int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.terms[id]; !ok {
+		return false, nil
+	}
+	if s.termUsedLocked(id) {
+		return false, errTermInUse
+	}
+	if err := s.commitLocked(journalRecord{Op: opDeleteTerm, ID: id}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// getTerm возвращает период по ID.
+func (s *Storage) getTerm(id int64) (Term, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	t, ok := s.terms[id]
+	return t, ok
+}
+
+// listTerms возвращает периоды в порядке дат; пустой academicYear — все годы.
+func (s *Storage) listTerms(academicYear string) []Term {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	res := []Term{}
+	for _, t := range s.terms {
+		if academicYear == "" || t.AcademicYear == academicYear {
+			res = append(res, t)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].StartDate < res[j].StartDate })
+	return res
+}
+
+// termRounding возвращает действующие правила округления итоговых оценок.
+func (s *Storage) termRounding() TermRounding {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.rounding
+}
+
+// setTermRounding меняет правила округления; утвержденные оценки не пересчитываются.
+func (s *Storage) setTermRounding(r TermRounding) (TermRounding, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !(r.Threshold > 0 && r.Threshold < 1) {
+		return TermRounding{}, errors.New("threshold must be between 0 and 1")
+	}
+	if r.MinGrades < 1 {
+		return TermRounding{}, errors.New("minGrades must be at least 1")
+	}
+	if err := s.commitLocked(journalRecord{Op: opSetTermRounding, Rounding: &r}); err != nil {
+		return TermRounding{}, err
+	}
+	return r, nil
+}
+
+// approveTermGrade утверждает итоговую оценку: предложенную (Value == 0) или
+// выставленную учителем вместо нее (тогда нужна причина). После этого оценки
+// ученика по предмету за период нельзя добавлять, исправлять и удалять.
+func (s *Storage) approveTermGrade(a termGradeApproval) (TermGrade, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	term, ok := s.terms[a.TermID]
+	if !ok {
+		return TermGrade{}, errUnknownTerm
+	}
+	sub, err := s.requireSubjectLocked(a.Subject)
+	if err != nil {
+		return TermGrade{}, err
+	}
+	p := s.termGradeProposalLocked(term, sub, a.StudentID)
+	if p.Approved != nil {
+		return TermGrade{}, errTermGradeExists
+	}
+	tg := TermGrade{
+		ID:         s.nextTermGradeID,
+		TermID:     term.ID,
+		StudentID:  a.StudentID,
+		SubjectID:  sub.ID,
+		Subject:    sub.Name,
+		Value:      a.Value,
+		ScaleID:    p.ScaleID,
+		Average:    p.Average,
+		Proposed:   p.Proposed,
+		Reason:     strings.TrimSpace(a.Reason),
+		TeacherID:  a.TeacherID,
+		ApprovedAt: time.Now().UTC(),
+	}
+	scale := s.scales[p.ScaleID]
+	switch {
+	case tg.Value == 0 && p.Proposed == 0:
+		return TermGrade{}, errNoTermProposal
+	case tg.Value == 0:
+		tg.Value = p.Proposed
+	case !scale.allows(tg.Value):
+		return TermGrade{}, scale.invalidValueError()
+	}
+	// без предложенной оценки учитель ставит итоговую сам, это не считается заменой
+	tg.Overridden = p.Proposed != 0 && tg.Value != p.Proposed
+	if tg.Overridden && tg.Reason == "" {
+		return TermGrade{}, errors.New("reason is required when the term grade differs from the proposed one")
+	}
+	if err := s.commitLocked(journalRecord{Op: opPutTermGrade, TermGrade: &tg}); err != nil {
+		return TermGrade{}, err
+	}
+	return tg, nil
+}
+
+// reopenTermGrade отменяет утверждение итоговой оценки и снимает блокировку оценок.
+func (s *Storage) reopenTermGrade(id int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.termGrades[id]; !ok {
+		return false, nil
+	}
+	if err := s.commitLocked(journalRecord{Op: opDeleteTermGrade, ID: id}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// listTermGrades возвращает утвержденные итоговые оценки, упорядоченные по периоду,
+// ученику и предмету. Нулевые termID и studentID означают «без фильтра».
+func (s *Storage) listTermGrades(termID, studentID int64) []TermGrade {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	res := []TermGrade{}
+	for _, tg := range s.termGrades {
+		if termID != 0 && tg.TermID != termID {
+			continue
+		}
+		if studentID != 0 && tg.StudentID != studentID {
+			continue
+		}
+		res = append(res, tg)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		a, b := res[i], res[j]
+		if a.TermID != b.TermID {
+			return s.terms[a.TermID].StartDate < s.terms[b.TermID].StartDate
+		}
+		if a.StudentID != b.StudentID {
+			return a.StudentID < b.StudentID
+		}
+		return a.Subject < b.Subject
+	})
+	return res
+}
+
+// termGradeProposalLocked вычисляет предложенную итоговую оценку ученика по предмету за
+// период по данным хранилища (см. gradeMath.termProposal).
+func (s *Storage) termGradeProposalLocked(term Term, sub Subject, studentID int64) TermGradeProposal {
+	grades := []Grade{}
+	for _, g := range s.grades {
+		if g.StudentID == studentID {
+			grades = append(grades, g)
+		}
+	}
+	var approved *TermGrade
+	for _, tg := range s.termGrades {
+		if tg.TermID == term.ID && tg.StudentID == studentID && tg.SubjectID == sub.ID {
+			tg := tg
+			approved = &tg
+			break
+		}
+	}
+	scale := s.scaleForLocked(sub.ID, s.users[studentID].ClassName)
+	return s.gradeMathLocked().termProposal(term, sub, studentID, grades, scale, s.rounding, approved)
+}
+
+// roundAverage округляет средний балл до целого: вверх, если дробная часть не меньше threshold.
+func roundAverage(avg, threshold float64) float64 {
+	whole := math.Floor(avg)
+	// средний балл уже округлен до сотых, допуск защищает от ошибок представления 0.1 + 0.2
+	if avg-whole >= threshold-1e-9 {
+		whole++
+	}
+	return whole
+}
+
+// termLockedLocked проверяет, закрыта ли утвержденной итоговой оценкой оценка
+// ученика по предмету за указанную дату.
+func (s *Storage) termLockedLocked(studentID, subjectID int64, date string) bool {
+	for _, tg := range s.termGrades {
+		if tg.StudentID != studentID || tg.SubjectID != subjectID {
+			continue
+		}
+		if t, ok := s.terms[tg.TermID]; ok && date >= t.StartDate && date <= t.EndDate {
+			return true
+		}
+	}
+	return false
+}
+
+// termUsedLocked проверяет, есть ли у периода утвержденные итоговые оценки.
+func (s *Storage) termUsedLocked(id int64) bool {
+	for _, tg := range s.termGrades {
+		if tg.TermID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// validateTermLocked нормализует период и проверяет даты и пересечения с другими периодами.
+func (s *Storage) validateTermLocked(t *Term) error {
+	t.Name = strings.TrimSpace(t.Name)
+	t.AcademicYear = strings.TrimSpace(t.AcademicYear)
+	t.StartDate = strings.TrimSpace(t.StartDate)
+	t.EndDate = strings.TrimSpace(t.EndDate)
+	if t.Name == "" {
+		return errors.New("name is required")
+	}
+	if t.AcademicYear == "" {
+		t.AcademicYear = s.currentYearLocked()
+	}
+	if !validAcademicYear(t.AcademicYear) {
+		return errors.New("academicYear must look like 2026/2027")
+	}
+	if _, err := time.Parse("2006-01-02", t.StartDate); err != nil {
+		return errors.New("startDate must be YYYY-MM-DD")
+	}
+	if _, err := time.Parse("2006-01-02", t.EndDate); err != nil {
+		return errors.New("endDate must be YYYY-MM-DD")
+	}
+	if t.EndDate < t.StartDate {
+		return errors.New("endDate must not be before startDate")
+	}
+	for _, other := range s.terms {
+		if other.ID != t.ID && t.StartDate <= other.EndDate && other.StartDate <= t.EndDate {
+			return fmt.Errorf("term overlaps %q (%s — %s)", other.Name, other.StartDate, other.EndDate)
+		}
+	}
+	return nil
+}
+
+// putTermLocked сохраняет период в памяти.
+func (s *Storage) putTermLocked(t Term) {
+	s.terms[t.ID] = t
+	bumpCounter(&s.nextTermID, t.ID)
+}
+
+// putTermGradeLocked сохраняет итоговую оценку в памяти.
+func (s *Storage) putTermGradeLocked(tg TermGrade) {
+	s.termGrades[tg.ID] = tg
+	bumpCounter(&s.nextTermGradeID, tg.ID)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// persister — долговременное хранение журнала изменений Storage. Сами данные и их проверка
+// живут в Storage, в памяти; persister лишь восстанавливает состояние при открытии и сохраняет
+// каждую операцию до того, как она будет применена. Любая реализация должна проходить общий
+// набор проверок (см. store_conformance_test.go).
+type persister interface {
+	// load передает apply сохраненные записи по порядку и возвращает номер последней из них.
+	load(apply func(journalRecord) error) (int64, error)
+	// append надежно сохраняет запись.
+	append(rec journalRecord) error
+	// shouldSnapshot сообщает, что накопленные записи пора свернуть в снимок.
+	shouldSnapshot() bool
+	// snapshot заменяет сохраненное состояние записями снимка.
+	snapshot(records []journalRecord) error
+	close() error
+}
+
+// memoryJournal — persister без сохранения: данные живут до остановки процесса.
+type memoryJournal struct{}
+
+func (memoryJournal) load(func(journalRecord) error) (int64, error) { return 0, nil }
+func (memoryJournal) append(journalRecord) error                    { return nil }
+func (memoryJournal) shouldSnapshot() bool                          { return false }
+func (memoryJournal) snapshot([]journalRecord) error                { return nil }
+func (memoryJournal) close() error                                  { return nil }
+
+// storeBackend описывает подключаемый способ хранения журнала.
+type storeBackend struct {
+	// open открывает журнал; dataDir используется только персистентными бэкендами.
+	open func(dataDir string) (persister, error)
+	// persistent — сохраняет ли бэкенд данные между перезапусками.
+	persistent bool
+}
+
+// storeBackends — зарегистрированные бэкенды, выбираются через STORAGE_BACKEND.
+var storeBackends = map[string]storeBackend{
+	"memory": {
+		open: func(string) (persister, error) {
+			return memoryJournal{}, nil
+		},
+	},
+	"file": {
+		open: func(dataDir string) (persister, error) {
+			j, err := openJournal(dataDir)
+			if err != nil {
+				return nil, err
+			}
+			return j, nil
+		},
+		persistent: true,
+	},
+}
+
+// openStore открывает хранилище поверх журнала выбранного бэкенда.
+func openStore(backend, dataDir string) (*Storage, error) {
+	b, ok := storeBackends[backend]
+	if !ok {
+		return nil, fmt.Errorf("unknown storage backend %q (available: %v)", backend, storeBackendNames())
+	}
+	return b.openStorage(dataDir)
+}
+
+// openStorage открывает журнал бэкенда и восстанавливает из него хранилище.
+func (b storeBackend) openStorage(dataDir string) (*Storage, error) {
+	p, err := b.open(dataDir)
+	if err != nil {
+		return nil, err
+	}
+	return NewStorage(p)
+}
+
+// storeBackendNames возвращает отсортированные имена бэкендов.
+func storeBackendNames() []string {
+	names := make([]string, 0, len(storeBackends))
+	for name := range storeBackends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
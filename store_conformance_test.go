@@ -0,0 +1,2078 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// conformanceCheck — один сценарий общего набора проверок хранилища.
+// Каждая проверка получает свежее хранилище поверх журнала бэкенда.
+type conformanceCheck struct {
+	name string
+	run  func(st *Storage) error
+}
+
+// conformanceChecks — поведение хранилища, которое обязан сохранять любой бэкенд журнала.
+var conformanceChecks = []conformanceCheck{
+	{"seeded admin", checkSeededAdmin},
+	{"classes", checkClasses},
+	{"users", checkUsers},
+	{"password hash", checkPasswordHash},
+	{"bulk create users", checkCreateUsers},
+	{"update user", checkUpdateUser},
+	{"students sorted by class", checkStudentsSorted},
+	{"sessions", checkSessions},
+	{"password change and reset", checkPasswordChange},
+	{"invites", checkInvites},
+	{"subjects", checkSubjects},
+	{"teacher assignments", checkAssignments},
+	{"schedule", checkSchedule},
+	{"schedule photos", checkSchedulePhotos},
+	{"grades", checkGrades},
+	{"grade types and averages", checkGradeTypes},
+	{"terms and term grades", checkTerms},
+	{"grading scales", checkScales},
+	{"journal matrix", checkJournalMatrix},
+	{"attendance", checkAttendance},
+	{"lesson log", checkLessons},
+	{"student diary", checkDiary},
+	{"homework", checkHomework},
+	{"rollover", checkRollover},
+}
+
+// TestStoreConformance прогоняет набор проверок для каждого зарегистрированного бэкенда.
+// Для персистентных бэкендов дополнительно проверяется переживание перезапуска.
+func TestStoreConformance(t *testing.T) {
+	for _, name := range storeBackendNames() {
+		backend := storeBackends[name]
+		t.Run(name, func(t *testing.T) {
+			for _, check := range conformanceChecks {
+				t.Run(check.name, func(t *testing.T) {
+					if err := runConformanceCheck(t.TempDir(), backend, check.run); err != nil {
+						t.Fatal(err)
+					}
+				})
+			}
+			if backend.persistent {
+				t.Run("reopen", func(t *testing.T) {
+					if err := checkReopen(t.TempDir(), backend); err != nil {
+						t.Fatal(err)
+					}
+				})
+			}
+		})
+	}
+}
+
+// runConformanceCheck открывает хранилище в каталоге dir и выполняет проверку.
+func runConformanceCheck(dir string, backend storeBackend, run func(*Storage) error) error {
+	st, err := backend.openStorage(dir)
+	if err != nil {
+		return fmt.Errorf("open: %w", err)
+	}
+	runErr := run(st)
+	if err := st.Close(); err != nil && runErr == nil {
+		runErr = fmt.Errorf("close: %w", err)
+	}
+	return runErr
+}
+
+// addClasses регистрирует классы, на которые ссылается проверка.
+func addClasses(st *Storage, names ...string) error {
+	for _, name := range names {
+		grade, letter := parseClassName(name)
+		if _, err := st.createClass(Class{Grade: grade, Letter: letter, AcademicYear: "2026/2027"}); err != nil {
+			return fmt.Errorf("createClass %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// addSubjects заводит в справочнике предметы, на которые ссылается проверка.
+func addSubjects(st *Storage, names ...string) error {
+	for _, name := range names {
+		if _, err := st.createSubject(Subject{Name: name}); err != nil {
+			return fmt.Errorf("createSubject %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func checkSeededAdmin(st *Storage) error {
+	u, ok := st.findUserByEmail("  ADMIN@school.local ")
+	if !ok {
+		return fmt.Errorf("default admin not found")
+	}
+	if u.Role != RoleAdmin {
+		return fmt.Errorf("default admin role = %q", u.Role)
+	}
+	if u.PasswordHash == "" {
+		return fmt.Errorf("default admin has no password hash")
+	}
+	return nil
+}
+
+func checkClasses(st *Storage) error {
+	teacher, err := st.createUser(User{FullName: "T", Email: "hr@school.local", Role: RoleTeacher})
+	if err != nil {
+		return err
+	}
+	c, err := st.createClass(Class{Grade: 7, Letter: "A", AcademicYear: "2026/2027", HomeroomTeacherID: teacher.ID})
+	if err != nil {
+		return err
+	}
+	if c.Name != "7A" {
+		return fmt.Errorf("createClass name = %q, want 7A", c.Name)
+	}
+	if _, err := st.createClass(Class{Grade: 7, Letter: "a"}); err == nil {
+		return fmt.Errorf("duplicate class accepted")
+	}
+	if _, err := st.createClass(Class{Grade: 10, Letter: "B", HomeroomTeacherID: 1}); err == nil {
+		return fmt.Errorf("admin accepted as homeroom teacher")
+	}
+	if err := addClasses(st, "10B", "1A"); err != nil {
+		return err
+	}
+	if got, ok := st.getClass("7 а"); !ok || got.HomeroomTeacherID != teacher.ID {
+		return fmt.Errorf("getClass = %+v, %v", got, ok)
+	}
+	// литеры без латинского двойника сохраняются
+	b, err := st.createClass(Class{Grade: 5, Letter: "б"})
+	if err != nil || b.Name != "5Б" {
+		return fmt.Errorf("createClass(5Б) = %+v, %v", b, err)
+	}
+	if got, ok := st.getClass("5 б"); !ok || got.Name != "5Б" {
+		return fmt.Errorf("getClass(5 б) = %+v, %v", got, ok)
+	}
+	if _, ok := st.getClass("5"); ok {
+		return fmt.Errorf("class 5Б found without its letter")
+	}
+	if grade, letter := parseClassName(b.Name); grade != 5 || letter != "Б" {
+		return fmt.Errorf("parseClassName(5Б) = %d, %q", grade, letter)
+	}
+	classes := st.listClasses()
+	if len(classes) != 4 || classes[0].Name != "1A" || classes[1].Name != "5Б" || classes[3].Name != "10B" {
+		return fmt.Errorf("listClasses order = %+v", classes)
+	}
+
+	if _, err := st.createUser(User{FullName: "S", Email: "s@school.local", Role: RoleStudent, ClassName: "7Z"}); !errors.Is(err, errUnknownClass) {
+		return fmt.Errorf("createUser with unknown class: %v", err)
+	}
+	if _, err := st.addSchedule(ScheduleEntry{ClassName: "7Z", Subject: "X"}); !errors.Is(err, errUnknownClass) {
+		return fmt.Errorf("addSchedule with unknown class: %v", err)
+	}
+	if _, err := st.addHomework(Homework{ClassName: "7Z", Subject: "X"}); !errors.Is(err, errUnknownClass) {
+		return fmt.Errorf("addHomework with unknown class: %v", err)
+	}
+	if _, err := st.setSchedulePhoto("7Z", "", []byte{1}); !errors.Is(err, errUnknownClass) {
+		return fmt.Errorf("setSchedulePhoto with unknown class: %v", err)
+	}
+
+	if _, err := st.createUser(User{FullName: "S", Email: "s@school.local", Role: RoleStudent, ClassName: "7 a"}); err != nil {
+		return err
+	}
+	if _, err := st.deleteClass("7A"); !errors.Is(err, errClassInUse) {
+		return fmt.Errorf("deleteClass of class in use: %v", err)
+	}
+	c.AcademicYear = "2027/2028"
+	c.HomeroomTeacherID = 0
+	if updated, err := st.updateClass(c); err != nil || updated.AcademicYear != "2027/2028" || updated.HomeroomTeacherID != 0 {
+		return fmt.Errorf("updateClass = %+v, %v", updated, err)
+	}
+	if deleted, err := st.deleteClass("1a"); err != nil || !deleted {
+		return fmt.Errorf("deleteClass = %v, %v", deleted, err)
+	}
+	if _, ok := st.getClass("1A"); ok {
+		return fmt.Errorf("class still present after delete")
+	}
+	return nil
+}
+
+func checkUsers(st *Storage) error {
+	if err := addClasses(st, "5A"); err != nil {
+		return err
+	}
+	a, err := st.createUser(User{FullName: "A", Email: "a@school.local", PasswordHash: "h", Role: RoleTeacher})
+	if err != nil {
+		return err
+	}
+	b, err := st.createUser(User{FullName: "B", Email: "b@school.local", PasswordHash: "h", Role: RoleStudent, ClassName: "5A"})
+	if err != nil {
+		return err
+	}
+	if a.ID <= 0 || b.ID <= a.ID {
+		return fmt.Errorf("ids must be positive and increasing, got %d, %d", a.ID, b.ID)
+	}
+	if _, err := st.createUser(User{FullName: "A2", Email: " A@School.Local", Role: RoleTeacher}); err == nil {
+		return fmt.Errorf("duplicate email (case-insensitive) accepted")
+	}
+	if _, err := st.createUser(User{FullName: "C", Email: "  ", Role: RoleTeacher}); err == nil {
+		return fmt.Errorf("empty email accepted")
+	}
+	got, ok := st.getUser(b.ID)
+	if !ok || got.Email != b.Email || got.PasswordHash != "h" {
+		return fmt.Errorf("getUser returned %+v, %v", got, ok)
+	}
+	if n := len(st.listUsers()); n != 3 {
+		return fmt.Errorf("listUsers returned %d users, want 3", n)
+	}
+
+	deleted, err := st.deleteUser(a.ID)
+	if err != nil || !deleted {
+		return fmt.Errorf("deleteUser = %v, %v", deleted, err)
+	}
+	if deleted, _ := st.deleteUser(a.ID); deleted {
+		return fmt.Errorf("deleteUser reported success twice")
+	}
+	if _, ok := st.findUserByEmail(a.Email); ok {
+		return fmt.Errorf("deleted user still found by email")
+	}
+	c, err := st.createUser(User{FullName: "A", Email: a.Email, Role: RoleTeacher})
+	if err != nil {
+		return fmt.Errorf("email of deleted user cannot be reused: %w", err)
+	}
+	if c.ID <= b.ID {
+		return fmt.Errorf("id %d reused after delete", c.ID)
+	}
+	return nil
+}
+
+func checkPasswordHash(st *Storage) error {
+	u, err := st.createUser(User{FullName: "P", Email: "p@school.local", PasswordHash: "old", Role: RoleTeacher})
+	if err != nil {
+		return err
+	}
+	if err := st.setPasswordHash(u.ID, "new"); err != nil {
+		return err
+	}
+	got, ok := st.findUserByEmail(u.Email)
+	if !ok || got.PasswordHash != "new" || got.FullName != u.FullName {
+		return fmt.Errorf("after setPasswordHash got %+v", got)
+	}
+	if err := st.setPasswordHash(9999, "x"); err == nil {
+		return fmt.Errorf("setPasswordHash accepted unknown user")
+	}
+	return nil
+}
+
+func checkCreateUsers(st *Storage) error {
+	if err := addClasses(st, "5A"); err != nil {
+		return err
+	}
+	before := len(st.listUsers())
+	if _, err := st.createUsers([]User{
+		{FullName: "A", Email: "a@school.local", Role: RoleStudent, ClassName: "5A"},
+		{FullName: "B", Email: " A@school.local", Role: RoleStudent, ClassName: "5A"},
+	}); err == nil {
+		return fmt.Errorf("createUsers accepted duplicate emails in one batch")
+	}
+	if _, err := st.createUsers([]User{
+		{FullName: "C", Email: "c@school.local", Role: RoleStudent, ClassName: "5A"},
+		{FullName: "Admin", Email: "admin@school.local", Role: RoleAdmin},
+	}); err == nil {
+		return fmt.Errorf("createUsers accepted an existing email")
+	}
+	if n := len(st.listUsers()); n != before {
+		return fmt.Errorf("failed batch left %d users, want %d", n, before)
+	}
+	users, err := st.createUsers([]User{
+		{FullName: "D", Email: "d@school.local", PasswordHash: "hd", Role: RoleStudent, ClassName: "5A"},
+		{FullName: "E", Email: "e@school.local", PasswordHash: "he", Role: RoleTeacher},
+	})
+	if err != nil {
+		return err
+	}
+	if len(users) != 2 || users[0].ID <= 0 || users[1].ID != users[0].ID+1 {
+		return fmt.Errorf("createUsers ids = %+v", users)
+	}
+	if u, ok := st.findUserByEmail("e@school.local"); !ok || u.ID != users[1].ID || u.PasswordHash != "he" {
+		return fmt.Errorf("batch user not found: %+v", u)
+	}
+	next, err := st.createUser(User{FullName: "F", Email: "f@school.local", Role: RoleTeacher})
+	if err != nil {
+		return err
+	}
+	if next.ID <= users[1].ID {
+		return fmt.Errorf("id counter not advanced by batch: %d", next.ID)
+	}
+	return nil
+}
+
+func checkUpdateUser(st *Storage) error {
+	if err := addClasses(st, "5A", "6A"); err != nil {
+		return err
+	}
+	u, err := st.createUser(User{FullName: "Petrov", Email: "up@school.local", PasswordHash: "h", Role: RoleStudent, ClassName: "5A"})
+	if err != nil {
+		return err
+	}
+	other, err := st.createUser(User{FullName: "O", Email: "other@school.local", Role: RoleTeacher})
+	if err != nil {
+		return err
+	}
+	dupEmail := " OTHER@school.local"
+	if _, _, err := st.updateUser(u.ID, userPatch{Email: &dupEmail}, 1); err == nil {
+		return fmt.Errorf("updateUser allowed duplicate email of user %d", other.ID)
+	}
+	name, email, className := "Petrova", "new@school.local", "6a"
+	got, changes, err := st.updateUser(u.ID, userPatch{FullName: &name, Email: &email, ClassName: &className}, 1)
+	if err != nil {
+		return err
+	}
+	if len(changes) != 3 || got.PasswordHash != "h" || got.ClassName != "6A" {
+		return fmt.Errorf("updateUser = %+v, changes %+v", got, changes)
+	}
+	if _, ok := st.findUserByEmail(u.Email); ok {
+		return fmt.Errorf("old email still indexed")
+	}
+	if found, ok := st.findUserByEmail("new@school.local"); !ok || found.ID != u.ID || found.ClassName != "6A" {
+		return fmt.Errorf("new email not indexed: %+v", found)
+	}
+	if _, changes, err := st.updateUser(u.ID, userPatch{FullName: &name, ClassName: &className}, 1); err != nil || len(changes) != 0 {
+		return fmt.Errorf("no-op update = %+v, %v", changes, err)
+	}
+	audit := st.listAudit("user", u.ID)
+	if len(audit) != 1 || audit[0].ActorID != 1 || len(audit[0].Changes) != 3 {
+		return fmt.Errorf("audit = %+v", audit)
+	}
+	if len(st.listAudit("user", other.ID)) != 0 {
+		return fmt.Errorf("audit filter by entity id ignored")
+	}
+	if _, _, err := st.updateUser(999, userPatch{FullName: &name}, 1); !errors.Is(err, errUserNotFound) {
+		return fmt.Errorf("updateUser accepted unknown user: %v", err)
+	}
+	empty := " "
+	if _, _, err := st.updateUser(u.ID, userPatch{FullName: &empty}, 1); err == nil {
+		return fmt.Errorf("updateUser accepted an empty name")
+	}
+
+	// смена роли сбрасывает класс и завершает сессии
+	if _, _, err := st.createSession(u.ID, sessionClient{}, sessionTTL{Access: time.Hour, Refresh: 24 * time.Hour}); err != nil {
+		return err
+	}
+	teacher := RoleTeacher
+	if _, _, err := st.updateUser(u.ID, userPatch{Role: &teacher}, u.ID); err == nil {
+		return fmt.Errorf("user changed their own role")
+	}
+	got, _, err = st.updateUser(u.ID, userPatch{Role: &teacher}, 1)
+	if err != nil || got.Role != RoleTeacher || got.ClassName != "" {
+		return fmt.Errorf("role change = %+v, %v", got, err)
+	}
+	if n := len(st.listSessionsByUser(u.ID)); n != 0 {
+		return fmt.Errorf("sessions kept after role change: %d", n)
+	}
+	student := RoleStudent
+	if _, _, err := st.updateUser(u.ID, userPatch{Role: &student}, 1); err == nil {
+		return fmt.Errorf("student without a class accepted")
+	}
+	if got, _, err = st.updateUser(u.ID, userPatch{Role: &student, ClassName: &className}, 1); err != nil || got.ClassName != "6A" {
+		return fmt.Errorf("role change with a class = %+v, %v", got, err)
+	}
+	return nil
+}
+
+func checkStudentsSorted(st *Storage) error {
+	if err := addClasses(st, "5A", "5B"); err != nil {
+		return err
+	}
+	for _, u := range []User{
+		{FullName: "Yakov", Email: "y@school.local", Role: RoleStudent, ClassName: "5B"},
+		{FullName: "boris", Email: "bo@school.local", Role: RoleStudent, ClassName: "5A"},
+		{FullName: "Anna", Email: "an@school.local", Role: RoleStudent, ClassName: "5A"},
+		{FullName: "Teacher", Email: "t@school.local", Role: RoleTeacher},
+	} {
+		if _, err := st.createUser(u); err != nil {
+			return err
+		}
+	}
+	var names []string
+	for _, u := range st.listStudentsSortedByClass() {
+		names = append(names, u.FullName)
+	}
+	if fmt.Sprint(names) != "[Anna boris Yakov]" {
+		return fmt.Errorf("got order %v", names)
+	}
+	return nil
+}
+
+func checkSessions(st *Storage) error {
+	ttl := sessionTTL{Access: time.Hour, Refresh: 24 * time.Hour}
+	client := sessionClient{UserAgent: "conformance", IP: "192.0.2.1"}
+	u, err := st.createUser(User{FullName: "T", Email: "t@school.local", Role: RoleTeacher})
+	if err != nil {
+		return err
+	}
+	s1, t1, err := st.createSession(u.ID, client, ttl)
+	if err != nil {
+		return err
+	}
+	_, t2, err := st.createSession(u.ID, client, ttl)
+	if err != nil {
+		return err
+	}
+	if t1.Token == "" || t1.RefreshToken == "" || t1.Token == t2.Token || t1.Token == t1.RefreshToken {
+		return fmt.Errorf("tokens must be unique and non-empty")
+	}
+	if _, _, err := st.createSession(9999, client, ttl); err == nil {
+		return fmt.Errorf("session created for unknown user")
+	}
+	session, got, ok := st.sessionByToken(t1.Token)
+	if !ok || got.ID != u.ID || session.ID != s1.ID {
+		return fmt.Errorf("sessionByToken returned %+v, %+v, %v", session, got, ok)
+	}
+	if session.UserAgent != client.UserAgent || session.IP != client.IP {
+		return fmt.Errorf("client info not recorded: %+v", session)
+	}
+	if !session.LastUsedAt.Equal(s1.LastUsedAt) {
+		return fmt.Errorf("lastUsedAt of a fresh session touched: %v, want %v", session.LastUsedAt, s1.LastUsedAt)
+	}
+	if n := len(st.listSessionsByUser(u.ID)); n != 2 {
+		return fmt.Errorf("listSessionsByUser returned %d sessions, want 2", n)
+	}
+	if _, _, ok := st.sessionByToken("missing"); ok {
+		return fmt.Errorf("unknown token accepted")
+	}
+	if _, _, ok := st.sessionByToken(t1.RefreshToken); ok {
+		return fmt.Errorf("refresh token accepted as access token")
+	}
+
+	refreshed, t3, _, err := st.refreshSession(t1.RefreshToken, client, ttl)
+	if err != nil {
+		return err
+	}
+	if refreshed.ID != s1.ID {
+		return fmt.Errorf("refresh changed session id")
+	}
+	if !refreshed.RefreshExpiresAt.Equal(s1.RefreshExpiresAt) || !t3.RefreshExpiresAt.Equal(s1.RefreshExpiresAt) {
+		return fmt.Errorf("refresh moved the refresh deadline: %s, want %s", refreshed.RefreshExpiresAt, s1.RefreshExpiresAt)
+	}
+	short, shortTokens, err := st.createSession(u.ID, client, sessionTTL{Access: time.Hour, Refresh: time.Minute})
+	if err != nil {
+		return err
+	}
+	if shortTokens.ExpiresAt.After(shortTokens.RefreshExpiresAt) {
+		return fmt.Errorf("access token outlives the session: %s after %s", shortTokens.ExpiresAt, shortTokens.RefreshExpiresAt)
+	}
+	if _, err := st.revokeSession(short.ID); err != nil {
+		return err
+	}
+	if _, _, ok := st.sessionByToken(t1.Token); ok {
+		return fmt.Errorf("old access token valid after refresh")
+	}
+	if _, _, _, err := st.refreshSession(t1.RefreshToken, client, ttl); !errors.Is(err, errSessionNotFound) {
+		return fmt.Errorf("old refresh token reused: %v", err)
+	}
+	if _, _, ok := st.sessionByToken(t3.Token); !ok {
+		return fmt.Errorf("refreshed access token rejected")
+	}
+
+	if revoked, err := st.revokeSession(s1.ID); err != nil || !revoked {
+		return fmt.Errorf("revokeSession = %v, %v", revoked, err)
+	}
+	if _, _, ok := st.sessionByToken(t3.Token); ok {
+		return fmt.Errorf("revoked session still valid")
+	}
+
+	expired, t4, err := st.createSession(u.ID, client, sessionTTL{Access: -time.Second, Refresh: -time.Second})
+	if err != nil {
+		return err
+	}
+	if _, _, ok := st.sessionByToken(t4.Token); ok {
+		return fmt.Errorf("expired access token accepted")
+	}
+	if _, _, _, err := st.refreshSession(t4.RefreshToken, client, ttl); !errors.Is(err, errSessionNotFound) {
+		return fmt.Errorf("expired refresh token accepted: %v", err)
+	}
+	if n, err := st.pruneSessions(); err != nil || n != 1 {
+		return fmt.Errorf("pruneSessions = %d, %v", n, err)
+	}
+	if revoked, _ := st.revokeSession(expired.ID); revoked {
+		return fmt.Errorf("pruned session still present")
+	}
+
+	if n, err := st.revokeUserSessions(u.ID); err != nil || n != 1 {
+		return fmt.Errorf("revokeUserSessions = %d, %v", n, err)
+	}
+	if _, _, ok := st.sessionByToken(t2.Token); ok {
+		return fmt.Errorf("session valid after sign out everywhere")
+	}
+
+	_, t5, err := st.createSession(u.ID, client, ttl)
+	if err != nil {
+		return err
+	}
+	if _, err := st.deleteUser(u.ID); err != nil {
+		return err
+	}
+	if _, _, ok := st.sessionByToken(t5.Token); ok {
+		return fmt.Errorf("session of deleted user still valid")
+	}
+	if n := len(st.listSessionsByUser(u.ID)); n != 0 {
+		return fmt.Errorf("deleted user still has %d sessions", n)
+	}
+	return nil
+}
+
+func checkPasswordChange(st *Storage) error {
+	if err := addClasses(st, "8A"); err != nil {
+		return err
+	}
+	u, err := st.createUser(User{FullName: "P", Email: "pw@school.local", PasswordHash: "old", Role: RoleStudent, ClassName: "8A"})
+	if err != nil {
+		return err
+	}
+	ttl := sessionTTL{Access: time.Hour, Refresh: time.Hour}
+	keep, keepTokens, err := st.createSession(u.ID, sessionClient{}, ttl)
+	if err != nil {
+		return err
+	}
+	_, otherTokens, err := st.createSession(u.ID, sessionClient{}, ttl)
+	if err != nil {
+		return err
+	}
+	n, err := st.changePassword(u.ID, "new", keep.ID)
+	if err != nil {
+		return err
+	}
+	if n != 1 {
+		return fmt.Errorf("changePassword revoked %d sessions, want 1", n)
+	}
+	if _, _, ok := st.sessionByToken(otherTokens.Token); ok {
+		return fmt.Errorf("other session still valid after password change")
+	}
+	if _, got, ok := st.sessionByToken(keepTokens.Token); !ok || got.PasswordHash != "new" {
+		return fmt.Errorf("current session lost or hash not updated: %v %q", ok, got.PasswordHash)
+	}
+
+	now := time.Now().UTC()
+	if _, _, err := st.createPasswordReset(PasswordReset{UserID: 999, CreatedAt: now, ExpiresAt: now.Add(time.Hour)}); err == nil {
+		return fmt.Errorf("reset created for unknown user")
+	}
+	_, oldCode, err := st.createPasswordReset(PasswordReset{UserID: u.ID, CreatedBy: 1, CreatedAt: now, ExpiresAt: now.Add(time.Hour)})
+	if err != nil {
+		return err
+	}
+	_, code, err := st.createPasswordReset(PasswordReset{UserID: u.ID, CreatedBy: 1, CreatedAt: now, ExpiresAt: now.Add(time.Hour)})
+	if err != nil {
+		return err
+	}
+	if _, err := st.redeemPasswordReset(u.Email, oldCode, "x"); !errors.Is(err, errResetInvalid) {
+		return fmt.Errorf("superseded reset code accepted: %v", err)
+	}
+	if _, err := st.redeemPasswordReset("other@school.local", code, "x"); !errors.Is(err, errResetInvalid) {
+		return fmt.Errorf("reset code accepted for another email: %v", err)
+	}
+	got, err := st.redeemPasswordReset(" PW@school.local", strings.ToLower(code), "reset")
+	if err != nil {
+		return err
+	}
+	if got.PasswordHash != "reset" {
+		return fmt.Errorf("reset did not update hash: %q", got.PasswordHash)
+	}
+	if _, _, ok := st.sessionByToken(keepTokens.Token); ok {
+		return fmt.Errorf("session still valid after password reset")
+	}
+	if _, err := st.redeemPasswordReset(u.Email, code, "again"); !errors.Is(err, errResetInvalid) {
+		return fmt.Errorf("reset code redeemed twice: %v", err)
+	}
+
+	_, expiredCode, err := st.createPasswordReset(PasswordReset{UserID: u.ID, CreatedAt: now, ExpiresAt: now.Add(-time.Minute)})
+	if err != nil {
+		return err
+	}
+	if _, err := st.redeemPasswordReset(u.Email, expiredCode, "x"); !errors.Is(err, errResetInvalid) {
+		return fmt.Errorf("expired reset code accepted: %v", err)
+	}
+	return nil
+}
+
+func checkInvites(st *Storage) error {
+	if err := addClasses(st, "7A"); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	inv, code, err := st.createInvite(Invite{Role: RoleStudent, ClassName: "7 а", CreatedBy: 1, CreatedAt: now, ExpiresAt: now.Add(time.Hour)})
+	if err != nil {
+		return err
+	}
+	if code == "" || inv.ID <= 0 || inv.ClassName != "7A" {
+		return fmt.Errorf("createInvite returned %+v, %q", inv, code)
+	}
+	u, err := st.redeemInvite(" "+strings.ToLower(code)+" ", User{FullName: "S", Email: "s@school.local", Role: RoleAdmin})
+	if err != nil {
+		return err
+	}
+	if u.Role != RoleStudent || u.ClassName != "7A" {
+		return fmt.Errorf("invite role/class not applied: %+v", u)
+	}
+	if _, err := st.redeemInvite(code, User{FullName: "S2", Email: "s2@school.local"}); !errors.Is(err, errInviteInvalid) {
+		return fmt.Errorf("invite redeemed twice: %v", err)
+	}
+	invites := st.listInvites()
+	if len(invites) != 1 || invites[0].UsedBy != u.ID || invites[0].UsedAt == nil {
+		return fmt.Errorf("invite not marked used: %+v", invites)
+	}
+
+	if _, _, err := st.createInvite(Invite{Role: RoleTeacher, CreatedAt: now, ExpiresAt: now.Add(time.Hour)}); !errors.Is(err, errInviteRole) {
+		return fmt.Errorf("teacher invite created: %v", err)
+	}
+	expired, expiredCode, err := st.createInvite(Invite{Role: RoleStudent, ClassName: "7A", CreatedAt: now, ExpiresAt: now.Add(-time.Minute)})
+	if err != nil {
+		return err
+	}
+	if _, err := st.redeemInvite(expiredCode, User{FullName: "T", Email: "t@school.local"}); !errors.Is(err, errInviteInvalid) {
+		return fmt.Errorf("expired invite accepted: %v", err)
+	}
+	_, dupCode, err := st.createInvite(Invite{Role: RoleStudent, ClassName: "7A", CreatedAt: now, ExpiresAt: now.Add(time.Hour)})
+	if err != nil {
+		return err
+	}
+	if _, err := st.redeemInvite(dupCode, User{FullName: "Dup", Email: u.Email}); err == nil {
+		return fmt.Errorf("invite created user with duplicate email")
+	}
+	if _, err := st.redeemInvite(dupCode, User{FullName: "T", Email: "t@school.local"}); err != nil {
+		return fmt.Errorf("invite unusable after failed attempt: %w", err)
+	}
+	if deleted, err := st.deleteInvite(expired.ID); err != nil || !deleted {
+		return fmt.Errorf("deleteInvite = %v, %v", deleted, err)
+	}
+	if n := len(st.listInvites()); n != 2 {
+		return fmt.Errorf("listInvites returned %d after delete, want 2", n)
+	}
+	return nil
+}
+
+func checkSubjects(st *Storage) error {
+	if err := addClasses(st, "7A"); err != nil {
+		return err
+	}
+	math, err := st.createSubject(Subject{Name: " Математика ", ShortName: "Мат", Aliases: []string{"математика", "Алгебра", " алгебра", ""}})
+	if err != nil {
+		return err
+	}
+	if math.ID == 0 || math.Name != "Математика" || strings.Join(math.Aliases, ",") != "Алгебра" {
+		return fmt.Errorf("subject not normalized: %+v", math)
+	}
+	if _, err := st.createSubject(Subject{Name: "алгебра"}); err == nil {
+		return fmt.Errorf("subject accepted with a name used as an alias")
+	}
+	physics, err := st.createSubject(Subject{Name: "Физика"})
+	if err != nil {
+		return err
+	}
+	for _, name := range []string{"мат", "АЛГЕБРА", "  математика  "} {
+		if got, ok := st.resolveSubject(name); !ok || got.ID != math.ID {
+			return fmt.Errorf("resolveSubject(%q) = %+v, %v", name, got, ok)
+		}
+	}
+	if _, err := st.addGrade(Grade{StudentID: 10, Subject: "Биология", Value: 5, TeacherID: 1, Date: "2026-02-01"}); !errors.Is(err, errUnknownSubject) {
+		return fmt.Errorf("grade with unknown subject: err = %v", err)
+	}
+	g, err := st.addGrade(Grade{StudentID: 10, Subject: "алгебра", Value: 5, TeacherID: 1, Date: "2026-02-01"})
+	if err != nil {
+		return err
+	}
+	if g.SubjectID != math.ID || g.Subject != "Математика" {
+		return fmt.Errorf("grade subject not resolved: %+v", g)
+	}
+	hw, err := st.addHomework(Homework{ClassName: "7A", Subject: "мат", Description: "1"})
+	if err != nil {
+		return err
+	}
+	if hw.SubjectID != math.ID {
+		return fmt.Errorf("homework subject not resolved: %+v", hw)
+	}
+
+	math.Name = "Математика и алгебра"
+	if _, err := st.updateSubject(math); err != nil {
+		return err
+	}
+	if grades := st.listGradesByStudent(10); len(grades) != 1 || grades[0].Subject != "Математика и алгебра" {
+		return fmt.Errorf("rename not applied to grades: %+v", grades)
+	}
+	if _, err := st.updateSubject(Subject{ID: physics.ID, Name: "Физика", Aliases: []string{"Мат"}}); err == nil {
+		return fmt.Errorf("update accepted an alias of another subject")
+	}
+	if deleted, err := st.deleteSubject(math.ID); !errors.Is(err, errSubjectInUse) || deleted {
+		return fmt.Errorf("deleteSubject of used subject = %v, %v", deleted, err)
+	}
+	if deleted, err := st.deleteSubject(physics.ID); err != nil || !deleted {
+		return fmt.Errorf("deleteSubject = %v, %v", deleted, err)
+	}
+	if n := len(st.listSubjects()); n != 1 {
+		return fmt.Errorf("listSubjects returned %d, want 1", n)
+	}
+
+	// Все записи уже ссылаются на справочник, мигрировать нечего.
+	report, err := st.applySubjectMigration(subjectMigrationParams{CreateMissing: true})
+	if err != nil {
+		return err
+	}
+	if len(report.Mappings) != 0 || len(report.Created) != 0 {
+		return fmt.Errorf("migration of catalog records = %+v", report)
+	}
+	if _, err := st.previewSubjectMigration(subjectMigrationParams{Mapping: map[string]int64{"x": 999}}); err == nil {
+		return fmt.Errorf("migration accepted mapping to a missing subject")
+	}
+	return nil
+}
+
+func checkAssignments(st *Storage) error {
+	if err := addClasses(st, "7A", "8A"); err != nil {
+		return err
+	}
+	if err := addSubjects(st, "Математика", "Алгебра"); err != nil {
+		return err
+	}
+	teacher, err := st.createUser(User{FullName: "T", Email: "t@school.local", Role: RoleTeacher})
+	if err != nil {
+		return err
+	}
+	student, err := st.createUser(User{FullName: "S", Email: "s@school.local", Role: RoleStudent, ClassName: "7A"})
+	if err != nil {
+		return err
+	}
+	if subjects := st.teacherSubjects(teacher.ID); len(subjects) != 0 {
+		return fmt.Errorf("subjects before assignment = %v", subjects)
+	}
+	if _, err := st.createAssignment(Assignment{TeacherID: student.ID, Subject: "Математика", ClassName: "7A"}); err == nil {
+		return fmt.Errorf("assignment accepted for a student")
+	}
+	if _, err := st.createAssignment(Assignment{TeacherID: teacher.ID, Subject: "Математика", ClassName: "7Z"}); !errors.Is(err, errUnknownClass) {
+		return fmt.Errorf("assignment to unknown class: err = %v", err)
+	}
+	math, err := st.createAssignment(Assignment{TeacherID: teacher.ID, Subject: " Математика ", ClassName: "7 а"})
+	if err != nil {
+		return err
+	}
+	if math.ID == 0 || math.Subject != "Математика" || math.ClassName != "7A" {
+		return fmt.Errorf("assignment not normalized: %+v", math)
+	}
+	if _, err := st.createAssignment(Assignment{TeacherID: teacher.ID, Subject: "математика", ClassName: "7A"}); !errors.Is(err, errAssignmentExists) {
+		return fmt.Errorf("duplicate assignment: err = %v", err)
+	}
+	for _, a := range []Assignment{
+		{TeacherID: teacher.ID, Subject: "Математика", ClassName: "7A", Group: "2"},
+		{TeacherID: teacher.ID, Subject: "Алгебра", ClassName: "8A"},
+	} {
+		if _, err := st.createAssignment(a); err != nil {
+			return err
+		}
+	}
+	if subjects := st.teacherSubjects(teacher.ID); strings.Join(subjects, ",") != "Алгебра,Математика" {
+		return fmt.Errorf("teacherSubjects = %v", subjects)
+	}
+	if n := len(st.listAssignments(0, "7a")); n != 2 {
+		return fmt.Errorf("listAssignments by class returned %d, want 2", n)
+	}
+	if _, err := st.createClass(Class{Grade: 9, Letter: "A", AcademicYear: "2026/2027"}); err != nil {
+		return err
+	}
+	if _, err := st.addSchedule(ScheduleEntry{ClassName: "9A", Subject: "Алгебра", Weekday: "monday", TeacherID: teacher.ID}); !errors.Is(err, errNoAssignment) {
+		return fmt.Errorf("lesson without assignment: err = %v", err)
+	}
+	if _, err := st.replaceSchedule([]ScheduleEntry{{ClassName: "7A", Subject: "Алгебра", TeacherID: teacher.ID}}); !errors.Is(err, errNoAssignment) {
+		return fmt.Errorf("replaceSchedule with lesson without assignment: err = %v", err)
+	}
+	if _, err := st.addSchedule(ScheduleEntry{ClassName: "8A", Subject: "алгебра", Weekday: "monday", TeacherID: teacher.ID}); err != nil {
+		return err
+	}
+	if deleted, err := st.deleteClass("8A"); !errors.Is(err, errClassInUse) || deleted {
+		return fmt.Errorf("deleteClass of assigned class = %v, %v", deleted, err)
+	}
+	if deleted, err := st.deleteAssignment(math.ID); err != nil || !deleted {
+		return fmt.Errorf("deleteAssignment = %v, %v", deleted, err)
+	}
+	if n := len(st.listAssignments(teacher.ID, "")); n != 2 {
+		return fmt.Errorf("listAssignments after delete returned %d, want 2", n)
+	}
+	admin := RoleAdmin
+	if _, _, err := st.updateUser(teacher.ID, userPatch{Role: &admin}, 1); err != nil {
+		return err
+	}
+	if n := len(st.listAssignments(teacher.ID, "")); n != 0 {
+		return fmt.Errorf("assignments kept after role change: %d", n)
+	}
+	return nil
+}
+
+func checkSchedule(st *Storage) error {
+	if err := addClasses(st, "7A", "8B"); err != nil {
+		return err
+	}
+	if err := addSubjects(st, "Физика"); err != nil {
+		return err
+	}
+	var teachers []int64
+	for _, email := range []string{"t1@school.local", "t2@school.local"} {
+		u, err := st.createUser(User{FullName: "T", Email: email, Role: RoleTeacher})
+		if err != nil {
+			return err
+		}
+		for _, className := range []string{"7A", "8B"} {
+			if _, err := st.createAssignment(Assignment{TeacherID: u.ID, Subject: "Физика", ClassName: className}); err != nil {
+				return err
+			}
+		}
+		teachers = append(teachers, u.ID)
+	}
+	e, err := st.addSchedule(ScheduleEntry{ClassName: "7 а", Subject: "Физика", Weekday: "monday", StartTime: "09:00", EndTime: "09:45"})
+	if err != nil {
+		return err
+	}
+	if e.ID <= 0 || e.ClassName != "7A" {
+		return fmt.Errorf("addSchedule returned %+v", e)
+	}
+	if n := len(st.listScheduleByClass("7a")); n != 1 {
+		return fmt.Errorf("listScheduleByClass returned %d entries", n)
+	}
+	for _, entry := range []ScheduleEntry{
+		{ClassName: "8B", Subject: "Физика", Weekday: "Вт", StartTime: "10:00", TeacherID: teachers[0]},
+		{ClassName: "7A", Subject: "Физика", Weekday: "понедельник", StartTime: "8:30", TeacherID: teachers[0]},
+		{ClassName: "7A", Subject: "Физика", Weekday: "someday", StartTime: "08:00", TeacherID: teachers[0]},
+		{ClassName: "8B", Subject: "Физика", Weekday: "tuesday", StartTime: "09:00", TeacherID: teachers[1]},
+	} {
+		if _, err := st.addSchedule(entry); err != nil {
+			return err
+		}
+	}
+	var order []string
+	for _, entry := range st.listScheduleByTeacher(teachers[0]) {
+		order = append(order, entry.Weekday+" "+entry.StartTime)
+	}
+	if strings.Join(order, ", ") != "понедельник 8:30, Вт 10:00, someday 08:00" {
+		return fmt.Errorf("listScheduleByTeacher order = %v", order)
+	}
+	if list := st.listScheduleByClass("7A"); len(list) != 3 || list[0].StartTime != "8:30" || list[1].ID != e.ID {
+		return fmt.Errorf("listScheduleByClass order = %+v", list)
+	}
+	n, err := st.replaceSchedule([]ScheduleEntry{{ClassName: "8b", Subject: "физика"}, {ClassName: "8b", Subject: "Физика"}})
+	if err != nil {
+		return err
+	}
+	if n != 2 || len(st.listAllSchedule()) != 2 || len(st.listScheduleByClass("7A")) != 0 {
+		return fmt.Errorf("replaceSchedule did not replace entries")
+	}
+	if err := st.clearSchedule(); err != nil {
+		return err
+	}
+	if len(st.listAllSchedule()) != 0 {
+		return fmt.Errorf("clearSchedule left entries")
+	}
+	return nil
+}
+
+func checkSchedulePhotos(st *Storage) error {
+	if err := addClasses(st, "5B"); err != nil {
+		return err
+	}
+	photo, err := st.setSchedulePhoto("5 в", "", []byte{1, 2, 3})
+	if err != nil {
+		return err
+	}
+	if photo.ClassName != "5B" || photo.ContentType != "image/jpeg" || photo.ImageData != "data:image/jpeg;base64,AQID" {
+		return fmt.Errorf("setSchedulePhoto returned %+v", photo)
+	}
+	if _, ok := st.getSchedulePhotoByClass("5b"); !ok {
+		return fmt.Errorf("photo not found by normalized class")
+	}
+	if stats := st.schedulePhotoStats(); len(stats) != 1 || stats["5B"] != 1 {
+		return fmt.Errorf("schedulePhotoStats = %v", stats)
+	}
+	if err := st.clearSchedule(); err != nil {
+		return err
+	}
+	if _, ok := st.getSchedulePhotoByClass("5B"); ok {
+		return fmt.Errorf("clearSchedule left photos")
+	}
+	return nil
+}
+
+func checkGrades(st *Storage) error {
+	if err := addSubjects(st, "Математика", "Физика"); err != nil {
+		return err
+	}
+	for _, g := range []Grade{
+		{StudentID: 10, Subject: "Математика", Value: 5, TeacherID: 1, Date: "2026-02-01"},
+		{StudentID: 10, Subject: "Математика", Value: 4, TeacherID: 1, Date: "2026-02-10"},
+		{StudentID: 11, Subject: "Физика", Value: 3, TeacherID: 1, Date: "2026-02-05"},
+		{StudentID: 11, Subject: "Математика", Value: 2, TeacherID: 2, Date: "2026-02-05"},
+	} {
+		saved, err := st.addGrade(g)
+		if err != nil {
+			return err
+		}
+		if saved.ID <= 0 {
+			return fmt.Errorf("addGrade returned id %d", saved.ID)
+		}
+	}
+	if n := len(st.listGradesByStudent(10)); n != 2 {
+		return fmt.Errorf("listGradesByStudent returned %d grades", n)
+	}
+	rows := st.listGradesByTeacherSubjectDateRange(1, " Математика ", "2026-02-02", "2026-02-28")
+	if len(rows) != 1 || rows[0].Value != 4 {
+		return fmt.Errorf("date range filter returned %+v", rows)
+	}
+	if n := len(st.listGradesByTeacherSubjectDateRange(1, "Математика", "", "")); n != 2 {
+		return fmt.Errorf("open range returned %d grades", n)
+	}
+
+	g := rows[0]
+	g.Value, g.Comment = 3, "пересдача"
+	updated, rev, err := st.updateGrade(g, 1, "ошибка ввода")
+	if err != nil {
+		return err
+	}
+	if updated.Value != 3 || updated.Subject != "Математика" || rev == nil || len(rev.Changes) != 2 {
+		return fmt.Errorf("updateGrade returned %+v, %+v", updated, rev)
+	}
+	if _, rev, err := st.updateGrade(updated, 1, "без изменений"); err != nil || rev != nil {
+		return fmt.Errorf("no-op update created revision %+v (%v)", rev, err)
+	}
+	if got, _ := st.getGrade(g.ID); got.Value != 3 {
+		return fmt.Errorf("grade after update = %+v", got)
+	}
+	del, err := st.deleteGrade(g.ID, 1, "дубль")
+	if err != nil {
+		return err
+	}
+	if del.Action != "delete" || del.GradeID != g.ID || del.StudentID != 10 {
+		return fmt.Errorf("deleteGrade returned %+v", del)
+	}
+	if _, ok := st.getGrade(g.ID); ok {
+		return fmt.Errorf("deleted grade is still present")
+	}
+	if _, err := st.deleteGrade(g.ID, 1, "дубль"); !errors.Is(err, errGradeNotFound) {
+		return fmt.Errorf("second delete: %v", err)
+	}
+	revs := st.listGradeRevisions(g.ID, 0)
+	if len(revs) != 2 || revs[0].Action != "update" || revs[0].Reason != "ошибка ввода" || revs[1].Action != "delete" {
+		return fmt.Errorf("listGradeRevisions returned %+v", revs)
+	}
+	if n := len(st.listGradeRevisions(0, 11)); n != 0 {
+		return fmt.Errorf("revisions leaked to another student: %d", n)
+	}
+
+	// пакет сохраняется целиком или не сохраняется совсем
+	_, err = st.addGrades([]Grade{
+		{StudentID: 12, Subject: "Физика", Value: 5, Type: "test", Date: "2026-03-01"},
+		{StudentID: 13, Subject: "Физика", Value: 7, Type: "test", Date: "2026-03-01"},
+		{StudentID: 14, Subject: "Физика", Value: 4, Type: "exam", Date: "2026-03-01"},
+	})
+	var batchErr *batchError
+	if !errors.As(err, &batchErr) || len(batchErr.Rows) != 2 || batchErr.Rows[0].Row != 1 || batchErr.Rows[1].StudentID != 14 {
+		return fmt.Errorf("invalid batch: %v", err)
+	}
+	if n := len(st.listGradesByStudent(12)); n != 0 {
+		return fmt.Errorf("rejected batch saved %d grades", n)
+	}
+	batch, err := st.addGrades([]Grade{
+		{StudentID: 12, Subject: "физика", Value: 5, Type: "test", Date: "2026-03-01"},
+		{StudentID: 13, Subject: "Физика", Value: 4, Type: "test", Date: "2026-03-01"},
+	})
+	if err != nil {
+		return err
+	}
+	if len(batch) != 2 || batch[0].ID == batch[1].ID || batch[0].Subject != "Физика" || batch[1].ScaleID == 0 {
+		return fmt.Errorf("addGrades returned %+v", batch)
+	}
+	next, err := st.addGrade(Grade{StudentID: 13, Subject: "Физика", Value: 3, Date: "2026-03-02"})
+	if err != nil {
+		return err
+	}
+	if next.ID <= batch[1].ID {
+		return fmt.Errorf("grade id %d reused after batch", next.ID)
+	}
+	return nil
+}
+
+func checkGradeTypes(st *Storage) error {
+	if err := addSubjects(st, "Математика", "Физика"); err != nil {
+		return err
+	}
+	types := st.listGradeTypes()
+	if len(types) != len(gradeTypeCatalog) || types[0].Code != "test" {
+		return fmt.Errorf("listGradeTypes returned %+v", types)
+	}
+	if _, err := st.addGrade(Grade{StudentID: 10, Subject: "Математика", Value: 5, Type: "exam"}); !errors.Is(err, errUnknownGradeType) {
+		return fmt.Errorf("unknown grade type accepted: %v", err)
+	}
+	oral, err := st.addGrade(Grade{StudentID: 10, Subject: "Математика", Value: 5, Date: "2026-02-01"})
+	if err != nil {
+		return err
+	}
+	if oral.Type != defaultGradeType {
+		return fmt.Errorf("grade without type got %q", oral.Type)
+	}
+	for _, g := range []Grade{
+		{StudentID: 10, Subject: "Математика", Value: 2, Type: " Control ", Date: "2026-02-02"},
+		{StudentID: 10, Subject: "Физика", Value: 4, Type: "lab", Date: "2026-02-02"},
+	} {
+		if _, err := st.addGrade(g); err != nil {
+			return err
+		}
+	}
+	// (5·1 + 2·3) / 4 = 2.75
+	avg := gradeAverages(st, st.listGradesByStudent(10))
+	if len(avg) != 2 || avg[0].Subject != "Математика" || avg[0].Average != 2.75 || avg[0].Count != 2 || avg[1].Average != 4 {
+		return fmt.Errorf("gradeAverages returned %+v", avg)
+	}
+	if _, err := st.setGradeTypeWeight("control", 0); err == nil {
+		return fmt.Errorf("zero weight accepted")
+	}
+	if _, err := st.setGradeTypeWeight("exam", 2); !errors.Is(err, errUnknownGradeType) {
+		return fmt.Errorf("weight of unknown type: %v", err)
+	}
+	if _, err := st.setGradeTypeWeight("CONTROL", 1); err != nil {
+		return err
+	}
+	// (5 + 2) / 2 = 3.5
+	if avg := gradeAverages(st, st.listGradesByStudent(10)); avg[0].Average != 3.5 {
+		return fmt.Errorf("average after weight change = %+v", avg[0])
+	}
+	if n := len(gradeAverages(st, nil)); n != 0 {
+		return fmt.Errorf("gradeAverages(nil) returned %d rows", n)
+	}
+	return nil
+}
+
+func checkTerms(st *Storage) error {
+	if err := addSubjects(st, "Математика", "Физика"); err != nil {
+		return err
+	}
+	q1, err := st.createTerm(Term{AcademicYear: "2026/2027", Name: "1 четверть", StartDate: "2026-09-01", EndDate: "2026-10-25"})
+	if err != nil {
+		return err
+	}
+	if _, err := st.createTerm(Term{AcademicYear: "2026/2027", Name: "2 четверть", StartDate: "2026-10-20", EndDate: "2026-12-28"}); err == nil {
+		return fmt.Errorf("overlapping term accepted")
+	}
+	if _, err := st.createTerm(Term{AcademicYear: "2026/2027", Name: "2 четверть", StartDate: "2026-12-28", EndDate: "2026-11-05"}); err == nil {
+		return fmt.Errorf("term ending before its start accepted")
+	}
+	q2, err := st.createTerm(Term{AcademicYear: "2026/2027", Name: "2 четверть", StartDate: "2026-11-05", EndDate: "2026-12-28"})
+	if err != nil {
+		return err
+	}
+	if terms := st.listTerms("2026/2027"); len(terms) != 2 || terms[0].ID != q1.ID {
+		return fmt.Errorf("listTerms returned %+v", terms)
+	}
+
+	for _, g := range []Grade{
+		{StudentID: 10, Subject: "Математика", Value: 4, Date: "2026-09-10"},
+		{StudentID: 10, Subject: "Математика", Value: 3, Date: "2026-09-20"},
+		{StudentID: 10, Subject: "Математика", Value: 3, Date: "2026-10-01"},
+		{StudentID: 10, Subject: "Математика", Value: 5, Date: "2026-11-10"},
+		{StudentID: 11, Subject: "Математика", Value: 5, Date: "2026-09-10"},
+	} {
+		if _, err := st.addGrade(g); err != nil {
+			return err
+		}
+	}
+	// (4 + 3 + 3) / 3 = 3.33 → 3; у второго ученика одна оценка — меньше minGrades.
+	props, err := termGradeProposals(st, q1.ID, "математика", []int64{10, 11})
+	if err != nil {
+		return err
+	}
+	if len(props) != 2 || props[0].Average != 3.33 || props[0].Count != 3 || props[0].Proposed != 3 || props[1].Proposed != 0 {
+		return fmt.Errorf("termGradeProposals returned %+v", props)
+	}
+	if _, err := st.setTermRounding(TermRounding{Threshold: 0.3, MinGrades: 1}); err != nil {
+		return err
+	}
+	if props, _ := termGradeProposals(st, q1.ID, "Математика", []int64{10, 11}); props[0].Proposed != 4 || props[1].Proposed != 5 {
+		return fmt.Errorf("proposals after rounding change = %+v", props)
+	}
+	if _, err := st.setTermRounding(TermRounding{Threshold: 1, MinGrades: 1}); err == nil {
+		return fmt.Errorf("threshold 1 accepted")
+	}
+	if _, err := st.approveTermGrade(termGradeApproval{TermID: q1.ID, StudentID: 10, Subject: "Математика", Value: 5, TeacherID: 1}); err == nil {
+		return fmt.Errorf("override without reason accepted")
+	}
+	tg, err := st.approveTermGrade(termGradeApproval{TermID: q1.ID, StudentID: 10, Subject: "Математика", TeacherID: 1})
+	if err != nil {
+		return err
+	}
+	if tg.Value != 4 || tg.Proposed != 4 || tg.Overridden || tg.Average != 3.33 {
+		return fmt.Errorf("approveTermGrade returned %+v", tg)
+	}
+	if _, err := st.approveTermGrade(termGradeApproval{TermID: q1.ID, StudentID: 10, Subject: "Математика", TeacherID: 1}); !errors.Is(err, errTermGradeExists) {
+		return fmt.Errorf("second approval: %v", err)
+	}
+	over, err := st.approveTermGrade(termGradeApproval{TermID: q1.ID, StudentID: 11, Subject: "Математика", Value: 4, Reason: "мало оценок", TeacherID: 1})
+	if err != nil {
+		return err
+	}
+	if !over.Overridden || over.Proposed != 5 {
+		return fmt.Errorf("override returned %+v", over)
+	}
+
+	if _, err := st.addGrade(Grade{StudentID: 10, Subject: "Математика", Value: 5, Date: "2026-10-02"}); !errors.Is(err, errTermLocked) {
+		return fmt.Errorf("grade added to locked term: %v", err)
+	}
+	if _, err := st.addGrade(Grade{StudentID: 10, Subject: "Физика", Value: 5, Date: "2026-10-02"}); err != nil {
+		return fmt.Errorf("other subject locked too: %w", err)
+	}
+	var locked Grade
+	for _, g := range st.listGradesByStudent(10) {
+		if g.Date == "2026-09-10" {
+			locked = g
+		}
+	}
+	locked.Value = 5
+	if _, _, err := st.updateGrade(locked, 1, "x"); !errors.Is(err, errTermLocked) {
+		return fmt.Errorf("locked grade updated: %v", err)
+	}
+	if _, err := st.deleteGrade(locked.ID, 1, "x"); !errors.Is(err, errTermLocked) {
+		return fmt.Errorf("locked grade deleted: %v", err)
+	}
+	if _, err := st.updateTerm(Term{ID: q1.ID, AcademicYear: "2026/2027", Name: "I", StartDate: "2026-09-02", EndDate: "2026-10-25"}); !errors.Is(err, errTermInUse) {
+		return fmt.Errorf("dates of an approved term changed: %v", err)
+	}
+	if _, err := st.deleteTerm(q1.ID); !errors.Is(err, errTermInUse) {
+		return fmt.Errorf("approved term deleted: %v", err)
+	}
+	if n := len(st.listTermGrades(q1.ID, 0)); n != 2 {
+		return fmt.Errorf("listTermGrades returned %d", n)
+	}
+
+	if ok, err := st.reopenTermGrade(tg.ID); err != nil || !ok {
+		return fmt.Errorf("reopenTermGrade: %v, %v", ok, err)
+	}
+	if _, err := st.addGrade(Grade{StudentID: 10, Subject: "Математика", Value: 5, Date: "2026-10-02"}); err != nil {
+		return fmt.Errorf("reopened term still locked: %w", err)
+	}
+	if ok, err := st.deleteTerm(q2.ID); err != nil || !ok {
+		return fmt.Errorf("deleteTerm: %v, %v", ok, err)
+	}
+	return nil
+}
+
+func checkScales(st *Storage) error {
+	if err := addClasses(st, "8A"); err != nil {
+		return err
+	}
+	if err := addSubjects(st, "Физкультура", "Информатика", "Химия"); err != nil {
+		return err
+	}
+	scales := st.listScales()
+	if len(scales) != 3 || !scales[0].Default || len(scales[2].Values) != 10 {
+		return fmt.Errorf("seeded scales = %+v", scales)
+	}
+	five, passFail, ten := scales[0], scales[1], scales[2]
+	if _, err := st.createScale(GradingScale{Name: "Кривая", Values: []ScaleValue{{Value: 1}, {Value: 1}}}); err == nil {
+		return fmt.Errorf("scale with duplicate values accepted")
+	}
+	pe, _ := st.resolveSubject("Физкультура")
+	pe.ScaleID = passFail.ID
+	if _, err := st.updateSubject(pe); err != nil {
+		return err
+	}
+	bad := pe
+	bad.ScaleID = 99
+	if _, err := st.updateSubject(bad); !errors.Is(err, errUnknownScale) {
+		return fmt.Errorf("unknown scale accepted for subject: %v", err)
+	}
+	c, _ := st.getClass("8A")
+	c.ScaleID = ten.ID
+	if _, err := st.updateClass(c); err != nil {
+		return err
+	}
+	u, err := st.createUser(User{FullName: "S", Email: "s@school.local", Role: RoleStudent, ClassName: "8A"})
+	if err != nil {
+		return err
+	}
+	// шкала предмета важнее шкалы класса, шкала класса — шкалы по умолчанию
+	if sc, _ := st.scaleFor("Физкультура", "8A"); sc.ID != passFail.ID {
+		return fmt.Errorf("scaleFor(PE) = %+v", sc)
+	}
+	if sc, _ := st.scaleFor("Информатика", "8a"); sc.ID != ten.ID {
+		return fmt.Errorf("scaleFor(class) = %+v", sc)
+	}
+	if sc, _ := st.scaleFor("Химия", "7B"); sc.ID != five.ID {
+		return fmt.Errorf("scaleFor(default) = %+v", sc)
+	}
+	if _, err := st.addGrade(Grade{StudentID: u.ID, Subject: "Физкультура", Value: 5, Date: "2026-02-01"}); !errors.Is(err, errInvalidGradeValue) {
+		return fmt.Errorf("value outside pass/fail accepted: %v", err)
+	}
+	pass, err := st.addGrade(Grade{StudentID: u.ID, Subject: "Физкультура", Value: 2, Date: "2026-02-01"})
+	if err != nil {
+		return err
+	}
+	if pass.ScaleID != passFail.ID {
+		return fmt.Errorf("grade scale = %d", pass.ScaleID)
+	}
+	high, err := st.addGrade(Grade{StudentID: u.ID, Subject: "Информатика", Value: 9, Date: "2026-02-01"})
+	if err != nil {
+		return err
+	}
+	if _, err := st.addGrade(Grade{StudentID: 10, Subject: "Информатика", Value: 9, Date: "2026-02-01"}); !errors.Is(err, errInvalidGradeValue) {
+		return fmt.Errorf("9 accepted on the default scale: %v", err)
+	}
+	high.Value = 11
+	if _, _, err := st.updateGrade(high, 1, "x"); !errors.Is(err, errInvalidGradeValue) {
+		return fmt.Errorf("update outside the scale accepted: %v", err)
+	}
+	// зачет в среднее не входит
+	avg := gradeAverages(st, st.listGradesByStudent(u.ID))
+	if len(avg) != 1 || avg[0].Subject != "Информатика" || avg[0].Average != 9 {
+		return fmt.Errorf("gradeAverages = %+v", avg)
+	}
+
+	// у оценки своя шкала: смена шкалы класса не делает ее недопустимой
+	c.ScaleID = 0
+	if _, err := st.updateClass(c); err != nil {
+		return err
+	}
+	high.Value = 10
+	if _, _, err := st.updateGrade(high, 1, "x"); err != nil {
+		return fmt.Errorf("grade lost its scale: %w", err)
+	}
+	ten.Values = ten.Values[:5]
+	if _, err := st.updateScale(ten); !errors.Is(err, errScaleInUse) {
+		return fmt.Errorf("used value removed from scale: %v", err)
+	}
+	if _, err := st.deleteScale(passFail.ID); !errors.Is(err, errScaleInUse) {
+		return fmt.Errorf("used scale deleted: %v", err)
+	}
+	five.Default = false
+	if _, err := st.updateScale(five); err == nil {
+		return fmt.Errorf("default flag removed without a replacement")
+	}
+
+	term, err := st.createTerm(Term{AcademicYear: "2025/2026", Name: "2 полугодие", StartDate: "2026-01-10", EndDate: "2026-05-31"})
+	if err != nil {
+		return err
+	}
+	if _, err := st.setTermRounding(TermRounding{Threshold: 0.5, MinGrades: 1}); err != nil {
+		return err
+	}
+	props, err := termGradeProposals(st, term.ID, "Физкультура", []int64{u.ID})
+	if err != nil {
+		return err
+	}
+	if props[0].ScaleID != passFail.ID || props[0].Proposed != 0 || props[0].Count != 0 {
+		return fmt.Errorf("pass/fail proposal = %+v", props[0])
+	}
+	if _, err := st.approveTermGrade(termGradeApproval{TermID: term.ID, StudentID: u.ID, Subject: "Физкультура", Value: 3, TeacherID: 1}); !errors.Is(err, errInvalidGradeValue) {
+		return fmt.Errorf("term grade outside the scale accepted: %v", err)
+	}
+	tg, err := st.approveTermGrade(termGradeApproval{TermID: term.ID, StudentID: u.ID, Subject: "Физкультура", Value: 2, TeacherID: 1})
+	if err != nil {
+		return err
+	}
+	if tg.Overridden || tg.ScaleID != passFail.ID {
+		return fmt.Errorf("pass/fail term grade = %+v", tg)
+	}
+	c.ScaleID = ten.ID
+	if _, err := st.updateClass(c); err != nil {
+		return err
+	}
+	props, _ = termGradeProposals(st, term.ID, "Информатика", []int64{u.ID})
+	if props[0].Proposed != 10 || props[0].ScaleID != ten.ID {
+		return fmt.Errorf("ten-point proposal = %+v", props[0])
+	}
+
+	custom, err := st.createScale(GradingScale{Name: " Буквенная ", Values: []ScaleValue{{Value: 2, Label: "B"}, {Value: 1, Label: "A"}}, Default: true})
+	if err != nil {
+		return err
+	}
+	if custom.Name != "Буквенная" || custom.Values[0].Label != "A" {
+		return fmt.Errorf("createScale returned %+v", custom)
+	}
+	if sc, _ := st.getScale(five.ID); sc.Default {
+		return fmt.Errorf("old default scale kept its flag")
+	}
+	if ok, err := st.deleteScale(five.ID); err != nil || !ok {
+		return fmt.Errorf("deleteScale: %v, %v", ok, err)
+	}
+	return nil
+}
+
+func checkJournalMatrix(st *Storage) error {
+	if err := addClasses(st, "7A", "7B"); err != nil {
+		return err
+	}
+	if err := addSubjects(st, "Математика", "Физика"); err != nil {
+		return err
+	}
+	var ids []int64
+	for _, u := range []User{
+		{FullName: "Яковлев", Email: "y@school.local", Role: RoleStudent, ClassName: "7A"},
+		{FullName: "Андреева", Email: "a@school.local", Role: RoleStudent, ClassName: "7A"},
+		{FullName: "Борисов", Email: "b@school.local", Role: RoleStudent, ClassName: "7B"},
+	} {
+		created, err := st.createUser(u)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, created.ID)
+	}
+	for _, e := range []ScheduleEntry{
+		{ClassName: "7A", Subject: "Математика", Weekday: "Пн", StartTime: "09:00"},
+		{ClassName: "7A", Subject: "Математика", Weekday: "thursday", StartTime: "10:00"},
+		{ClassName: "7A", Subject: "Физика", Weekday: "wednesday", StartTime: "11:00"},
+		{ClassName: "7B", Subject: "Математика", Weekday: "tuesday", StartTime: "09:00"},
+	} {
+		if _, err := st.addSchedule(e); err != nil {
+			return err
+		}
+	}
+	// 2026-03-02 — понедельник
+	for _, g := range []Grade{
+		{StudentID: ids[0], Subject: "Математика", Value: 5, Type: "control", Date: "2026-03-05"},
+		{StudentID: ids[0], Subject: "Математика", Value: 3, Date: "2026-03-05"},
+		{StudentID: ids[1], Subject: "Математика", Value: 4, Date: "2026-03-07"},
+		{StudentID: ids[1], Subject: "Физика", Value: 2, Date: "2026-03-04"},
+		{StudentID: ids[2], Subject: "Математика", Value: 2, Date: "2026-03-03"},
+		{StudentID: ids[0], Subject: "Математика", Value: 2, Date: "2026-03-20"},
+	} {
+		if _, err := st.addGrade(g); err != nil {
+			return err
+		}
+	}
+	if list := st.listGrades(gradeFilter{ClassName: "7a", Subject: "математика", From: "2026-03-01", To: "2026-03-10"}); len(list) != 3 ||
+		list[0].Value != 5 || list[2].StudentID != ids[1] {
+		return fmt.Errorf("listGrades(class) = %+v", list)
+	}
+	if list := st.listGrades(gradeFilter{StudentID: ids[1]}); len(list) != 2 || list[1].Subject != "Физика" {
+		return fmt.Errorf("listGrades(student) = %+v", list)
+	}
+	if list := st.listGrades(gradeFilter{Subject: "Астрономия"}); len(list) != 0 {
+		return fmt.Errorf("listGrades(unknown subject) = %+v", list)
+	}
+	m, err := journalMatrix(st, "7a", "математика", "2026-03-01", "2026-03-10")
+	if err != nil {
+		return err
+	}
+	var dates []string
+	for _, c := range m.Columns {
+		dates = append(dates, fmt.Sprintf("%s/%v", c.Date, c.Scheduled))
+	}
+	if strings.Join(dates, " ") != "2026-03-02/true 2026-03-05/true 2026-03-07/false 2026-03-09/true" || m.Columns[0].Weekday != "monday" {
+		return fmt.Errorf("journal columns = %v", dates)
+	}
+	if len(m.Rows) != 2 || m.Rows[0].FullName != "Андреева" || m.Rows[1].StudentID != ids[0] {
+		return fmt.Errorf("journal rows = %+v", m.Rows)
+	}
+	first, second := m.Rows[0], m.Rows[1]
+	if len(first.Cells) != 4 || len(first.Cells[2]) != 1 || first.Cells[2][0].Value != 4 || len(first.Cells[0]) != 0 {
+		return fmt.Errorf("journal cells = %+v", first.Cells)
+	}
+	// (5·3 + 3·1) / 4 = 4.5
+	if len(second.Cells[1]) != 2 || second.Average != 4.5 || second.Count != 2 {
+		return fmt.Errorf("journal row = %+v", second)
+	}
+	if _, err := journalMatrix(st, "7A", "Математика", "2026-03-10", "2026-03-01"); err == nil {
+		return fmt.Errorf("reversed period accepted")
+	}
+	if _, err := journalMatrix(st, "9Z", "Математика", "2026-03-01", "2026-03-10"); !errors.Is(err, errUnknownClass) {
+		return fmt.Errorf("unknown class: %v", err)
+	}
+	return nil
+}
+
+func checkAttendance(st *Storage) error {
+	if err := addClasses(st, "5A", "5B"); err != nil {
+		return err
+	}
+	if err := addSubjects(st, "Чтение", "Музыка"); err != nil {
+		return err
+	}
+	var ids []int64
+	for _, u := range []User{
+		{FullName: "Б", Email: "b@school.local", Role: RoleStudent, ClassName: "5A"},
+		{FullName: "А", Email: "a@school.local", Role: RoleStudent, ClassName: "5A"},
+		{FullName: "В", Email: "v@school.local", Role: RoleStudent, ClassName: "5B"},
+	} {
+		created, err := st.createUser(u)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, created.ID)
+	}
+	_, err := st.markAttendance(attendanceSheet{ClassName: "5A", Subject: "Чтение", Date: "2026-03-02", TeacherID: 1, Marks: []Attendance{
+		{StudentID: ids[0], Status: "absent"},
+		{StudentID: ids[2], Status: "absent"},
+		{StudentID: ids[1], Status: "sick"},
+	}})
+	var batchErr *batchError
+	if !errors.As(err, &batchErr) || len(batchErr.Rows) != 2 || batchErr.Rows[0].Row != 1 || batchErr.Rows[1].Row != 2 {
+		return fmt.Errorf("invalid sheet: %v", err)
+	}
+	if n := len(st.listAttendance(attendanceFilter{})); n != 0 {
+		return fmt.Errorf("rejected sheet saved %d marks", n)
+	}
+	for _, sheet := range []attendanceSheet{
+		{ClassName: "5a", Subject: "чтение", Date: "2026-03-02", Marks: []Attendance{{StudentID: ids[0], Status: "Absent"}, {StudentID: ids[1], Status: "present"}}},
+		{ClassName: "5A", Subject: "Музыка", Date: "2026-03-03", Marks: []Attendance{{StudentID: ids[0], Status: "late", Comment: "10 минут"}}},
+		{ClassName: "5A", Subject: "Чтение", Date: "2026-04-01", Marks: []Attendance{{StudentID: ids[1], Status: "excused"}}},
+		{ClassName: "5B", Subject: "Чтение", Date: "2026-03-02", Marks: []Attendance{{StudentID: ids[2], Status: "absent"}}},
+	} {
+		if _, err := st.markAttendance(sheet); err != nil {
+			return err
+		}
+	}
+	// повторная отметка заменяет прежнюю
+	fixed, err := st.markAttendance(attendanceSheet{ClassName: "5A", Subject: "Чтение", Date: "2026-03-02", Marks: []Attendance{{StudentID: ids[0], Status: "excused"}}})
+	if err != nil {
+		return err
+	}
+	marks := st.listAttendance(attendanceFilter{StudentID: ids[0]})
+	if len(marks) != 2 || marks[0].ID != fixed[0].ID || marks[0].Status != "excused" || marks[1].Comment != "10 минут" {
+		return fmt.Errorf("student marks = %+v", marks)
+	}
+	if n := len(st.listAttendance(attendanceFilter{ClassName: "5A", Subject: "Чтение", From: "2026-03-01", To: "2026-03-31"})); n != 2 {
+		return fmt.Errorf("class marks for March = %d", n)
+	}
+	report := attendanceReport(st, "", "2026-03-01", "2026-03-31")
+	if len(report) != 2 || report[0].ClassName != "5A" || report[0].Marked != 3 || report[0].Excused != 1 || report[0].Late != 1 || report[0].Absent != 0 ||
+		len(report[0].Students) != 2 || report[0].Students[0].FullName != "А" || report[0].Students[0].Marked != 1 || report[1].Absent != 1 {
+		return fmt.Errorf("attendanceReport = %+v", report)
+	}
+	if r := attendanceReport(st, "5a", "", ""); len(r) != 1 || r[0].Excused != 2 {
+		return fmt.Errorf("attendanceReport(5A) = %+v", r)
+	}
+	if _, err := st.deleteClass("5B"); !errors.Is(err, errClassInUse) {
+		return fmt.Errorf("class with attendance deleted: %v", err)
+	}
+	return nil
+}
+
+func checkLessons(st *Storage) error {
+	if err := addClasses(st, "6A", "6B", "6C"); err != nil {
+		return err
+	}
+	if err := addSubjects(st, "История", "Химия"); err != nil {
+		return err
+	}
+	six, err := st.createUser(User{FullName: "Шестаков", Email: "s6@school.local", Role: RoleStudent, ClassName: "6A"})
+	if err != nil {
+		return err
+	}
+	other, err := st.createUser(User{FullName: "Другой", Email: "d6@school.local", Role: RoleStudent, ClassName: "6B"})
+	if err != nil {
+		return err
+	}
+	hw, err := st.addHomework(Homework{ClassName: "6A", Subject: "История", Description: "§5", DueDate: "2026-03-04"})
+	if err != nil {
+		return err
+	}
+	foreign, err := st.addHomework(Homework{ClassName: "6B", Subject: "История", Description: "§5", DueDate: "2026-03-04"})
+	if err != nil {
+		return err
+	}
+	for _, l := range []Lesson{
+		{ClassName: "6A", Subject: "История", Date: "2026-03-02", Period: 2},
+		{ClassName: "6A", Subject: "История", Date: "2026-03-02", Period: 0, Topic: "Египет"},
+		{ClassName: "6A", Subject: "История", Date: "2026-03-02", Period: 2, Topic: "Египет", HomeworkID: foreign.ID},
+		{ClassName: "6A", Subject: "История", Date: "2 марта", Period: 2, Topic: "Египет"},
+	} {
+		if _, err := st.createLesson(l); err == nil {
+			return fmt.Errorf("invalid lesson accepted: %+v", l)
+		}
+	}
+	if _, err := st.createLesson(Lesson{ClassName: "9Z", Subject: "История", Date: "2026-03-02", Period: 1, Topic: "Египет"}); !errors.Is(err, errUnknownClass) {
+		return fmt.Errorf("lesson for unknown class: %v", err)
+	}
+	first, err := st.createLesson(Lesson{ClassName: "6a", Subject: "история", Date: "2026-03-02", Period: 2, Topic: " Египет ", HomeworkID: hw.ID, TeacherID: 1})
+	if err != nil {
+		return err
+	}
+	if first.ClassName != "6A" || first.Subject != "История" || first.Topic != "Египет" || first.HomeworkID != hw.ID {
+		return fmt.Errorf("createLesson = %+v", first)
+	}
+	if _, err := st.createLesson(Lesson{ClassName: "6A", Subject: "История", Date: "2026-03-02", Period: 2, Topic: "Еще раз"}); !errors.Is(err, errLessonExists) {
+		return fmt.Errorf("duplicate lesson: %v", err)
+	}
+	second, err := st.createLesson(Lesson{ClassName: "6A", Subject: "История", Date: "2026-03-04", Period: 1, Topic: "Вавилон", TeacherID: 2})
+	if err != nil {
+		return err
+	}
+	third, err := st.createLesson(Lesson{ClassName: "6A", Subject: "История", Date: "2026-03-04", Period: 3, Topic: "Ассирия", TeacherID: 2})
+	if err != nil {
+		return err
+	}
+
+	// единственный урок даты привязывается сам, при нескольких уроках — только явно
+	linked, err := st.addGrade(Grade{StudentID: six.ID, Subject: "История", Value: 5, Date: "2026-03-02"})
+	if err != nil || linked.LessonID != first.ID {
+		return fmt.Errorf("grade not linked to the lesson: %+v, %v", linked, err)
+	}
+	if g, err := st.addGrade(Grade{StudentID: six.ID, Subject: "История", Value: 4, Date: "2026-03-04"}); err != nil || g.LessonID != 0 {
+		return fmt.Errorf("grade linked to one of two lessons: %+v, %v", g, err)
+	}
+	explicit, err := st.addGrade(Grade{StudentID: six.ID, Subject: "История", Value: 3, Date: "2026-03-04", LessonID: third.ID})
+	if err != nil || explicit.LessonID != third.ID {
+		return fmt.Errorf("grade for an explicit lesson: %+v, %v", explicit, err)
+	}
+	for _, g := range []Grade{
+		{StudentID: six.ID, Subject: "История", Value: 3, Date: "2026-03-02", LessonID: second.ID},
+		{StudentID: other.ID, Subject: "История", Value: 3, Date: "2026-03-02", LessonID: first.ID},
+		{StudentID: six.ID, Subject: "Химия", Value: 3, Date: "2026-03-02", LessonID: first.ID},
+	} {
+		if _, err := st.addGrade(g); !errors.Is(err, errLessonMismatch) {
+			return fmt.Errorf("grade for another lesson: %v", err)
+		}
+	}
+	if _, err := st.addGrade(Grade{StudentID: six.ID, Subject: "История", Value: 3, Date: "2026-03-02", LessonID: 999}); !errors.Is(err, errLessonNotFound) {
+		return fmt.Errorf("grade for unknown lesson: %v", err)
+	}
+	moved := linked
+	moved.Date = "2026-03-09"
+	if moved, _, err = st.updateGrade(moved, 1, "перенос"); err != nil || moved.LessonID != 0 {
+		return fmt.Errorf("moved grade keeps the lesson: %+v, %v", moved, err)
+	}
+	marks, err := st.markAttendance(attendanceSheet{ClassName: "6A", Subject: "История", Date: "2026-03-04", LessonID: second.ID, Marks: []Attendance{{StudentID: six.ID, Status: "late"}}})
+	if err != nil || marks[0].LessonID != second.ID {
+		return fmt.Errorf("attendance for a lesson: %+v, %v", marks, err)
+	}
+	if _, err := st.markAttendance(attendanceSheet{ClassName: "6A", Subject: "История", Date: "2026-03-04", LessonID: third.ID, Marks: []Attendance{{StudentID: six.ID, Status: "absent"}}}); err != nil {
+		return err
+	}
+	if n := len(st.listAttendance(attendanceFilter{StudentID: six.ID})); n != 2 {
+		return fmt.Errorf("marks for two lessons of a day = %d", n)
+	}
+	if _, err := st.markAttendance(attendanceSheet{ClassName: "6A", Subject: "История", Date: "2026-03-02", LessonID: second.ID, Marks: []Attendance{{StudentID: six.ID, Status: "late"}}}); !errors.Is(err, errLessonMismatch) {
+		return fmt.Errorf("attendance for another lesson: %v", err)
+	}
+
+	second.Period = 3
+	if _, err := st.updateLesson(second); !errors.Is(err, errLessonExists) {
+		return fmt.Errorf("lesson moved onto another: %v", err)
+	}
+	second.Period, second.Topic, second.Date = 2, "Древний Вавилон", "2026-05-01"
+	if second, err = st.updateLesson(second); err != nil || second.Date != "2026-03-04" || second.Topic != "Древний Вавилон" {
+		return fmt.Errorf("updateLesson = %+v, %v", second, err)
+	}
+	if list := st.listLessons(lessonFilter{ClassName: "6a", Subject: "история", From: "2026-03-03"}); len(list) != 2 || list[0].ID != second.ID || list[1].ID != third.ID {
+		return fmt.Errorf("listLessons = %+v", list)
+	}
+	if list := st.listLessons(lessonFilter{TeacherID: 1}); len(list) != 1 || list[0].ID != first.ID {
+		return fmt.Errorf("listLessons(teacher) = %+v", list)
+	}
+
+	m, err := journalMatrix(st, "6A", "История", "2026-03-01", "2026-03-10")
+	if err != nil {
+		return err
+	}
+	if len(m.Columns) != 3 || m.Columns[0].Date != "2026-03-02" || m.Columns[0].Scheduled || len(m.Columns[0].Lessons) != 1 ||
+		m.Columns[0].Lessons[0].Topic != "Египет" || len(m.Columns[1].Lessons) != 2 || m.Columns[1].Lessons[1].ID != third.ID ||
+		len(m.Columns[2].Lessons) != 0 {
+		return fmt.Errorf("journal columns = %+v", m.Columns)
+	}
+
+	// отметка без урока совпадет по ключу с отметкой удаляемого урока: остается поздняя
+	if _, err := st.markAttendance(attendanceSheet{ClassName: "6A", Subject: "История", Date: "2026-03-04", Marks: []Attendance{{StudentID: six.ID, Status: "present"}}}); err != nil {
+		return err
+	}
+	if ok, err := st.deleteLesson(third.ID, 1); err != nil || !ok {
+		return fmt.Errorf("deleteLesson: %v, %v", ok, err)
+	}
+	if _, ok := st.getLesson(third.ID); ok {
+		return fmt.Errorf("deleted lesson still found")
+	}
+	for _, g := range st.listGradesByStudent(six.ID) {
+		if g.LessonID == third.ID {
+			return fmt.Errorf("grade still linked to a deleted lesson: %+v", g)
+		}
+	}
+	if n := len(st.listAttendance(attendanceFilter{LessonID: third.ID})); n != 0 {
+		return fmt.Errorf("marks still linked to a deleted lesson: %d", n)
+	}
+	unlinked := st.listAttendance(attendanceFilter{StudentID: six.ID, From: "2026-03-04", To: "2026-03-04"})
+	if len(unlinked) != 2 {
+		return fmt.Errorf("marks after unlinking = %+v", unlinked)
+	}
+	for _, a := range unlinked {
+		if a.LessonID == 0 && a.Status != "present" {
+			return fmt.Errorf("older mark kept after unlinking: %+v", a)
+		}
+	}
+	if revs := st.listGradeRevisions(explicit.ID, 0); len(revs) != 1 || revs[0].ActorID != 1 || len(revs[0].Changes) != 1 ||
+		revs[0].Changes[0].Field != "lesson" || revs[0].Changes[0].From != fmt.Sprint(third.ID) {
+		return fmt.Errorf("revisions of an unlinked grade = %+v", revs)
+	}
+
+	// урок с оценками закрытого периода не удаляется
+	locked, err := st.createLesson(Lesson{ClassName: "6A", Subject: "История", Date: "2026-03-11", Period: 1, Topic: "Персия"})
+	if err != nil {
+		return err
+	}
+	if _, err := st.addGrade(Grade{StudentID: six.ID, Subject: "История", Value: 5, Date: "2026-03-11"}); err != nil {
+		return err
+	}
+	term, err := st.createTerm(Term{AcademicYear: "2025/2026", Name: "3 четверть", StartDate: "2026-01-12", EndDate: "2026-03-20"})
+	if err != nil {
+		return err
+	}
+	if _, err := st.approveTermGrade(termGradeApproval{TermID: term.ID, StudentID: six.ID, Subject: "История", Value: 4, TeacherID: 1}); err != nil {
+		return err
+	}
+	if _, err := st.deleteLesson(locked.ID, 1); !errors.Is(err, errTermLocked) {
+		return fmt.Errorf("lesson of a locked term deleted: %v", err)
+	}
+	if _, ok := st.getLesson(locked.ID); !ok {
+		return fmt.Errorf("lesson of a locked term is gone")
+	}
+	if _, err := st.createLesson(Lesson{ClassName: "6C", Subject: "Химия", Date: "2026-03-02", Period: 1, Topic: "Вещества"}); err != nil {
+		return err
+	}
+	if _, err := st.deleteClass("6C"); !errors.Is(err, errClassInUse) {
+		return fmt.Errorf("class with lessons deleted: %v", err)
+	}
+	return nil
+}
+
+func checkDiary(st *Storage) error {
+	if err := addClasses(st, "8A"); err != nil {
+		return err
+	}
+	if err := addSubjects(st, "Алгебра", "Физика", "Биология"); err != nil {
+		return err
+	}
+	u, err := st.createUser(User{FullName: "Дневников", Email: "diary@school.local", Role: RoleStudent, ClassName: "8A"})
+	if err != nil {
+		return err
+	}
+	for _, e := range []ScheduleEntry{
+		{ClassName: "8A", Subject: "Алгебра", Weekday: "Пн", StartTime: "09:00", EndTime: "09:45", Room: "12"},
+		{ClassName: "8A", Subject: "Физика", Weekday: "monday", StartTime: "8:00"},
+		{ClassName: "8A", Subject: "Алгебра", Weekday: "1", StartTime: "10:00"},
+		{ClassName: "8A", Subject: "Физика", Weekday: "вт.", StartTime: "09:00"},
+	} {
+		if _, err := st.addSchedule(e); err != nil {
+			return err
+		}
+	}
+	// 2026-W10: 2026-03-02 — 2026-03-08
+	if _, err := st.createLesson(Lesson{ClassName: "8A", Subject: "Алгебра", Date: "2026-03-02", Period: 3, Topic: "Уравнения"}); err != nil {
+		return err
+	}
+	for _, g := range []Grade{
+		{StudentID: u.ID, Subject: "Алгебра", Value: 5, Date: "2026-03-02"},
+		{StudentID: u.ID, Subject: "Биология", Value: 4, Date: "2026-03-03"},
+		{StudentID: u.ID, Subject: "Алгебра", Value: 3, Date: "2026-03-09"},
+	} {
+		if _, err := st.addGrade(g); err != nil {
+			return err
+		}
+	}
+	if _, err := st.addHomework(Homework{ClassName: "8A", Subject: "Физика", Description: "№3", DueDate: "2026-03-03"}); err != nil {
+		return err
+	}
+	if _, err := st.markAttendance(attendanceSheet{ClassName: "8A", Subject: "Физика", Date: "2026-03-02", Marks: []Attendance{{StudentID: u.ID, Status: "absent"}}}); err != nil {
+		return err
+	}
+
+	d, err := studentDiary(st, u.ID, "2026-W10")
+	if err != nil {
+		return err
+	}
+	if d.From != "2026-03-02" || d.To != "2026-03-08" || len(d.Days) != 6 || d.Days[0].Weekday != "monday" || d.Days[5].Date != "2026-03-07" {
+		return fmt.Errorf("diary days = %+v", d)
+	}
+	mon, tue := d.Days[0].Lessons, d.Days[1].Lessons
+	if len(mon) != 3 || mon[0].Subject != "Физика" || mon[0].Attendance != "absent" || mon[1].Period != 2 || mon[1].Room != "12" ||
+		len(mon[1].Grades) != 0 || mon[2].Topic != "Уравнения" || len(mon[2].Grades) != 1 || mon[2].Grades[0].Value != 5 {
+		return fmt.Errorf("diary monday = %+v", mon)
+	}
+	if len(tue) != 2 || len(tue[0].Homework) != 1 || !tue[0].Scheduled || tue[1].Subject != "Биология" || tue[1].Scheduled || len(tue[1].Grades) != 1 {
+		return fmt.Errorf("diary tuesday = %+v", tue)
+	}
+	if len(d.Days[2].Lessons) != 0 || d.Days[2].Lessons == nil {
+		return fmt.Errorf("diary wednesday = %+v", d.Days[2])
+	}
+	if d, err := studentDiary(st, u.ID, "2026-W53"); err != nil || d.From != "2026-12-28" {
+		return fmt.Errorf("diary for week 53: %+v, %v", d, err)
+	}
+	for _, week := range []string{"2026-10", "2026-W1", "2025-W53", "2026-W00"} {
+		if _, err := studentDiary(st, u.ID, week); err == nil {
+			return fmt.Errorf("week %q accepted", week)
+		}
+	}
+	return nil
+}
+
+func checkHomework(st *Storage) error {
+	if err := addClasses(st, "6B"); err != nil {
+		return err
+	}
+	if err := addSubjects(st, "Химия"); err != nil {
+		return err
+	}
+	hw, err := st.addHomework(Homework{ClassName: "6 в", Subject: "Химия", Description: "§1", DueDate: "2026-03-01"})
+	if err != nil {
+		return err
+	}
+	if hw.ID <= 0 || hw.ClassName != "6B" {
+		return fmt.Errorf("addHomework returned %+v", hw)
+	}
+	if n := len(st.listHomeworkByClass("6b")); n != 1 {
+		return fmt.Errorf("listHomeworkByClass returned %d", n)
+	}
+	if n := len(st.listHomeworkByClass("6A")); n != 0 {
+		return fmt.Errorf("homework leaked to another class")
+	}
+	return nil
+}
+
+func checkRollover(st *Storage) error {
+	if err := addSubjects(st, "Math"); err != nil {
+		return err
+	}
+	for _, c := range []Class{{Grade: 5, Letter: "A"}, {Grade: 6, Letter: "A"}, {Grade: 11, Letter: "A"}} {
+		c.AcademicYear = "2026/2027"
+		if _, err := st.createClass(c); err != nil {
+			return err
+		}
+	}
+	if _, err := st.createClass(Class{Grade: 1, Letter: "A", AcademicYear: "2027/2028"}); err != nil {
+		return err
+	}
+	five, err := st.createUser(User{FullName: "Five", Email: "five@school.local", Role: RoleStudent, ClassName: "5A"})
+	if err != nil {
+		return err
+	}
+	grad, err := st.createUser(User{FullName: "Grad", Email: "grad@school.local", Role: RoleStudent, ClassName: "11A"})
+	if err != nil {
+		return err
+	}
+	_, gradTokens, err := st.createSession(grad.ID, sessionClient{}, sessionTTL{Access: time.Hour, Refresh: time.Hour})
+	if err != nil {
+		return err
+	}
+	g, err := st.addGrade(Grade{StudentID: five.ID, Subject: "Math", Value: 5, TeacherID: 1, Date: "2027-05-20"})
+	if err != nil {
+		return err
+	}
+	if _, err := st.addHomework(Homework{ClassName: "5A", Subject: "Math", Description: "1"}); err != nil {
+		return err
+	}
+	if _, err := st.addSchedule(ScheduleEntry{ClassName: "6A", Subject: "Math", Weekday: "monday"}); err != nil {
+		return err
+	}
+	if _, err := st.markAttendance(attendanceSheet{ClassName: "5A", Subject: "Math", Date: "2027-05-20", Marks: []Attendance{{StudentID: five.ID, Status: "late"}}}); err != nil {
+		return err
+	}
+	if _, err := st.createLesson(Lesson{ClassName: "5A", Subject: "Math", Date: "2027-05-21", Period: 1, Topic: "Дроби"}); err != nil {
+		return err
+	}
+	nextYearHomework, err := st.addHomework(Homework{ClassName: "5A", Subject: "Math", Description: "летнее", DueDate: "2027-09-10"})
+	if err != nil {
+		return err
+	}
+	first, err := st.createUser(User{FullName: "First", Email: "first@school.local", Role: RoleStudent, ClassName: "1A"})
+	if err != nil {
+		return err
+	}
+	if _, err := st.addGrade(Grade{StudentID: first.ID, Subject: "Math", Value: 5, TeacherID: 1, Date: "2027-05-20"}); err != nil {
+		return err
+	}
+	if _, err := st.addHomework(Homework{ClassName: "1A", Subject: "Math", Description: "прописи", DueDate: "2027-05-21"}); err != nil {
+		return err
+	}
+	if _, err := st.addSchedule(ScheduleEntry{ClassName: "1A", Subject: "Math", Weekday: "tuesday"}); err != nil {
+		return err
+	}
+	if _, err := st.markAttendance(attendanceSheet{ClassName: "1A", Subject: "Math", Date: "2027-05-20", Marks: []Attendance{{StudentID: first.ID, Status: "present"}}}); err != nil {
+		return err
+	}
+	if _, err := st.createLesson(Lesson{ClassName: "1A", Subject: "Math", Date: "2027-05-21", Period: 1, Topic: "Счет"}); err != nil {
+		return err
+	}
+
+	plan, err := st.previewRollover(11)
+	if err != nil {
+		return err
+	}
+	if plan.FromYear != "2026/2027" || plan.ToYear != "2027/2028" || len(plan.Promotions) != 2 ||
+		plan.Promotions[0].From != "5A" || plan.Promotions[0].To != "6A" || plan.Promotions[0].Students != 1 ||
+		len(plan.Graduates) != 1 || plan.Graduates[0].From != "11A" || len(plan.Kept) != 1 || plan.Archive["grades"] != 1 ||
+		plan.Archive["homework"] != 1 || plan.Archive["schedule"] != 1 || plan.Archive["attendance"] != 1 || plan.Archive["lessons"] != 1 {
+		return fmt.Errorf("previewRollover = %+v", plan)
+	}
+	if len(st.listGradesByStudent(five.ID)) != 1 {
+		return fmt.Errorf("preview changed data")
+	}
+	if _, err := st.applyRollover(11, 1); err != nil {
+		return err
+	}
+	if u, _ := st.getUser(five.ID); u.ClassName != "6A" || u.Archived {
+		return fmt.Errorf("student not promoted: %+v", u)
+	}
+	if u, _ := st.getUser(grad.ID); !u.Archived {
+		return fmt.Errorf("graduate not archived: %+v", u)
+	}
+	if _, _, ok := st.sessionByToken(gradTokens.Token); ok {
+		return fmt.Errorf("graduate session still valid")
+	}
+	_, gradTokens, err = st.createSession(grad.ID, sessionClient{}, sessionTTL{Access: time.Hour, Refresh: time.Hour})
+	if err != nil {
+		return err
+	}
+	if _, _, ok := st.sessionByToken(gradTokens.Token); ok {
+		return fmt.Errorf("archived user's session accepted")
+	}
+	if _, _, _, err := st.refreshSession(gradTokens.RefreshToken, sessionClient{}, sessionTTL{Access: time.Hour, Refresh: time.Hour}); !errors.Is(err, errSessionNotFound) {
+		return fmt.Errorf("archived user's session refreshed: %v", err)
+	}
+	for _, u := range st.listStudentsSortedByClass() {
+		if u.ID == grad.ID {
+			return fmt.Errorf("graduate still listed among students")
+		}
+	}
+	if c, ok := st.getClass("7A"); !ok || c.AcademicYear != "2027/2028" {
+		return fmt.Errorf("6A not promoted to 7A: %+v", c)
+	}
+	if _, ok := st.getClass("11A"); ok {
+		return fmt.Errorf("graduated class still registered")
+	}
+	if c, ok := st.getClass("1A"); !ok || c.Grade != 1 {
+		return fmt.Errorf("class of the new year was changed: %+v", c)
+	}
+	if len(st.listGradesByStudent(five.ID)) != 0 || len(st.listScheduleByClass("7A")) != 0 ||
+		len(st.listAttendance(attendanceFilter{ClassName: "6A"})) != 0 || len(st.listLessons(lessonFilter{ClassName: "6A"})) != 0 {
+		return fmt.Errorf("current year data not cleared")
+	}
+	if hw := st.listHomeworkByClass("6A"); len(hw) != 1 || hw[0].ID != nextYearHomework.ID {
+		return fmt.Errorf("homework due next year not moved to the promoted class: %+v", hw)
+	}
+	if len(st.listGradesByStudent(first.ID)) != 1 || len(st.listHomeworkByClass("1A")) != 1 || len(st.listScheduleByClass("1A")) != 1 ||
+		len(st.listAttendance(attendanceFilter{ClassName: "1A"})) != 1 || len(st.listLessons(lessonFilter{ClassName: "1A"})) != 1 {
+		return fmt.Errorf("data of a class already on the next year was archived")
+	}
+	archive, ok := st.getArchive("2026-2027")
+	if !ok || len(archive.Grades) != 1 || archive.Grades[0] != g || len(archive.Students) != 2 || len(archive.Classes) != 3 || len(archive.Schedule) != 1 ||
+		len(archive.Attendance) != 1 || archive.Attendance[0].Status != "late" || len(archive.Lessons) != 1 || archive.Lessons[0].Topic != "Дроби" {
+		return fmt.Errorf("archive = %+v", archive)
+	}
+	if list := st.listArchives(); len(list) != 1 || list[0].Counts["grades"] != 1 {
+		return fmt.Errorf("listArchives = %+v", list)
+	}
+	if _, err := st.previewRollover(11); err != nil {
+		return fmt.Errorf("next year preview: %w", err)
+	}
+	if next, err := st.addGrade(Grade{StudentID: five.ID, Subject: "Math", Value: 4, TeacherID: 1, Date: "2027-09-10"}); err != nil || next.ID <= g.ID {
+		return fmt.Errorf("grade id reused after rollover: %+v, %v", next, err)
+	}
+	return nil
+}
+
+// checkReopen проверяет, что подтвержденные записи переживают перезапуск.
+func checkReopen(dir string, backend storeBackend) error {
+	st, err := backend.openStorage(dir)
+	if err != nil {
+		return err
+	}
+	if err := addClasses(st, "9A"); err != nil {
+		return err
+	}
+	if err := addSubjects(st, "История"); err != nil {
+		return err
+	}
+	u, err := st.createUser(User{FullName: "P", Email: "p@school.local", PasswordHash: "secret", Role: RoleStudent, ClassName: "9A"})
+	if err != nil {
+		return err
+	}
+	_, tokens, err := st.createSession(u.ID, sessionClient{}, sessionTTL{Access: time.Hour, Refresh: time.Hour})
+	if err != nil {
+		return err
+	}
+	g, err := st.addGrade(Grade{StudentID: u.ID, Subject: "История", Value: 5, TeacherID: 1, Date: "2026-01-15"})
+	if err != nil {
+		return err
+	}
+	if _, err := st.addHomework(Homework{ClassName: "9A", Subject: "История", Description: "§2"}); err != nil {
+		return err
+	}
+	lesson, err := st.createLesson(Lesson{ClassName: "9A", Subject: "История", Date: "2026-01-16", Period: 4, Topic: "Реформы"})
+	if err != nil {
+		return err
+	}
+	dropped, err := st.createLesson(Lesson{ClassName: "9A", Subject: "История", Date: "2026-01-17", Period: 1, Topic: "Ошибка"})
+	if err != nil {
+		return err
+	}
+	if _, err := st.deleteLesson(dropped.ID, 1); err != nil {
+		return err
+	}
+	if _, err := st.markAttendance(attendanceSheet{ClassName: "9A", Subject: "История", Date: "2026-01-16", Marks: []Attendance{{StudentID: u.ID, Status: "absent"}}}); err != nil {
+		return err
+	}
+	if _, err := st.addGrades([]Grade{{StudentID: 77, Subject: "История", Value: 3, Date: "2026-01-16"}, {StudentID: 78, Subject: "История", Value: 4, Date: "2026-01-16"}}); err != nil {
+		return err
+	}
+	g.Value = 4
+	if g, _, err = st.updateGrade(g, 1, "исправление"); err != nil {
+		return err
+	}
+	if _, err := st.setGradeTypeWeight("lab", 2.5); err != nil {
+		return err
+	}
+	passFail := st.listScales()[1]
+	if _, err := st.createScale(GradingScale{Name: "Буквенная", Values: []ScaleValue{{Value: 1, Label: "A"}, {Value: 2, Label: "B"}}}); err != nil {
+		return err
+	}
+	term, err := st.createTerm(Term{AcademicYear: "2026/2027", Name: "1 полугодие", StartDate: "2026-01-01", EndDate: "2026-06-30"})
+	if err != nil {
+		return err
+	}
+	if _, err := st.setTermRounding(TermRounding{Threshold: 0.6, MinGrades: 1}); err != nil {
+		return err
+	}
+	if _, err := st.approveTermGrade(termGradeApproval{TermID: term.ID, StudentID: u.ID, Subject: "История", TeacherID: 1}); err != nil {
+		return err
+	}
+	teacher, err := st.createUser(User{FullName: "T", Email: "t@school.local", Role: RoleTeacher})
+	if err != nil {
+		return err
+	}
+	if _, err := st.createAssignment(Assignment{TeacherID: teacher.ID, Subject: "История", ClassName: "9A"}); err != nil {
+		return err
+	}
+	if _, err := st.createClass(Class{Grade: 11, Letter: "A", AcademicYear: "2026/2027"}); err != nil {
+		return err
+	}
+	grad, err := st.createUser(User{FullName: "G", Email: "g@school.local", Role: RoleStudent, ClassName: "11A"})
+	if err != nil {
+		return err
+	}
+	renamed := "P2"
+	if _, _, err := st.updateUser(u.ID, userPatch{FullName: &renamed}, 1); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	_, resetCode, err := st.createPasswordReset(PasswordReset{UserID: u.ID, CreatedAt: now, ExpiresAt: now.Add(time.Hour)})
+	if err != nil {
+		return err
+	}
+	if err := st.Close(); err != nil {
+		return err
+	}
+
+	st, err = backend.openStorage(dir)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+	if _, ok := st.getClass("9A"); !ok {
+		return fmt.Errorf("class lost after reopen")
+	}
+	got, ok := st.findUserByEmail(u.Email)
+	if !ok || got.ID != u.ID || got.PasswordHash != "secret" || got.FullName != "P2" {
+		return fmt.Errorf("user lost after reopen: %+v", got)
+	}
+	if _, _, ok := st.sessionByToken(tokens.Token); !ok {
+		return fmt.Errorf("session lost after reopen")
+	}
+	grades := st.listGradesByStudent(u.ID)
+	if len(grades) != 1 || grades[0] != g {
+		return fmt.Errorf("grades after reopen = %+v", grades)
+	}
+	if len(st.listGradesByStudent(77)) != 1 || len(st.listGradesByStudent(78)) != 1 {
+		return fmt.Errorf("grade batch lost after reopen")
+	}
+	if marks := st.listAttendance(attendanceFilter{StudentID: u.ID}); len(marks) != 1 || marks[0].Status != "absent" || marks[0].ClassName != "9A" ||
+		marks[0].LessonID != lesson.ID {
+		return fmt.Errorf("attendance after reopen = %+v", marks)
+	}
+	if len(st.listHomeworkByClass("9A")) != 1 || len(st.teacherSubjects(teacher.ID)) != 1 {
+		return fmt.Errorf("homework or assignment lost after reopen")
+	}
+	if lessons := st.listLessons(lessonFilter{}); len(lessons) != 1 || lessons[0] != lesson {
+		return fmt.Errorf("lessons after reopen = %+v", lessons)
+	}
+	if revs := st.listGradeRevisions(g.ID, u.ID); len(revs) != 1 || revs[0].Changes[0].To != "4" {
+		return fmt.Errorf("grade revisions after reopen = %+v", revs)
+	}
+	if types := st.listGradeTypes(); types[4].Code != "lab" || types[4].Weight != 2.5 {
+		return fmt.Errorf("grade weights after reopen = %+v", types)
+	}
+	if st.termRounding().Threshold != 0.6 || len(st.listTerms("")) != 1 || len(st.listTermGrades(term.ID, u.ID)) != 1 {
+		return fmt.Errorf("terms or rounding lost after reopen")
+	}
+	if _, err := st.addGrade(Grade{StudentID: u.ID, Subject: "История", Value: 5, Date: "2026-02-01"}); !errors.Is(err, errTermLocked) {
+		return fmt.Errorf("term lock lost after reopen: %v", err)
+	}
+	if scales := st.listScales(); len(scales) != 4 || scales[3].Values[1].Label != "B" || !scales[0].Default {
+		return fmt.Errorf("grading scales after reopen = %+v", scales)
+	}
+	if sc, err := st.createScale(GradingScale{Name: "Еще одна", Values: passFail.Values}); err != nil || sc.ID != 5 {
+		return fmt.Errorf("scale counter after reopen: %+v, %v", sc, err)
+	}
+	if sub, ok := st.resolveSubject("история"); !ok || sub.ID != g.SubjectID {
+		return fmt.Errorf("subject catalog lost after reopen")
+	}
+	if audit := st.listAudit("user", u.ID); len(audit) != 1 {
+		return fmt.Errorf("audit after reopen = %+v", audit)
+	}
+	if _, err := st.redeemPasswordReset(u.Email, resetCode, "secret2"); err != nil {
+		return fmt.Errorf("reset code lost after reopen: %w", err)
+	}
+	next, err := st.createUser(User{FullName: "Q", Email: "q@school.local", Role: RoleTeacher})
+	if err != nil {
+		return err
+	}
+	if next.ID <= u.ID {
+		return fmt.Errorf("id counter regressed after reopen: %d after %d", next.ID, u.ID)
+	}
+
+	if _, err := st.applyRollover(11, 1); err != nil {
+		return err
+	}
+	if err := st.Close(); err != nil {
+		return err
+	}
+	st, err = backend.openStorage(dir)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+	if got, _ := st.getUser(u.ID); got.ClassName != "10A" {
+		return fmt.Errorf("promotion lost after reopen: %+v", got)
+	}
+	if n := len(st.listAssignments(teacher.ID, "10A")); n != 1 {
+		return fmt.Errorf("assignment not promoted after reopen: %d", n)
+	}
+	if got, _ := st.getUser(grad.ID); !got.Archived {
+		return fmt.Errorf("graduate not archived after reopen: %+v", got)
+	}
+	if archive, ok := st.getArchive("2026/2027"); !ok || len(archive.Grades) != 1 || len(archive.GradeRevisions) != 1 ||
+		len(archive.Terms) != 1 || len(archive.TermGrades) != 1 || len(archive.Attendance) != 1 {
+		return fmt.Errorf("archive lost after reopen: %+v", archive)
+	}
+	return nil
+}
+
+func TestSessionByTokenTouchesStaleSessions(t *testing.T) {
+	st, err := NewStorage(memoryJournal{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := st.createUser(User{FullName: "T", Email: "t@school.local", Role: RoleTeacher, PasswordHash: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, tokens, err := st.createSession(u.ID, sessionClient{}, sessionTTL{Access: time.Hour, Refresh: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale := session.LastUsedAt.Add(-2 * sessionTouchInterval)
+	st.mu.Lock()
+	session.LastUsedAt = stale
+	st.sessions[session.ID] = session
+	st.mu.Unlock()
+
+	got, _, ok := st.sessionByToken(tokens.Token)
+	if !ok || !got.LastUsedAt.After(stale) {
+		t.Fatalf("stale session not touched: %+v, %v", got, ok)
+	}
+	again, _, _ := st.sessionByToken(tokens.Token)
+	if !again.LastUsedAt.Equal(got.LastUsedAt) {
+		t.Fatalf("lastUsedAt touched within interval: %v, want %v", again.LastUsedAt, got.LastUsedAt)
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// throttlePolicy задает порог и рост задержки для одного вида ключей.
+type throttlePolicy struct {
+	// FreeAttempts — сколько неудачных попыток допускается без блокировки.
+	FreeAttempts int
+	// BaseDelay — блокировка после первой попытки сверх порога; далее удваивается.
+	BaseDelay time.Duration
+	// MaxDelay — верхняя граница блокировки.
+	MaxDelay time.Duration
+	// ResetAfter — через сколько без неудачных попыток счетчик обнуляется.
+	ResetAfter time.Duration
+}
+
+// throttleEntry — счетчик неудачных попыток для email или адреса клиента.
+type throttleEntry struct {
+	Key         string    `json:"key"`
+	Failures    int       `json:"failures"`
+	LastFailure time.Time `json:"lastFailure"`
+	LockedUntil time.Time `json:"lockedUntil"`
+}
+
+// throttleMaxEntries — предел числа счетчиков в памяти по умолчанию.
+const throttleMaxEntries = 100000
+
+// loginThrottle ограничивает подбор паролей: отдельно по email и по IP.
+// Состояние хранится только в памяти процесса.
+type loginThrottle struct {
+	mu      sync.Mutex
+	entries map[string]*throttleEntry
+	byEmail throttlePolicy
+	byIP    throttlePolicy
+	// maxEntries — сколько счетчиков хранится одновременно; при переполнении
+	// вытесняются самые старые (см. evictLocked).
+	maxEntries int
+}
+
+// newLoginThrottle создает ограничитель с политиками по умолчанию.
+func newLoginThrottle() *loginThrottle {
+	return &loginThrottle{
+		entries:    make(map[string]*throttleEntry),
+		byEmail:    throttlePolicy{FreeAttempts: 5, BaseDelay: 30 * time.Second, MaxDelay: 15 * time.Minute, ResetAfter: time.Hour},
+		byIP:       throttlePolicy{FreeAttempts: 20, BaseDelay: 30 * time.Second, MaxDelay: 15 * time.Minute, ResetAfter: time.Hour},
+		maxEntries: throttleMaxEntries,
+	}
+}
+
+// throttleKeys возвращает ключи попытки входа: по email и по адресу клиента.
+func throttleKeys(email, ip string) (emailKeyName, ipKeyName string) {
+	return "email:" + emailKey(email), "ip:" + strings.TrimSpace(ip)
+}
+
+// policyFor выбирает политику по префиксу ключа.
+func (t *loginThrottle) policyFor(key string) throttlePolicy {
+	if strings.HasPrefix(key, "ip:") {
+		return t.byIP
+	}
+	return t.byEmail
+}
+
+// reserve занимает попытку входа до проверки пароля: под одной блокировкой проверяет,
+// что ключи не заблокированы, и сразу учитывает попытку как неудачную, так что
+// параллельные запросы не проходят сверх порога. Возвращает, сколько еще ждать
+// (0 — попытка занята). Удачную попытку нужно вернуть через succeed или release.
+func (t *loginThrottle) reserve(email, ip string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	emailName, ipName := throttleKeys(email, ip)
+	var wait time.Duration
+	for _, key := range []string{emailName, ipName} {
+		if e, ok := t.entries[key]; ok && now.Before(e.LockedUntil) {
+			wait = max(wait, e.LockedUntil.Sub(now))
+		}
+	}
+	if wait > 0 {
+		return wait
+	}
+	for _, key := range []string{emailName, ipName} {
+		policy := t.policyFor(key)
+		e, ok := t.entries[key]
+		if !ok || t.expired(e, policy, now) {
+			if !ok {
+				t.evictLocked(now)
+			}
+			e = &throttleEntry{Key: key}
+			t.entries[key] = e
+		}
+		e.Failures++
+		e.LastFailure = now
+		if over := e.Failures - policy.FreeAttempts; over > 0 {
+			e.LockedUntil = now.Add(lockDelay(policy, over))
+		}
+	}
+	return 0
+}
+
+// lockDelay возвращает блокировку после over попыток сверх порога.
+func lockDelay(policy throttlePolicy, over int) time.Duration {
+	if over > 20 {
+		return policy.MaxDelay
+	}
+	return min(policy.BaseDelay<<(over-1), policy.MaxDelay)
+}
+
+// succeed завершает удачную попытку: счетчик email сбрасывается, а попытка
+// возвращается счетчику адреса. Прежние неудачи адреса остаются, чтобы вход
+// в свою учетную запись не открывал подбор чужих.
+func (t *loginThrottle) succeed(email, ip string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	emailName, ipName := throttleKeys(email, ip)
+	delete(t.entries, emailName)
+	t.releaseLocked(ipName)
+}
+
+// release возвращает занятую попытку обоим счетчикам, когда проверка не состоялась
+// (например, из-за внутренней ошибки).
+func (t *loginThrottle) release(email, ip string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	emailName, ipName := throttleKeys(email, ip)
+	t.releaseLocked(emailName)
+	t.releaseLocked(ipName)
+}
+
+// releaseLocked уменьшает счетчик ключа на одну попытку и снимает блокировку,
+// если без этой попытки порог не превышен.
+func (t *loginThrottle) releaseLocked(key string) {
+	e, ok := t.entries[key]
+	if !ok {
+		return
+	}
+	e.Failures--
+	if e.Failures <= 0 {
+		delete(t.entries, key)
+		return
+	}
+	if e.Failures <= t.policyFor(key).FreeAttempts {
+		e.LockedUntil = time.Time{}
+	}
+}
+
+// evictLocked освобождает место под новый счетчик, когда достигнут maxEntries:
+// сначала удаляет устаревшие счетчики, затем самый давний незаблокированный,
+// а если заблокированы все — тот, чья блокировка истекает раньше.
+func (t *loginThrottle) evictLocked(now time.Time) {
+	if len(t.entries) < t.maxEntries {
+		return
+	}
+	for key, e := range t.entries {
+		if t.expired(e, t.policyFor(key), now) {
+			delete(t.entries, key)
+		}
+	}
+	if len(t.entries) < t.maxEntries {
+		return
+	}
+	var victim *throttleEntry
+	for _, e := range t.entries {
+		switch {
+		case victim == nil:
+			victim = e
+		case now.Before(victim.LockedUntil) != now.Before(e.LockedUntil):
+			if !now.Before(e.LockedUntil) {
+				victim = e
+			}
+		case now.Before(e.LockedUntil):
+			if e.LockedUntil.Before(victim.LockedUntil) {
+				victim = e
+			}
+		case e.LastFailure.Before(victim.LastFailure):
+			victim = e
+		}
+	}
+	delete(t.entries, victim.Key)
+}
+
+// list возвращает актуальные счетчики, заблокированные первыми.
+func (t *loginThrottle) list() []throttleEntry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	res := []throttleEntry{}
+	for _, e := range t.entries {
+		if !t.expired(e, t.policyFor(e.Key), now) {
+			res = append(res, *e)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if !res[i].LockedUntil.Equal(res[j].LockedUntil) {
+			return res[i].LockedUntil.After(res[j].LockedUntil)
+		}
+		return res[i].Key < res[j].Key
+	})
+	return res
+}
+
+// clear снимает блокировку и обнуляет счетчик ключа.
+func (t *loginThrottle) clear(key string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.entries[key]; !ok {
+		return false
+	}
+	delete(t.entries, key)
+	return true
+}
+
+// prune удаляет устаревшие счетчики.
+func (t *loginThrottle) prune() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	n := 0
+	for key, e := range t.entries {
+		if t.expired(e, t.policyFor(key), now) {
+			delete(t.entries, key)
+			n++
+		}
+	}
+	return n
+}
+
+// expired сообщает, что блокировка снята и счетчик пора обнулить.
+func (t *loginThrottle) expired(e *throttleEntry, policy throttlePolicy, now time.Time) bool {
+	return !now.Before(e.LockedUntil) && now.Sub(e.LastFailure) >= policy.ResetAfter
+}
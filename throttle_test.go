@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestThrottleReserveAndRelease(t *testing.T) {
+	th := newLoginThrottle()
+	for i := 0; i < th.byEmail.FreeAttempts; i++ {
+		if wait := th.reserve("a@school.local", "192.0.2.1"); wait != 0 {
+			t.Fatalf("attempt %d locked for %s", i+1, wait)
+		}
+	}
+	th.succeed("a@school.local", "192.0.2.1")
+	if _, ok := th.entries["email:a@school.local"]; ok {
+		t.Fatal("email counter kept after success")
+	}
+	if e := th.entries["ip:192.0.2.1"]; e.Failures != th.byEmail.FreeAttempts-1 {
+		t.Fatalf("ip failures after success = %d, want %d", e.Failures, th.byEmail.FreeAttempts-1)
+	}
+
+	for i := 0; i <= th.byEmail.FreeAttempts; i++ {
+		th.reserve("b@school.local", "192.0.2.2")
+	}
+	if wait := th.reserve("b@school.local", "192.0.2.3"); wait == 0 {
+		t.Fatal("email not locked after exceeding the limit")
+	}
+	th.release("b@school.local", "192.0.2.2")
+	if wait := th.reserve("b@school.local", "192.0.2.2"); wait != 0 {
+		t.Fatalf("released attempt still locked for %s", wait)
+	}
+}
+
+func TestThrottleEviction(t *testing.T) {
+	th := newLoginThrottle()
+	th.maxEntries = 10
+	for i := 0; i <= th.byEmail.FreeAttempts; i++ {
+		th.reserve("victim@school.local", fmt.Sprintf("198.51.100.%d", i))
+	}
+	for i := 0; i < 100; i++ {
+		th.reserve(fmt.Sprintf("user%d@school.local", i), fmt.Sprintf("192.0.2.%d", i))
+	}
+	if n := len(th.entries); n > th.maxEntries {
+		t.Fatalf("entries = %d, want at most %d", n, th.maxEntries)
+	}
+	if wait := th.reserve("victim@school.local", "203.0.113.1"); wait == 0 {
+		t.Fatal("locked email counter evicted by a flood of new keys")
+	}
+}
@@ -1,5 +1,7 @@
 package main
 
+import "time"
+
 // Role описывает роль пользователя в системе.
 type Role string
 
@@ -12,6 +14,60 @@ const (
 	RoleStudent Role = "student"
 )
 
+// Class — класс из реестра. Name — каноничное имя (см. normalizeClassName),
+// на которое ссылаются ученики, расписание и домашние задания. ScaleID — шкала
+// оценивания класса для предметов без собственной шкалы.
+type Class struct {
+	Name              string    `json:"name"`
+	Grade             int       `json:"grade"`
+	Letter            string    `json:"letter"`
+	AcademicYear      string    `json:"academicYear"`
+	HomeroomTeacherID int64     `json:"homeroomTeacherId,omitempty"`
+	ScaleID           int64     `json:"scaleId,omitempty"`
+	CreatedAt         time.Time `json:"createdAt"`
+}
+
+// Subject — предмет из справочника. Записи ссылаются на него по SubjectID и хранят
+// каноничное название Name; ShortName и Aliases помогают находить предмет по другим написаниям.
+// ScaleID — шкала оценивания предмета, она важнее шкалы класса.
+type Subject struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	ShortName string    `json:"shortName,omitempty"`
+	Aliases   []string  `json:"aliases"`
+	ScaleID   int64     `json:"scaleId,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// GradingScale — шкала оценивания: допустимые значения оценок, их подписи и числовые
+// эквиваленты для средних. Default — шкала для предметов и классов без своей шкалы.
+type GradingScale struct {
+	ID      int64        `json:"id"`
+	Name    string       `json:"name"`
+	Values  []ScaleValue `json:"values"`
+	Default bool         `json:"default"`
+}
+
+// ScaleValue — одно значение шкалы. Значения без Numeric (например, «зачет»)
+// в средних не учитываются.
+type ScaleValue struct {
+	Value   int      `json:"value"`
+	Label   string   `json:"label"`
+	Numeric *float64 `json:"numeric,omitempty"`
+}
+
+// Assignment — закрепление учителя за предметом в классе. Group задает подгруппу
+// класса (например, группу по английскому); пустая — весь класс.
+type Assignment struct {
+	ID        int64     `json:"id"`
+	TeacherID int64     `json:"teacherId"`
+	SubjectID int64     `json:"subjectId,omitempty"`
+	Subject   string    `json:"subject"`
+	ClassName string    `json:"className"`
+	Group     string    `json:"group,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
 // User — учетная запись пользователя.
 type User struct {
 	ID           int64  `json:"id"`
@@ -20,12 +76,110 @@ type User struct {
 	PasswordHash string `json:"-"`
 	Role         Role   `json:"role"`
 	ClassName    string `json:"className,omitempty"`
+	// Archived — учетная запись выпускника: вход запрещен, данные сохраняются.
+	Archived bool `json:"archived,omitempty"`
+}
+
+// Session — сессия входа: токен доступа с коротким сроком жизни и refresh-токен.
+// В хранилище попадают только хеши токенов.
+type Session struct {
+	ID               string    `json:"id"`
+	UserID           int64     `json:"userId"`
+	UserAgent        string    `json:"userAgent"`
+	IP               string    `json:"ip"`
+	TokenHash        string    `json:"-"`
+	RefreshHash      string    `json:"-"`
+	CreatedAt        time.Time `json:"createdAt"`
+	LastUsedAt       time.Time `json:"lastUsedAt"`
+	ExpiresAt        time.Time `json:"expiresAt"`
+	RefreshExpiresAt time.Time `json:"refreshExpiresAt"`
+}
+
+// SessionTokens — пара токенов, выдаваемая клиенту при входе и обновлении сессии.
+type SessionTokens struct {
+	Token            string    `json:"token"`
+	RefreshToken     string    `json:"refreshToken"`
+	ExpiresAt        time.Time `json:"expiresAt"`
+	RefreshExpiresAt time.Time `json:"refreshExpiresAt"`
+}
+
+// Invite — одноразовый код приглашения, привязанный к роли и классу.
+// Сам код выдается только при создании, в хранилище лежит его хеш.
+type Invite struct {
+	ID        int64      `json:"id"`
+	CodeHash  string     `json:"-"`
+	Role      Role       `json:"role"`
+	ClassName string     `json:"className,omitempty"`
+	CreatedBy int64      `json:"createdBy"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ExpiresAt time.Time  `json:"expiresAt"`
+	UsedBy    int64      `json:"usedBy,omitempty"`
+	UsedAt    *time.Time `json:"usedAt,omitempty"`
+}
+
+// PasswordReset — одноразовый код сброса пароля, выданный администратором.
+// У пользователя может быть только один действующий код; в хранилище лежит его хеш.
+type PasswordReset struct {
+	UserID    int64     `json:"userId"`
+	CodeHash  string    `json:"-"`
+	CreatedBy int64     `json:"createdBy"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// FieldChange — изменение одного поля сущности.
+type FieldChange struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// AuditEntry — запись журнала действий администраторов: кто, когда и что изменил.
+type AuditEntry struct {
+	ID       int64         `json:"id"`
+	ActorID  int64         `json:"actorId"`
+	Action   string        `json:"action"`
+	Entity   string        `json:"entity"`
+	EntityID int64         `json:"entityId"`
+	Changes  []FieldChange `json:"changes,omitempty"`
+	At       time.Time     `json:"at"`
+}
+
+// YearArchive — снимок учебного года, сделанный при переводе на следующий год.
+// Архив только для чтения.
+type YearArchive struct {
+	AcademicYear string          `json:"academicYear"`
+	ArchivedAt   time.Time       `json:"archivedAt"`
+	ArchivedBy   int64           `json:"archivedBy"`
+	Classes      []Class         `json:"classes"`
+	Students     []User          `json:"students"`
+	Grades       []Grade         `json:"grades"`
+	Homework     []Homework      `json:"homework"`
+	Schedule     []ScheduleEntry `json:"schedule"`
+	// GradeRevisions — история исправлений оценок за год.
+	GradeRevisions []GradeRevision `json:"gradeRevisions"`
+	// Terms и TermGrades — учебные периоды года и утвержденные итоговые оценки за них.
+	Terms      []Term      `json:"terms"`
+	TermGrades []TermGrade `json:"termGrades"`
+	// Attendance — отметки посещаемости за год.
+	Attendance []Attendance `json:"attendance"`
+	// Lessons — журнал уроков за год.
+	Lessons []Lesson `json:"lessons"`
+}
+
+// YearArchiveSummary — краткие сведения об архиве учебного года.
+type YearArchiveSummary struct {
+	AcademicYear string         `json:"academicYear"`
+	ArchivedAt   time.Time      `json:"archivedAt"`
+	ArchivedBy   int64          `json:"archivedBy"`
+	Counts       map[string]int `json:"counts"`
 }
 
 // ScheduleEntry — структурная запись урока.
 type ScheduleEntry struct {
 	ID        int64  `json:"id"`
 	ClassName string `json:"className"`
+	SubjectID int64  `json:"subjectId,omitempty"`
 	Subject   string `json:"subject"`
 	Weekday   string `json:"weekday"`
 	StartTime string `json:"startTime"`
@@ -45,17 +199,230 @@ type SchedulePhoto struct {
 type Grade struct {
 	ID        int64  `json:"id"`
 	StudentID int64  `json:"studentId"`
+	SubjectID int64  `json:"subjectId,omitempty"`
 	Subject   string `json:"subject"`
 	Value     int    `json:"value"`
 	Comment   string `json:"comment"`
 	TeacherID int64  `json:"teacherId"`
 	Date      string `json:"date"`
+	// Type — код вида работы из gradeTypeCatalog (test, control, homework, oral, lab).
+	Type string `json:"type"`
+	// ScaleID — шкала, по которой выставлена оценка; 0 — шкала по умолчанию.
+	ScaleID int64 `json:"scaleId,omitempty"`
+	// LessonID — запись журнала уроков, на котором выставлена оценка; 0 — без привязки.
+	LessonID int64 `json:"lessonId,omitempty"`
+}
+
+// GradeType — вид работы, за которую ставится оценка; Weight — ее вес в среднем балле.
+type GradeType struct {
+	Code   string  `json:"code"`
+	Name   string  `json:"name"`
+	Weight float64 `json:"weight"`
+}
+
+// GradeAverage — средневзвешенный балл ученика по предмету и число учтенных оценок.
+type GradeAverage struct {
+	StudentID int64   `json:"studentId"`
+	SubjectID int64   `json:"subjectId,omitempty"`
+	Subject   string  `json:"subject"`
+	Average   float64 `json:"average"`
+	Count     int     `json:"count"`
+}
+
+// GradeRevision — неизменяемая запись об исправлении или удалении оценки:
+// какие поля изменились (было/стало), кто, когда и почему.
+type GradeRevision struct {
+	ID        int64         `json:"id"`
+	GradeID   int64         `json:"gradeId"`
+	StudentID int64         `json:"studentId"`
+	SubjectID int64         `json:"subjectId,omitempty"`
+	Subject   string        `json:"subject"`
+	Action    string        `json:"action"`
+	Changes   []FieldChange `json:"changes"`
+	ActorID   int64         `json:"actorId"`
+	Reason    string        `json:"reason"`
+	At        time.Time     `json:"at"`
+}
+
+// Term — учебный период (четверть, триместр, полугодие) внутри учебного года.
+// Даты начала и конца включаются в период.
+type Term struct {
+	ID           int64  `json:"id"`
+	AcademicYear string `json:"academicYear"`
+	Name         string `json:"name"`
+	StartDate    string `json:"startDate"`
+	EndDate      string `json:"endDate"`
+}
+
+// TermRounding — правила, по которым средний балл за период превращается в предложенную итоговую оценку.
+type TermRounding struct {
+	// Threshold — дробная часть среднего, начиная с которой он округляется вверх
+	// (0.5: 3.5 → 4; 0.6: 3.5 → 3, 3.6 → 4).
+	Threshold float64 `json:"threshold"`
+	// MinGrades — минимальное число оценок за период, при котором итоговая предлагается.
+	MinGrades int `json:"minGrades"`
+}
+
+// TermGrade — утвержденная учителем итоговая оценка ученика по предмету за период.
+// Overridden означает, что учитель поставил не ту оценку, которую предложила система.
+type TermGrade struct {
+	ID         int64     `json:"id"`
+	TermID     int64     `json:"termId"`
+	StudentID  int64     `json:"studentId"`
+	SubjectID  int64     `json:"subjectId"`
+	Subject    string    `json:"subject"`
+	Value      int       `json:"value"`
+	ScaleID    int64     `json:"scaleId,omitempty"`
+	Average    float64   `json:"average"`
+	Proposed   int       `json:"proposed,omitempty"`
+	Overridden bool      `json:"overridden"`
+	Reason     string    `json:"reason,omitempty"`
+	TeacherID  int64     `json:"teacherId"`
+	ApprovedAt time.Time `json:"approvedAt"`
+}
+
+// TermGradeProposal — итоговая оценка, вычисленная по оценкам ученика за период,
+// и уже утвержденная оценка, если она есть.
+type TermGradeProposal struct {
+	StudentID int64   `json:"studentId"`
+	SubjectID int64   `json:"subjectId"`
+	Subject   string  `json:"subject"`
+	ScaleID   int64   `json:"scaleId"`
+	Average   float64 `json:"average"`
+	Count     int     `json:"count"`
+	// Proposed равна 0, если оценок за период меньше TermRounding.MinGrades.
+	Proposed int        `json:"proposed"`
+	Approved *TermGrade `json:"approved,omitempty"`
+}
+
+// JournalMatrix — журнал класса по предмету в бумажном виде: строки — ученики,
+// столбцы — даты уроков. Rows[i].Cells[j] — оценки ученика i за дату Columns[j].
+type JournalMatrix struct {
+	ClassName string          `json:"className"`
+	SubjectID int64           `json:"subjectId"`
+	Subject   string          `json:"subject"`
+	From      string          `json:"from"`
+	To        string          `json:"to"`
+	Columns   []JournalColumn `json:"columns"`
+	Rows      []JournalRow    `json:"rows"`
+}
+
+// JournalColumn — дата урока в журнале. Scheduled ложно для дат вне расписания,
+// по которым выставлены оценки или записаны уроки; Lessons — записанные уроки даты.
+type JournalColumn struct {
+	Date      string   `json:"date"`
+	Weekday   string   `json:"weekday"`
+	Scheduled bool     `json:"scheduled"`
+	Lessons   []Lesson `json:"lessons"`
+}
+
+// JournalRow — строка журнала: ученик, его оценки по датам и средневзвешенный балл
+// за период (Count — число учтенных в нем оценок).
+type JournalRow struct {
+	StudentID int64     `json:"studentId"`
+	FullName  string    `json:"fullName"`
+	Cells     [][]Grade `json:"cells"`
+	Average   float64   `json:"average"`
+	Count     int       `json:"count"`
+}
+
+// Diary — дневник ученика за учебную неделю (Week в формате ISO, например 2026-W10):
+// дни с понедельника по субботу и воскресенье, если в нем есть записи.
+type Diary struct {
+	Week      string     `json:"week"`
+	From      string     `json:"from"`
+	To        string     `json:"to"`
+	ClassName string     `json:"className"`
+	Days      []DiaryDay `json:"days"`
+}
+
+// DiaryDay — страница дневника за день.
+type DiaryDay struct {
+	Date    string        `json:"date"`
+	Weekday string        `json:"weekday"`
+	Lessons []DiaryLesson `json:"lessons"`
+}
+
+// DiaryLesson — строка дневника: урок по расписанию (Period — его номер в дне) или, если
+// Scheduled ложно, предмет вне расписания, по которому в этот день есть оценки, задания
+// или записанный урок. Homework — задания со сроком сдачи в этот день, Grades — оценки,
+// полученные на уроке, Attendance — отметка посещаемости, кроме present.
+type DiaryLesson struct {
+	Period     int        `json:"period,omitempty"`
+	SubjectID  int64      `json:"subjectId"`
+	Subject    string     `json:"subject"`
+	StartTime  string     `json:"startTime,omitempty"`
+	EndTime    string     `json:"endTime,omitempty"`
+	Room       string     `json:"room,omitempty"`
+	Scheduled  bool       `json:"scheduled"`
+	LessonID   int64      `json:"lessonId,omitempty"`
+	Topic      string     `json:"topic,omitempty"`
+	Homework   []Homework `json:"homework"`
+	Grades     []Grade    `json:"grades"`
+	Attendance string     `json:"attendance,omitempty"`
+}
+
+// Lesson — запись журнала уроков: проведенный урок предмета в классе, его номер в
+// расписании дня (Period), тема и задание, выданное на уроке (HomeworkID, 0 — без задания).
+type Lesson struct {
+	ID         int64     `json:"id"`
+	ClassName  string    `json:"className"`
+	SubjectID  int64     `json:"subjectId"`
+	Subject    string    `json:"subject"`
+	Date       string    `json:"date"`
+	Period     int       `json:"period"`
+	Topic      string    `json:"topic"`
+	HomeworkID int64     `json:"homeworkId,omitempty"`
+	TeacherID  int64     `json:"teacherId"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// Attendance — отметка посещаемости ученика на уроке предмета в дату: present, absent,
+// late или excused. На ученика, предмет, дату и урок из журнала уроков (LessonID)
+// приходится одна отметка; повторная отметка ее заменяет. ClassName — класс ученика
+// на момент отметки.
+type Attendance struct {
+	ID        int64     `json:"id"`
+	StudentID int64     `json:"studentId"`
+	ClassName string    `json:"className"`
+	SubjectID int64     `json:"subjectId"`
+	Subject   string    `json:"subject"`
+	Date      string    `json:"date"`
+	LessonID  int64     `json:"lessonId,omitempty"`
+	Status    string    `json:"status"`
+	Comment   string    `json:"comment,omitempty"`
+	TeacherID int64     `json:"teacherId"`
+	MarkedAt  time.Time `json:"markedAt"`
+}
+
+// AttendanceTotals — итоги посещаемости за период: Marked — число отметок,
+// остальные поля — число отметок с соответствующим статусом.
+type AttendanceTotals struct {
+	Marked  int `json:"marked"`
+	Absent  int `json:"absent"`
+	Late    int `json:"late"`
+	Excused int `json:"excused"`
+}
+
+// AttendanceStudentTotals — итоги посещаемости ученика.
+type AttendanceStudentTotals struct {
+	StudentID int64  `json:"studentId"`
+	FullName  string `json:"fullName"`
+	AttendanceTotals
+}
+
+// AttendanceReport — итоги посещаемости класса и его учеников за период.
+type AttendanceReport struct {
+	ClassName string `json:"className"`
+	AttendanceTotals
+	Students []AttendanceStudentTotals `json:"students"`
 }
 
 // Homework — домашнее задание для класса.
 type Homework struct {
 	ID          int64  `json:"id"`
 	ClassName   string `json:"className"`
+	SubjectID   int64  `json:"subjectId,omitempty"`
 	Subject     string `json:"subject"`
 	Description string `json:"description"`
 	DueDate     string `json:"dueDate"`
@@ -64,5 +431,10 @@ type Homework struct {
 
 // Server объединяет HTTP-слой и хранилище данных.
 type Server struct {
-	store *Storage
+	store        *Storage
+	sessionTTL   sessionTTL
+	registration registrationMode
+	throttle     *loginThrottle
+	outbox       Outbox
+	resetTTL     time.Duration
 }
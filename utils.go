@@ -1,11 +1,20 @@
 package main
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // writeJSON отправляет JSON-ответ с заданным HTTP-статусом.
@@ -24,7 +33,92 @@ func writeError(w http.ResponseWriter, status int, message string) {
 	})
 }
 
-// normalizeClassName приводит обозначение класса к каноничному виду.
+// queryID читает необязательный числовой ID из query-параметра name. Пустое
+// значение дает 0; при неверном значении пишет 400 и возвращает false.
+func queryID(w http.ResponseWriter, r *http.Request, name string) (int64, bool) {
+	raw := strings.TrimSpace(r.URL.Query().Get(name))
+	if raw == "" {
+		return 0, true
+	}
+	id, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid "+name)
+		return 0, false
+	}
+	return id, true
+}
+
+// weekdayAliases — написания дней недели в расписании: английские и русские
+// названия, сокращения и номера (1 — понедельник).
+var weekdayAliases = map[string]time.Weekday{
+	"monday": time.Monday, "mon": time.Monday, "понедельник": time.Monday, "пн": time.Monday, "1": time.Monday,
+	"tuesday": time.Tuesday, "tue": time.Tuesday, "вторник": time.Tuesday, "вт": time.Tuesday, "2": time.Tuesday,
+	"wednesday": time.Wednesday, "wed": time.Wednesday, "среда": time.Wednesday, "ср": time.Wednesday, "3": time.Wednesday,
+	"thursday": time.Thursday, "thu": time.Thursday, "четверг": time.Thursday, "чт": time.Thursday, "4": time.Thursday,
+	"friday": time.Friday, "fri": time.Friday, "пятница": time.Friday, "пт": time.Friday, "5": time.Friday,
+	"saturday": time.Saturday, "sat": time.Saturday, "суббота": time.Saturday, "сб": time.Saturday, "6": time.Saturday,
+	"sunday": time.Sunday, "sun": time.Sunday, "воскресенье": time.Sunday, "вс": time.Sunday, "7": time.Sunday,
+}
+
+// parseWeekday распознает день недели из записи расписания без учета регистра и точки
+// в сокращении («Пн.», «monday», «3»).
+func parseWeekday(s string) (time.Weekday, bool) {
+	d, ok := weekdayAliases[strings.TrimSuffix(strings.ToLower(strings.TrimSpace(s)), ".")]
+	return d, ok
+}
+
+// sortSchedule упорядочивает записи расписания по дню недели (с понедельника) и времени
+// начала; записи с нераспознанным днем или временем идут последними.
+func sortSchedule(entries []ScheduleEntry) {
+	day := func(e ScheduleEntry) int {
+		if d, ok := parseWeekday(e.Weekday); ok {
+			return (int(d) + 6) % 7
+		}
+		return 7
+	}
+	start := func(e ScheduleEntry) int {
+		t, err := time.Parse("15:04", strings.TrimSpace(e.StartTime))
+		if err != nil {
+			return 24 * 60
+		}
+		return t.Hour()*60 + t.Minute()
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if di, dj := day(entries[i]), day(entries[j]); di != dj {
+			return di < dj
+		}
+		if si, sj := start(entries[i]), start(entries[j]); si != sj {
+			return si < sj
+		}
+		return entries[i].ID < entries[j].ID
+	})
+}
+
+// parseISOWeek разбирает неделю в формате ISO 8601 («2026-W10») и возвращает ее понедельник.
+func parseISOWeek(s string) (time.Time, bool) {
+	yearText, weekText, found := strings.Cut(strings.TrimSpace(s), "-W")
+	year, err1 := strconv.Atoi(yearText)
+	week, err2 := strconv.Atoi(weekText)
+	if !found || len(yearText) != 4 || len(weekText) != 2 || err1 != nil || err2 != nil {
+		return time.Time{}, false
+	}
+	jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, time.UTC)
+	monday := jan4.AddDate(0, 0, (week-1)*7-(int(jan4.Weekday())+6)%7)
+	if y, w := monday.ISOWeek(); y != year || w != week {
+		return time.Time{}, false
+	}
+	return monday, true
+}
+
+// isoWeek возвращает неделю даты в формате ISO 8601 («2026-W10»).
+func isoWeek(t time.Time) string {
+	year, week := t.ISOWeek()
+	return fmt.Sprintf("%04d-W%02d", year, week)
+}
+
+// normalizeClassName приводит обозначение класса к каноничному виду: кириллические
+// литеры с латинским двойником (А, В, Е, ...) заменяются латинскими, остальные (Б, Г, Д, ...)
+// сохраняются.
 func normalizeClassName(s string) string {
 	clean := strings.ToUpper(strings.TrimSpace(s))
 	clean = strings.ReplaceAll(clean, " ", "")
@@ -32,6 +126,7 @@ func normalizeClassName(s string) string {
 		"А", "A",
 		"В", "B",
 		"Е", "E",
+		"Ё", "E",
 		"К", "K",
 		"М", "M",
 		"Н", "H",
@@ -45,9 +140,63 @@ func normalizeClassName(s string) string {
 	clean = replacer.Replace(clean)
 	var b strings.Builder
 	for _, r := range clean {
-		if (r >= '0' && r <= '9') || (r >= 'A' && r <= 'Z') {
+		if (r >= '0' && r <= '9') || isClassLetter(r) {
 			b.WriteRune(r)
 		}
 	}
 	return b.String()
 }
+
+// isClassLetter проверяет, что r — заглавная латинская или кириллическая буква.
+func isClassLetter(r rune) bool {
+	return (r >= 'A' && r <= 'Z') || (r >= 'А' && r <= 'Я')
+}
+
+// clientIP возвращает адрес клиента без порта.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// tooLarge сообщает, что чтение тела запроса прервано ограничением http.MaxBytesReader.
+func tooLarge(err error) bool {
+	var maxErr *http.MaxBytesError
+	return errors.As(err, &maxErr)
+}
+
+// retryAfterSeconds округляет ожидание вверх до целых секунд для заголовка Retry-After.
+func retryAfterSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
+
+// randomHex возвращает n случайных байт в hex-представлении.
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// hashToken вычисляет хеш секретного токена для хранения.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// envDuration читает длительность из переменной окружения (формат time.ParseDuration).
+func envDuration(name string, def time.Duration) time.Duration {
+	raw := strings.TrimSpace(os.Getenv(name))
+	if raw == "" {
+		return def
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d <= 0 {
+		log.Printf("invalid %s=%q, using %s", name, raw, def)
+		return def
+	}
+	return d
+}
@@ -0,0 +1,473 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Представления для обработчиков (средние баллы, итоговые оценки, журнал класса,
+// отчеты и дневник) собираются здесь поверх простых запросов Storage; само хранилище
+// отвечает только за записи и их проверку.
+
+// maxJournalDays — наибольшая длина периода журнала (учебный год с запасом).
+const maxJournalDays = 400
+
+// gradeMath — веса видов работ и шкалы, по которым оценки переводятся в средние баллы.
+type gradeMath struct {
+	weights map[string]float64
+	scales  map[int64]GradingScale
+	// fallback — шкала по умолчанию для оценок без известной шкалы.
+	fallback GradingScale
+}
+
+// newGradeMath читает действующие веса видов работ и шкалы из хранилища.
+func newGradeMath(st *Storage) gradeMath {
+	m := gradeMath{weights: map[string]float64{}, scales: map[int64]GradingScale{}, fallback: defaultGradingScales()[0]}
+	for _, t := range st.listGradeTypes() {
+		m.weights[t.Code] = t.Weight
+	}
+	for _, sc := range st.listScales() {
+		m.scales[sc.ID] = sc
+		if sc.Default {
+			m.fallback = sc
+		}
+	}
+	return m
+}
+
+// weight возвращает вес вида работы; у неизвестного вида вес 1.
+func (m gradeMath) weight(code string) float64 {
+	if code == "" {
+		code = defaultGradeType
+	}
+	if w, ok := m.weights[code]; ok {
+		return w
+	}
+	return 1
+}
+
+// scale возвращает шкалу, по которой выставлена оценка.
+func (m gradeMath) scale(g Grade) GradingScale {
+	if sc, ok := m.scales[g.ScaleID]; ok {
+		return sc
+	}
+	return m.fallback
+}
+
+// averages считает средневзвешенный балл по каждой паре «ученик — предмет».
+// Оценки переводятся в числа по своей шкале; оценки без числового эквивалента
+// (зачет) не учитываются. Результат упорядочен по ученику и предмету.
+func (m gradeMath) averages(grades []Grade) []GradeAverage {
+	type key struct {
+		studentID int64
+		subject   string
+	}
+	type acc struct {
+		avg        GradeAverage
+		sum, total float64
+	}
+	groups := map[key]*acc{}
+	for _, g := range grades {
+		n, ok := m.scale(g).numeric(g.Value)
+		if !ok {
+			continue
+		}
+		k := key{g.StudentID, g.Subject}
+		a := groups[k]
+		if a == nil {
+			a = &acc{avg: GradeAverage{StudentID: g.StudentID, SubjectID: g.SubjectID, Subject: g.Subject}}
+			groups[k] = a
+		}
+		w := m.weight(g.Type)
+		a.sum += n * w
+		a.total += w
+		a.avg.Count++
+	}
+	res := make([]GradeAverage, 0, len(groups))
+	for _, a := range groups {
+		a.avg.Average = math.Round(a.sum/a.total*100) / 100
+		res = append(res, a.avg)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].StudentID != res[j].StudentID {
+			return res[i].StudentID < res[j].StudentID
+		}
+		return res[i].Subject < res[j].Subject
+	})
+	return res
+}
+
+// gradeAverages считает средневзвешенные баллы оценок с действующими весами видов
+// работ (см. gradeMath.averages).
+func gradeAverages(st *Storage, grades []Grade) []GradeAverage {
+	return newGradeMath(st).averages(grades)
+}
+
+// termProposal считает средневзвешенный балл ученика по предмету за период из его
+// оценок grades и округляет его по правилам rounding до значения шкалы scale (шкалы
+// предмета в классе ученика). approved — уже утвержденная итоговая оценка, если есть.
+func (m gradeMath) termProposal(term Term, sub Subject, studentID int64, grades []Grade, scale GradingScale, rounding TermRounding, approved *TermGrade) TermGradeProposal {
+	p := TermGradeProposal{StudentID: studentID, SubjectID: sub.ID, Subject: sub.Name, ScaleID: scale.ID, Approved: approved}
+	var sum, total float64
+	for _, g := range grades {
+		if g.StudentID != studentID || g.SubjectID != sub.ID || g.Date < term.StartDate || g.Date > term.EndDate {
+			continue
+		}
+		n, ok := m.scale(g).numeric(g.Value)
+		if !ok {
+			continue
+		}
+		w := m.weight(g.Type)
+		sum += n * w
+		total += w
+		p.Count++
+	}
+	if p.Count > 0 {
+		p.Average = math.Round(sum/total*100) / 100
+	}
+	if p.Count > 0 && p.Count >= rounding.MinGrades {
+		p.Proposed, _ = scale.nearest(roundAverage(p.Average, rounding.Threshold))
+	}
+	return p
+}
+
+// termGradeProposals вычисляет предложенные итоговые оценки по предмету за период
+// для перечисленных учеников в том же порядке.
+func termGradeProposals(st *Storage, termID int64, subject string, studentIDs []int64) ([]TermGradeProposal, error) {
+	term, ok := st.getTerm(termID)
+	if !ok {
+		return nil, errUnknownTerm
+	}
+	sub, err := requireSubject(st, subject)
+	if err != nil {
+		return nil, err
+	}
+	approved := map[int64]TermGrade{}
+	for _, tg := range st.listTermGrades(termID, 0) {
+		if tg.SubjectID == sub.ID {
+			approved[tg.StudentID] = tg
+		}
+	}
+	m, rounding := newGradeMath(st), st.termRounding()
+	res := make([]TermGradeProposal, 0, len(studentIDs))
+	for _, id := range studentIDs {
+		u, _ := st.getUser(id)
+		scale, err := st.scaleFor(sub.Name, u.ClassName)
+		if err != nil {
+			return nil, err
+		}
+		var tg *TermGrade
+		if a, ok := approved[id]; ok {
+			tg = &a
+		}
+		res = append(res, m.termProposal(term, sub, id, st.listGradesByStudent(id), scale, rounding, tg))
+	}
+	return res, nil
+}
+
+// requireSubject ищет предмет в справочнике так же, как операции записи хранилища.
+func requireSubject(st *Storage, name string) (Subject, error) {
+	sub, ok := st.resolveSubject(name)
+	if !ok {
+		return Subject{}, fmt.Errorf("%w %q", errUnknownSubject, strings.TrimSpace(name))
+	}
+	return sub, nil
+}
+
+// requireClass проверяет, что класс заведен, и возвращает его нормализованное имя.
+func requireClass(st *Storage, name string) (string, error) {
+	name = normalizeClassName(name)
+	if _, ok := st.getClass(name); !ok {
+		return "", fmt.Errorf("%w %q", errUnknownClass, name)
+	}
+	return name, nil
+}
+
+// journalMatrix собирает журнал класса по предмету за период from..to (включительно).
+// Столбцы — даты уроков предмета по расписанию класса, даты записанных уроков и даты,
+// за которые выставлены оценки; строки — ученики класса по алфавиту.
+func journalMatrix(st *Storage, className, subject, from, to string) (JournalMatrix, error) {
+	className, err := requireClass(st, className)
+	if err != nil {
+		return JournalMatrix{}, err
+	}
+	sub, err := requireSubject(st, subject)
+	if err != nil {
+		return JournalMatrix{}, err
+	}
+	start, err1 := time.Parse("2006-01-02", from)
+	end, err2 := time.Parse("2006-01-02", to)
+	if err1 != nil || err2 != nil {
+		return JournalMatrix{}, errors.New("from and to must be YYYY-MM-DD")
+	}
+	if end.Before(start) || end.Sub(start) > maxJournalDays*24*time.Hour {
+		return JournalMatrix{}, errors.New("period must end after it starts and last at most 400 days")
+	}
+
+	scheduled := map[time.Weekday]bool{}
+	for _, entry := range st.listScheduleByClass(className) {
+		if entry.SubjectID == sub.ID {
+			if d, ok := parseWeekday(entry.Weekday); ok {
+				scheduled[d] = true
+			}
+		}
+	}
+	dates := map[string]bool{}
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		if scheduled[d.Weekday()] {
+			dates[d.Format("2006-01-02")] = true
+		}
+	}
+
+	m := JournalMatrix{ClassName: className, SubjectID: sub.ID, Subject: sub.Name, From: from, To: to, Columns: []JournalColumn{}, Rows: []JournalRow{}}
+	extra := map[string]bool{}
+	lessons := map[string][]Lesson{}
+	for _, l := range st.listLessons(lessonFilter{ClassName: className, Subject: sub.Name, From: from, To: to}) {
+		lessons[l.Date] = append(lessons[l.Date], l)
+		if !dates[l.Date] {
+			extra[l.Date] = true
+		}
+	}
+	byStudent := map[int64][]Grade{}
+	for _, g := range st.listGrades(gradeFilter{ClassName: className, Subject: sub.Name, From: from, To: to}) {
+		byStudent[g.StudentID] = append(byStudent[g.StudentID], g)
+		if !dates[g.Date] {
+			extra[g.Date] = true
+		}
+	}
+
+	for date := range dates {
+		m.Columns = append(m.Columns, JournalColumn{Date: date, Scheduled: true})
+	}
+	for date := range extra {
+		m.Columns = append(m.Columns, JournalColumn{Date: date})
+	}
+	sort.Slice(m.Columns, func(i, j int) bool { return m.Columns[i].Date < m.Columns[j].Date })
+	column := make(map[string]int, len(m.Columns))
+	for i := range m.Columns {
+		d, _ := time.Parse("2006-01-02", m.Columns[i].Date)
+		m.Columns[i].Weekday = strings.ToLower(d.Weekday().String())
+		m.Columns[i].Lessons = lessons[m.Columns[i].Date]
+		if m.Columns[i].Lessons == nil {
+			m.Columns[i].Lessons = []Lesson{}
+		}
+		column[m.Columns[i].Date] = i
+	}
+
+	gm := newGradeMath(st)
+	for _, u := range st.listStudentsSortedByClass() {
+		if u.ClassName != className {
+			continue
+		}
+		grades := byStudent[u.ID]
+		row := JournalRow{StudentID: u.ID, FullName: u.FullName, Cells: make([][]Grade, len(m.Columns))}
+		for i := range row.Cells {
+			row.Cells[i] = []Grade{}
+		}
+		for _, g := range grades {
+			row.Cells[column[g.Date]] = append(row.Cells[column[g.Date]], g)
+		}
+		if avg := gm.averages(grades); len(avg) > 0 {
+			row.Average, row.Count = avg[0].Average, avg[0].Count
+		}
+		m.Rows = append(m.Rows, row)
+	}
+	return m, nil
+}
+
+// attendanceReport подводит итоги посещаемости за период по классам (пустой className —
+// все классы). В итоги класса попадают его нынешние ученики, в том числе без отметок,
+// и ученики, отмеченные в этом классе раньше.
+func attendanceReport(st *Storage, className, from, to string) []AttendanceReport {
+	className = normalizeClassName(className)
+	reports := map[string]*AttendanceReport{}
+	rows := map[string]map[int64]*AttendanceStudentTotals{}
+	names := map[int64]string{}
+	row := func(class string, studentID int64) *AttendanceStudentTotals {
+		if reports[class] == nil {
+			reports[class] = &AttendanceReport{ClassName: class}
+			rows[class] = map[int64]*AttendanceStudentTotals{}
+		}
+		if rows[class][studentID] == nil {
+			name, ok := names[studentID]
+			if !ok {
+				u, _ := st.getUser(studentID)
+				name = u.FullName
+			}
+			rows[class][studentID] = &AttendanceStudentTotals{StudentID: studentID, FullName: name}
+		}
+		return rows[class][studentID]
+	}
+	for _, u := range st.listStudentsSortedByClass() {
+		if u.ClassName != "" && (className == "" || u.ClassName == className) {
+			names[u.ID] = u.FullName
+			row(u.ClassName, u.ID)
+		}
+	}
+	for _, a := range st.listAttendance(attendanceFilter{ClassName: className, From: from, To: to}) {
+		r := row(a.ClassName, a.StudentID)
+		r.count(a.Status)
+		reports[a.ClassName].count(a.Status)
+	}
+	res := make([]AttendanceReport, 0, len(reports))
+	for class, report := range reports {
+		report.Students = make([]AttendanceStudentTotals, 0, len(rows[class]))
+		for _, r := range rows[class] {
+			report.Students = append(report.Students, *r)
+		}
+		sort.Slice(report.Students, func(i, j int) bool {
+			return strings.ToLower(report.Students[i].FullName) < strings.ToLower(report.Students[j].FullName)
+		})
+		res = append(res, *report)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].ClassName < res[j].ClassName })
+	return res
+}
+
+// count учитывает отметку со статусом status.
+func (t *AttendanceTotals) count(status string) {
+	t.Marked++
+	switch status {
+	case attendanceAbsent:
+		t.Absent++
+	case attendanceLate:
+		t.Late++
+	case attendanceExcused:
+		t.Excused++
+	}
+}
+
+// studentDiary собирает дневник ученика за неделю week («2026-W10»): уроки каждого дня
+// по расписанию класса, темы из журнала уроков, задания со сроком сдачи в этот день,
+// полученные оценки и пропуски. Оценки и задания по предмету, которого нет в расписании
+// дня, попадают в отдельную строку вне расписания.
+func studentDiary(st *Storage, studentID int64, week string) (Diary, error) {
+	u, ok := st.getUser(studentID)
+	if !ok || u.Role != RoleStudent {
+		return Diary{}, errors.New("student not found")
+	}
+	monday, ok := parseISOWeek(week)
+	if !ok {
+		return Diary{}, errors.New("week must be YYYY-Www")
+	}
+	from, to := monday.Format("2006-01-02"), monday.AddDate(0, 0, 6).Format("2006-01-02")
+	d := Diary{Week: isoWeek(monday), From: from, To: to, ClassName: u.ClassName}
+
+	days := make([][]DiaryLesson, 7)
+	var entries []ScheduleEntry
+	var lessons []Lesson
+	var homework []Homework
+	if u.ClassName != "" {
+		entries = st.listScheduleByClass(u.ClassName)
+		lessons = st.listLessons(lessonFilter{ClassName: u.ClassName, From: from, To: to})
+		homework = st.listHomeworkByClass(u.ClassName)
+	}
+	for _, entry := range entries {
+		wd, ok := parseWeekday(entry.Weekday)
+		if !ok {
+			continue
+		}
+		i := (int(wd) + 6) % 7
+		days[i] = append(days[i], DiaryLesson{
+			Period:    len(days[i]) + 1,
+			SubjectID: entry.SubjectID,
+			Subject:   entry.Subject,
+			StartTime: entry.StartTime,
+			EndTime:   entry.EndTime,
+			Room:      entry.Room,
+			Scheduled: true,
+		})
+	}
+	// row находит строку дня date для предмета: урок с номером period, иначе первый урок
+	// предмета, иначе новая строка вне расписания. -1 — дата вне недели.
+	row := func(date string, subjectID int64, subject string, period int) (int, int) {
+		t, err := time.Parse("2006-01-02", date)
+		if err != nil || date < from || date > to {
+			return -1, -1
+		}
+		i := int(t.Sub(monday).Hours() / 24)
+		if period > 0 && period <= len(days[i]) && days[i][period-1].SubjectID == subjectID {
+			return i, period - 1
+		}
+		for j, l := range days[i] {
+			if l.SubjectID == subjectID {
+				return i, j
+			}
+		}
+		days[i] = append(days[i], DiaryLesson{SubjectID: subjectID, Subject: subject})
+		return i, len(days[i]) - 1
+	}
+
+	// period возвращает номер урока из журнала уроков; 0 — без привязки к уроку.
+	periods := map[int64]int{}
+	period := func(lessonID int64) int {
+		if lessonID == 0 {
+			return 0
+		}
+		if p, ok := periods[lessonID]; ok {
+			return p
+		}
+		l, _ := st.getLesson(lessonID)
+		periods[lessonID] = l.Period
+		return l.Period
+	}
+
+	for _, l := range lessons {
+		periods[l.ID] = l.Period
+		i, j := row(l.Date, l.SubjectID, l.Subject, l.Period)
+		if r := &days[i][j]; r.LessonID == 0 {
+			r.LessonID, r.Topic = l.ID, l.Topic
+		} else {
+			r.Topic += "; " + l.Topic
+		}
+	}
+	sort.Slice(homework, func(i, j int) bool { return homework[i].ID < homework[j].ID })
+	for _, hw := range homework {
+		if hw.DueDate < from || hw.DueDate > to {
+			continue
+		}
+		if i, j := row(hw.DueDate, hw.SubjectID, hw.Subject, 0); i >= 0 {
+			days[i][j].Homework = append(days[i][j].Homework, hw)
+		}
+	}
+	for _, g := range st.listGrades(gradeFilter{StudentID: studentID, From: from, To: to}) {
+		if i, j := row(g.Date, g.SubjectID, g.Subject, period(g.LessonID)); i >= 0 {
+			days[i][j].Grades = append(days[i][j].Grades, g)
+		}
+	}
+	for _, a := range st.listAttendance(attendanceFilter{StudentID: studentID, From: from, To: to}) {
+		if a.Status != attendancePresent {
+			if i, j := row(a.Date, a.SubjectID, a.Subject, period(a.LessonID)); i >= 0 {
+				days[i][j].Attendance = a.Status
+			}
+		}
+	}
+
+	for i, lessons := range days {
+		if i == 6 && len(lessons) == 0 {
+			break
+		}
+		date := monday.AddDate(0, 0, i)
+		for j := range lessons {
+			if lessons[j].Homework == nil {
+				lessons[j].Homework = []Homework{}
+			}
+			if lessons[j].Grades == nil {
+				lessons[j].Grades = []Grade{}
+			}
+		}
+		if lessons == nil {
+			lessons = []DiaryLesson{}
+		}
+		d.Days = append(d.Days, DiaryDay{
+			Date:    date.Format("2006-01-02"),
+			Weekday: strings.ToLower(date.Weekday().String()),
+			Lessons: lessons,
+		})
+	}
+	return d, nil
+}